@@ -0,0 +1,232 @@
+// Package auth authenticate the clients when connect, publish and play.
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"seal/conf"
+	"strings"
+	"sync"
+)
+
+const (
+	// ActionConnect the client connect the app.
+	ActionConnect = "connect"
+	// ActionPublish the client publish the stream.
+	ActionPublish = "publish"
+	// ActionPlay the client play the stream, by rtmp, http-flv or hls.
+	ActionPlay = "play"
+)
+
+// Info the client info to authenticate.
+type Info struct {
+	Action     string     `json:"action"`
+	Vhost      string     `json:"vhost,omitempty"`
+	App        string     `json:"app"`
+	Stream     string     `json:"stream"`
+	Params     url.Values `json:"params"`
+	TcURL      string     `json:"tcUrl"`
+	PageURL    string     `json:"pageUrl"`
+	RemoteAddr string     `json:"remoteAddr"`
+}
+
+// Authenticator accept the client if return nil, otherwise reject it with the reason.
+type Authenticator interface {
+	Authenticate(info *Info) error
+}
+
+// Auth authenticate the clients of a server by its config, each server has its own.
+type Auth struct {
+	// lock protect the authenticator and config, which are replaced when reload.
+	lock sync.RWMutex
+	// authenticator the authenticator of config, nil if auth is disabled.
+	authenticator Authenticator
+	config        *conf.Config
+	// err the error to create the authenticator of config, reject all clients if not nil.
+	err error
+	// overrides the authenticators of the auth overridden by vhost or app, cleared when reload.
+	overrides map[authKey]Authenticator
+
+	logger *log.Logger
+}
+
+// New create the auth of config c, log to the std log,
+// the clients are rejected if the auth of c is invalid, which is checked by the validate of config.
+func New(c *conf.Config) *Auth {
+	au := &Auth{
+		config: c,
+		logger: log.Default(),
+	}
+	au.authenticator, au.err = newAuthenticator(c)
+
+	return au
+}
+
+// SetLogger set the logger, must be called before used.
+func (au *Auth) SetLogger(l *log.Logger) {
+	au.logger = l
+}
+
+// Reload create the authenticator by config c and replace the current,
+// keep the current if c is invalid.
+func (au *Auth) Reload(c *conf.Config) (err error) {
+	var a Authenticator
+	if a, err = newAuthenticator(c); err != nil {
+		return
+	}
+
+	au.lock.Lock()
+	au.authenticator = a
+	au.config = c
+	au.err = nil
+	au.overrides = nil
+	au.lock.Unlock()
+
+	au.logger.Println("auth reload success, type=", c.Auth.Type)
+
+	return
+}
+
+// newAuthenticator create the authenticator by the auth of config c, nil if auth is disabled.
+func newAuthenticator(c *conf.Config) (a Authenticator, err error) {
+	cfg := &c.Auth
+
+	switch cfg.Type {
+	case "", "none":
+	case "secret":
+		if 0 == len(cfg.Secret) {
+			err = fmt.Errorf("auth type is secret, but secret is empty")
+			return
+		}
+		a = NewSecretAuthenticator(cfg.Secret)
+	case "http":
+		if 0 == len(cfg.HTTPURL) {
+			err = fmt.Errorf("auth type is http, but httpUrl is empty")
+			return
+		}
+		a = NewHTTPAuthenticator(cfg.HTTPURL, cfg.HTTPTimeout)
+	default:
+		err = fmt.Errorf("unknown auth type=%s", cfg.Type)
+		return
+	}
+
+	return
+}
+
+// authKey the settings to create the authenticator.
+type authKey struct {
+	typ         string
+	secret      string
+	httpURL     string
+	httpTimeout uint32
+}
+
+// overrideAuthenticator the authenticator of the auth of c overridden by vhost or app,
+// created once for the same settings, which are a few configured.
+func (au *Auth) overrideAuthenticator(c *conf.Config) (a Authenticator, err error) {
+	cfg := &c.Auth
+	key := authKey{cfg.Type, cfg.Secret, cfg.HTTPURL, cfg.HTTPTimeout}
+
+	au.lock.RLock()
+	a, ok := au.overrides[key]
+	au.lock.RUnlock()
+
+	if ok {
+		return
+	}
+
+	if a, err = newAuthenticator(c); err != nil {
+		return
+	}
+
+	au.lock.Lock()
+	defer au.lock.Unlock()
+
+	if nil == au.overrides {
+		au.overrides = make(map[authKey]Authenticator)
+	}
+
+	// created by other client at the same time, use the same one.
+	if v, ok := au.overrides[key]; ok {
+		return v, nil
+	}
+	au.overrides[key] = a
+
+	return
+}
+
+// Check authenticate the client by the authenticator of config, or of the vhost and app of client,
+// accept it if auth is disabled or the action is not checked by config.
+func (au *Auth) Check(info *Info) (err error) {
+	au.lock.RLock()
+	a, c := au.authenticator, au.config
+	err = au.err
+	au.lock.RUnlock()
+
+	if nil != err || nil == c {
+		return
+	}
+
+	// the auth overridden by vhost or app.
+	sc := c.StreamConfig(info.Vhost, info.App)
+	if sc.Auth != c.Auth {
+		if a, err = au.overrideAuthenticator(sc); err != nil {
+			return
+		}
+	}
+
+	if nil == a {
+		return
+	}
+
+	cfg := &sc.Auth
+
+	var enable string
+	switch info.Action {
+	case ActionConnect:
+		enable = cfg.Connect
+	case ActionPublish:
+		enable = cfg.Publish
+	case ActionPlay:
+		enable = cfg.Play
+	}
+
+	if "true" != enable {
+		return
+	}
+
+	if err = a.Authenticate(info); err != nil {
+		au.logger.Printf("auth reject, action=%s, app=%s, stream=%s, remote=%s, err=%v\n",
+			info.Action, info.App, info.Stream, info.RemoteAddr, err)
+	}
+
+	return
+}
+
+// SplitQuery split the name and the query params, e.g. "test?token=abc" to "test" and token=abc.
+func SplitQuery(s string) (name string, params url.Values) {
+	name = s
+	params = url.Values{}
+
+	i := strings.Index(s, "?")
+	if i < 0 {
+		return
+	}
+
+	name = s[:i]
+	if v, err := url.ParseQuery(s[i+1:]); nil == err {
+		params = v
+	}
+
+	return
+}
+
+// MergeParams merge the params of src to dst, the params of dst take precedence.
+func MergeParams(dst url.Values, src url.Values) {
+	for k, v := range src {
+		if _, ok := dst[k]; !ok {
+			dst[k] = v
+		}
+	}
+}
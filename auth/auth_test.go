@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"seal/conf"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yaml"
+)
+
+func TestSecretAuthenticator(t *testing.T) {
+	a := NewSecretAuthenticator("abc")
+
+	for _, v := range []struct {
+		name   string
+		params url.Values
+		err    string
+	}{
+		{"match", url.Values{"token": {"abc"}}, ""},
+		{"mismatch", url.Values{"token": {"xyz"}}, "invalid token"},
+		{"prefix", url.Values{"token": {"ab"}}, "invalid token"},
+		{"missing", url.Values{"other": {"abc"}}, "no token"},
+		{"empty", url.Values{"token": {""}}, "no token"},
+	} {
+		err := a.Authenticate(&Info{Params: v.params})
+		if (0 == len(v.err)) != (nil == err) || (nil != err && v.err != err.Error()) {
+			t.Errorf("%s: err=%v, expect %s", v.name, err, v.err)
+		}
+	}
+}
+
+func TestHTTPAuthenticator(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var info Info
+		if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch info.Stream {
+		case "ok":
+		case "slow":
+			time.Sleep(1500 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("stream " + info.Stream + " is forbidden\n"))
+		}
+	}))
+	defer ts.Close()
+
+	a := NewHTTPAuthenticator(ts.URL, 1)
+
+	for _, v := range []struct {
+		stream string
+		err    string
+	}{
+		{"ok", ""},
+		{"bad", "status=403, reason=stream bad is forbidden"},
+		{"slow", "http callback failed"},
+	} {
+		err := a.Authenticate(&Info{Action: ActionPublish, App: "live", Stream: v.stream})
+		if (0 == len(v.err)) != (nil == err) || (nil != err && !strings.Contains(err.Error(), v.err)) {
+			t.Errorf("%s: err=%v, expect %s", v.stream, err, v.err)
+		}
+	}
+
+	// the server is down.
+	ts.Close()
+	if err := a.Authenticate(&Info{Stream: "ok"}); nil == err {
+		t.Error("the server is down, should reject")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c := &conf.Config{}
+	if err := yaml.Unmarshal([]byte(`
+auth:
+  type: secret
+  secret: abc
+  publish: "true"
+vhosts:
+  - name: v1
+    auth:
+      secret: v1
+      play: "true"
+    apps:
+      - name: open
+        auth:
+          type: none
+  - name: v2
+    auth:
+      secret: v1
+`), c); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	au := New(c)
+	au.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	for _, v := range []struct {
+		name   string
+		vhost  string
+		app    string
+		action string
+		token  string
+		accept bool
+	}{
+		{"global", conf.DefaultVhost, "live", ActionPublish, "abc", true},
+		{"global reject", conf.DefaultVhost, "live", ActionPublish, "v1", false},
+		{"not checked", conf.DefaultVhost, "live", ActionPlay, "", true},
+		{"vhost", "v1", "live", ActionPublish, "v1", true},
+		{"vhost reject", "v1", "live", ActionPublish, "abc", false},
+		{"vhost play", "v1", "live", ActionPlay, "", false},
+		{"app disabled", "v1", "open", ActionPublish, "", true},
+		{"same settings", "v2", "live", ActionPublish, "v1", true},
+	} {
+		err := au.Check(&Info{
+			Action: v.action,
+			Vhost:  v.vhost,
+			App:    v.app,
+			Stream: "test",
+			Params: url.Values{"token": {v.token}},
+		})
+		if v.accept != (nil == err) {
+			t.Errorf("%s: err=%v, expect accept=%v", v.name, err, v.accept)
+		}
+	}
+
+	// the authenticators of overrides are created once for the same settings.
+	if 2 != len(au.overrides) {
+		t.Errorf("overrides=%d, expect 2", len(au.overrides))
+	}
+
+	// cleared when reload.
+	if err := au.Reload(c); err != nil {
+		t.Fatal(err)
+	}
+	if 0 != len(au.overrides) {
+		t.Errorf("overrides=%d after reload, expect 0", len(au.overrides))
+	}
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// httpDefaultTimeoutSeconds the default timeout of the http callback.
+const httpDefaultTimeoutSeconds = 3
+
+// HTTPAuthenticator post the client info in json to the url,
+// accept the client if the response status is 200, otherwise reject it.
+type HTTPAuthenticator struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPAuthenticator create the http callback authenticator, timeout in seconds.
+func NewHTTPAuthenticator(url string, timeout uint32) *HTTPAuthenticator {
+	if 0 == timeout {
+		timeout = httpDefaultTimeoutSeconds
+	}
+
+	return &HTTPAuthenticator{
+		url: url,
+		client: &http.Client{
+			Timeout: time.Duration(timeout) * time.Second,
+		},
+	}
+}
+
+// Authenticate .
+func (a *HTTPAuthenticator) Authenticate(info *Info) (err error) {
+	var body []byte
+	if body, err = json.Marshal(info); err != nil {
+		return
+	}
+
+	var res *http.Response
+	if res, err = a.client.Post(a.url, "application/json", bytes.NewReader(body)); err != nil {
+		err = fmt.Errorf("http callback failed, err=%v", err)
+		return
+	}
+	defer res.Body.Close()
+
+	// the reason of reject, limit the size in case of the big response.
+	reason, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+
+	if http.StatusOK != res.StatusCode {
+		err = fmt.Errorf("http callback rejected, status=%d, reason=%s", res.StatusCode, bytes.TrimSpace(reason))
+		return
+	}
+
+	return
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+)
+
+// SecretAuthenticator accept the client whose token param equals to the secret,
+// e.g. rtmp://127.0.0.1/live/test?token=secret or rtmp://127.0.0.1/live?token=secret
+type SecretAuthenticator struct {
+	secret string
+}
+
+// NewSecretAuthenticator create the static secret authenticator.
+func NewSecretAuthenticator(secret string) *SecretAuthenticator {
+	return &SecretAuthenticator{
+		secret: secret,
+	}
+}
+
+// Authenticate .
+func (a *SecretAuthenticator) Authenticate(info *Info) (err error) {
+	token := info.Params.Get("token")
+	if 0 == len(token) {
+		err = fmt.Errorf("no token")
+		return
+	}
+
+	if 1 != subtle.ConstantTimeCompare([]byte(token), []byte(a.secret)) {
+		err = fmt.Errorf("invalid token")
+		return
+	}
+
+	return
+}
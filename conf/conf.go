@@ -4,16 +4,18 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"reflect"
 
 	"github.com/calabashdad/utiltools"
 	"github.com/yaml"
 )
 
-// GlobalConfInfo global config info
-var GlobalConfInfo confInfo
+// GlobalConfInfo global config info, loaded from the config file by main.
+var GlobalConfInfo Config
 
 type systemConfInfo struct {
-	CPUNums uint32 `yaml:"cpuNums"`
+	CPUNums         uint32 `yaml:"cpuNums"`
+	ShutdownTimeout uint32 `yaml:"shutdownTimeout"`
 }
 
 type rtmpConfInfo struct {
@@ -24,6 +26,9 @@ type rtmpConfInfo struct {
 	AtcAuto           bool   `yaml:"atcAuto"`
 	TimeJitter        uint32 `yaml:"timeJitter"`
 	ConsumerQueueSize uint32 `yaml:"consumerQueueSize"`
+	TLSListen         string `yaml:"tlsListen"`
+	TLSCert           string `yaml:"tlsCert"`
+	TLSKey            string `yaml:"tlsKey"`
 }
 
 type hlsConfInfo struct {
@@ -32,15 +37,159 @@ type hlsConfInfo struct {
 	HlsWindow   int    `yaml:"hlsWindow"`
 	HlsPath     string `yaml:"hlsPath"`
 	HttpListen  string `yaml:"httpListen"`
+	Rtmpt       string `yaml:"rtmpt"`
 }
 
-type confInfo struct {
-	System systemConfInfo `yaml:"system"`
-	Rtmp   rtmpConfInfo   `yaml:"rtmp"`
-	Hls    hlsConfInfo    `yaml:"hls"`
+type ingestConfInfo struct {
+	URL    string `yaml:"url"`
+	Stream string `yaml:"stream"`
 }
 
-func (t *confInfo) Loads(c string) (err error) {
+type forwardConfInfo struct {
+	App          string   `yaml:"app"`
+	Destinations []string `yaml:"destinations"`
+}
+
+type edgeConfInfo struct {
+	Enable      string   `yaml:"enable"`
+	Origins     []string `yaml:"origins"`
+	IdleTimeout uint32   `yaml:"idleTimeout"`
+}
+
+type gopCacheConfInfo struct {
+	App         string `yaml:"app"`
+	Enable      string `yaml:"enable"`
+	Gops        uint32 `yaml:"gops"`
+	MaxDuration uint32 `yaml:"maxDuration"`
+	MaxBytes    uint64 `yaml:"maxBytes"`
+	MaxFrames   uint32 `yaml:"maxFrames"`
+}
+
+type authConfInfo struct {
+	Type        string `yaml:"type"`
+	Connect     string `yaml:"connect"`
+	Publish     string `yaml:"publish"`
+	Play        string `yaml:"play"`
+	Secret      string `yaml:"secret"`
+	HTTPURL     string `yaml:"httpUrl"`
+	HTTPTimeout uint32 `yaml:"httpTimeout"`
+}
+
+type hooksConfInfo struct {
+	OnConnect    []string `yaml:"onConnect"`
+	OnPublish    []string `yaml:"onPublish"`
+	OnUnpublish  []string `yaml:"onUnpublish"`
+	OnPlay       []string `yaml:"onPlay"`
+	OnStop       []string `yaml:"onStop"`
+	OnHlsSegment []string `yaml:"onHlsSegment"`
+	OnClose      []string `yaml:"onClose"`
+	Timeout      uint32   `yaml:"timeout"`
+	Retry        uint32   `yaml:"retry"`
+	QueueSize    uint32   `yaml:"queueSize"`
+}
+
+type statsConfInfo struct {
+	Listen string `yaml:"listen"`
+	Host   string `yaml:"host"`
+	Token  string `yaml:"token"`
+}
+
+// rtmpOverrideConfInfo the rtmp settings which can be overridden by vhost and app.
+type rtmpOverrideConfInfo struct {
+	Atc               *bool  `yaml:"atc,omitempty"`
+	AtcAuto           *bool  `yaml:"atcAuto,omitempty"`
+	TimeJitter        uint32 `yaml:"timeJitter,omitempty"`
+	ConsumerQueueSize uint32 `yaml:"consumerQueueSize,omitempty"`
+}
+
+// hlsOverrideConfInfo the hls settings which can be overridden by vhost and app.
+type hlsOverrideConfInfo struct {
+	Enable      string `yaml:"enable,omitempty"`
+	HlsFragment int    `yaml:"hlsFragment,omitempty"`
+	HlsWindow   int    `yaml:"hlsWindow,omitempty"`
+	HlsPath     string `yaml:"hlsPath,omitempty"`
+}
+
+// overrideConfInfo the settings of vhost or app which override the global, the empty use the global.
+type overrideConfInfo struct {
+	Rtmp rtmpOverrideConfInfo `yaml:"rtmp,omitempty"`
+	Hls  hlsOverrideConfInfo  `yaml:"hls,omitempty"`
+	Auth authConfInfo         `yaml:"auth,omitempty"`
+}
+
+type appConfInfo struct {
+	Name             string `yaml:"name"`
+	overrideConfInfo `yaml:",inline"`
+}
+
+// vhostConfInfo the settings of vhost, the gopCache and forward are the entries of apps as the global,
+// which are not in the app sections.
+type vhostConfInfo struct {
+	Name             string `yaml:"name"`
+	overrideConfInfo `yaml:",inline"`
+	GopCache         []gopCacheConfInfo `yaml:"gopCache,omitempty"`
+	Forward          []forwardConfInfo  `yaml:"forward,omitempty"`
+	Apps             []appConfInfo      `yaml:"apps"`
+}
+
+// Config the config of server, loads from yaml file.
+type Config struct {
+	System   systemConfInfo     `yaml:"system"`
+	Rtmp     rtmpConfInfo       `yaml:"rtmp"`
+	Hls      hlsConfInfo        `yaml:"hls"`
+	Ingest   []ingestConfInfo   `yaml:"ingest"`
+	Forward  []forwardConfInfo  `yaml:"forward"`
+	Edge     edgeConfInfo       `yaml:"edge"`
+	GopCache []gopCacheConfInfo `yaml:"gopCache"`
+	Auth     authConfInfo       `yaml:"auth"`
+	Hooks    hooksConfInfo      `yaml:"hooks"`
+	Stats    statsConfInfo      `yaml:"stats"`
+	Vhosts   []vhostConfInfo    `yaml:"vhosts"`
+}
+
+// restartSetting the setting which can not be applied live, keep copies the old value back.
+type restartSetting struct {
+	name    string
+	changed bool
+	keep    func()
+}
+
+// restartSettings the settings which can not be applied live, compared with old.
+func (t *Config) restartSettings(old *Config) []restartSetting {
+	return []restartSetting{
+		{"system.cpuNums", t.System.CPUNums != old.System.CPUNums, func() { t.System.CPUNums = old.System.CPUNums }},
+		{"rtmp.listen", t.Rtmp.Listen != old.Rtmp.Listen, func() { t.Rtmp.Listen = old.Rtmp.Listen }},
+		{"rtmp.tlsListen", t.Rtmp.TLSListen != old.Rtmp.TLSListen, func() { t.Rtmp.TLSListen = old.Rtmp.TLSListen }},
+		{"rtmp.tlsCert", t.Rtmp.TLSCert != old.Rtmp.TLSCert, func() { t.Rtmp.TLSCert = old.Rtmp.TLSCert }},
+		{"rtmp.tlsKey", t.Rtmp.TLSKey != old.Rtmp.TLSKey, func() { t.Rtmp.TLSKey = old.Rtmp.TLSKey }},
+		// the http server is started when start if hls is enabled, disabling hls is applied live to the new streams.
+		{"hls.enable", "true" == t.Hls.Enable && "true" != old.Hls.Enable, func() { t.Hls.Enable = old.Hls.Enable }},
+		{"hls.httpListen", t.Hls.HttpListen != old.Hls.HttpListen, func() { t.Hls.HttpListen = old.Hls.HttpListen }},
+		{"hls.rtmpt", t.Hls.Rtmpt != old.Hls.Rtmpt, func() { t.Hls.Rtmpt = old.Hls.Rtmpt }},
+		{"ingest", !reflect.DeepEqual(t.Ingest, old.Ingest), func() { t.Ingest = old.Ingest }},
+		{"hooks.queueSize", t.Hooks.QueueSize != old.Hooks.QueueSize, func() { t.Hooks.QueueSize = old.Hooks.QueueSize }},
+		{"stats.listen", t.Stats.Listen != old.Stats.Listen, func() { t.Stats.Listen = old.Stats.Listen }},
+		{"stats.host", t.Stats.Host != old.Stats.Host, func() { t.Stats.Host = old.Stats.Host }},
+	}
+}
+
+// KeepRestartRequired keep the old values of the settings changed which can not be applied live,
+// e.g. the listen ports, they take effect after restart, so the config reloaded is the same as
+// the running one, return the names of the settings changed.
+func (t *Config) KeepRestartRequired(old *Config) (names []string) {
+	for _, v := range t.restartSettings(old) {
+		if v.changed {
+			names = append(names, v.name)
+			v.keep()
+		}
+	}
+
+	return
+}
+
+// Loads loads the config from yaml file c, override by the environment variables,
+// then fill the defaults and validate it.
+func (t *Config) Loads(c string) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
 			log.Println(utiltools.PanicTrace())
@@ -66,5 +215,14 @@ func (t *confInfo) Loads(c string) (err error) {
 		return err
 	}
 
+	if err = t.LoadEnv(); err != nil {
+		log.Println("config env override failed, ", err.Error())
+		return err
+	}
+
+	if err = t.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
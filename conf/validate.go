@@ -0,0 +1,413 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yaml"
+)
+
+// the defaults of config, used when the setting is empty or 0.
+const (
+	defaultShutdownTimeout = 30
+	defaultRtmpListen      = "1935"
+	defaultRtmpTimeout     = 30
+	defaultRtmpChunkSize   = 60000
+	defaultRtmpTimeJitter  = 1
+	defaultHlsFragment     = 4
+	defaultHlsWindow       = 20
+	defaultHlsPath         = "./html"
+	defaultHlsHTTPListen   = "7001"
+	defaultEdgeIdleTimeout = 10
+	defaultGopCacheGops    = 1
+	defaultHooksTimeout    = 3
+	defaultHooksRetry      = 3
+	defaultHooksQueueSize  = 1024
+	defaultStatsHost       = "127.0.0.1"
+)
+
+const (
+	minRtmpChunkSize  = 128
+	maxRtmpChunkSize  = 65535
+	maxRtmpTimeJitter = 3
+	maxPort           = 65535
+	// envPrefix the prefix of environment variables to override the settings.
+	envPrefix = "SEAL"
+)
+
+// Validate fill the defaults of the empty settings, and check the settings,
+// return the error of all the invalid settings.
+func (t *Config) Validate() (err error) {
+	var errs []string
+	invalid := func(format string, v ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, v...))
+	}
+
+	if 0 == t.System.ShutdownTimeout {
+		t.System.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	if true {
+		r := &t.Rtmp
+
+		if 0 == len(r.Listen) {
+			r.Listen = defaultRtmpListen
+		}
+		if 0 == r.TimeOut {
+			r.TimeOut = defaultRtmpTimeout
+		}
+		if 0 == r.ChunkSize {
+			r.ChunkSize = defaultRtmpChunkSize
+		}
+		if 0 == r.TimeJitter {
+			r.TimeJitter = defaultRtmpTimeJitter
+		}
+
+		if r.ChunkSize < minRtmpChunkSize || r.ChunkSize > maxRtmpChunkSize {
+			invalid("rtmp.chunkSize=%d out of range [%d, %d]", r.ChunkSize, minRtmpChunkSize, maxRtmpChunkSize)
+		}
+		if 0 != len(r.TLSListen) && (0 == len(r.TLSCert) || 0 == len(r.TLSKey)) {
+			invalid("rtmp.tlsCert and rtmp.tlsKey are required when rtmp.tlsListen=%s", r.TLSListen)
+		}
+	}
+
+	if true {
+		h := &t.Hls
+
+		if 0 == h.HlsFragment {
+			h.HlsFragment = defaultHlsFragment
+		}
+		if 0 == h.HlsWindow {
+			h.HlsWindow = defaultHlsWindow
+		}
+		if 0 == len(h.HlsPath) {
+			h.HlsPath = defaultHlsPath
+		}
+		if 0 == len(h.HttpListen) {
+			h.HttpListen = defaultHlsHTTPListen
+		}
+
+		defaultSwitch(&h.Enable, "false")
+		defaultSwitch(&h.Rtmpt, "false")
+
+		checkSwitch(h.Rtmpt, "hls.rtmpt", invalid)
+	}
+
+	// the ports must be valid and not conflict, 0 to listen at a random port.
+	ports := make(map[string]string)
+	for _, v := range []struct {
+		name, port string
+		enable     bool
+	}{
+		{"rtmp.listen", t.Rtmp.Listen, true},
+		{"rtmp.tlsListen", t.Rtmp.TLSListen, 0 != len(t.Rtmp.TLSListen)},
+		{"hls.httpListen", t.Hls.HttpListen, "true" == t.Hls.Enable},
+		{"stats.listen", t.Stats.Listen, 0 != len(t.Stats.Listen)},
+	} {
+		if !v.enable {
+			continue
+		}
+
+		if n, e := strconv.Atoi(v.port); e != nil || n < 0 || n > maxPort {
+			invalid("%s=%s must be a port number in [0, %d]", v.name, v.port, maxPort)
+			continue
+		}
+
+		if other, ok := ports[v.port]; ok && "0" != v.port {
+			invalid("%s=%s conflicts with %s", v.name, v.port, other)
+		}
+		ports[v.port] = v.name
+	}
+
+	for i, v := range t.Ingest {
+		if e := checkRtmpURL(v.URL, true); e != nil {
+			invalid("ingest[%d].url=%s %v", i, v.URL, e)
+		}
+		if n := len(strings.Split(v.Stream, "/")); 0 != len(v.Stream) && 2 != n && 3 != n {
+			invalid("ingest[%d].stream=%s must be [vhost/]app/stream", i, v.Stream)
+		}
+	}
+
+	if true {
+		e := &t.Edge
+
+		if 0 == e.IdleTimeout {
+			e.IdleTimeout = defaultEdgeIdleTimeout
+		}
+
+		defaultSwitch(&e.Enable, "false")
+		checkSwitch(e.Enable, "edge.enable", invalid)
+
+		if "true" == e.Enable && 0 == len(e.Origins) {
+			invalid("edge.origins is required when edge.enable=true")
+		}
+	}
+
+	defaultGopCache(t.GopCache)
+
+	if true {
+		a := &t.Auth
+
+		defaultSwitch(&a.Connect, "false")
+		defaultSwitch(&a.Publish, "false")
+		defaultSwitch(&a.Play, "false")
+	}
+
+	t.checkStream("", invalid)
+
+	// the vhost and app sections are checked with the global they override.
+	vhosts := make(map[string]bool)
+	for i := range t.Vhosts {
+		v := &t.Vhosts[i]
+
+		if 0 == len(v.Name) {
+			invalid("vhosts[%d].name is required", i)
+			continue
+		}
+		if vhosts[strings.ToLower(v.Name)] {
+			invalid("vhosts[%d].name=%s is duplicated", i, v.Name)
+			continue
+		}
+		vhosts[strings.ToLower(v.Name)] = true
+
+		defaultGopCache(v.GopCache)
+		t.StreamConfig(v.Name, "").checkStream("vhost="+v.Name+" ", invalid)
+
+		apps := make(map[string]bool)
+		for j := range v.Apps {
+			a := &v.Apps[j]
+
+			if 0 == len(a.Name) || apps[a.Name] {
+				invalid("vhost=%s apps[%d].name=%s is empty or duplicated", v.Name, j, a.Name)
+				continue
+			}
+			apps[a.Name] = true
+
+			t.StreamConfig(v.Name, a.Name).checkStream("vhost="+v.Name+" app="+a.Name+" ", invalid)
+		}
+	}
+
+	if true {
+		h := &t.Hooks
+
+		if 0 == h.Timeout {
+			h.Timeout = defaultHooksTimeout
+		}
+		if 0 == h.Retry {
+			h.Retry = defaultHooksRetry
+		}
+		if 0 == h.QueueSize {
+			h.QueueSize = defaultHooksQueueSize
+		}
+
+		for _, v := range []struct {
+			name string
+			urls []string
+		}{
+			{"onConnect", h.OnConnect},
+			{"onPublish", h.OnPublish},
+			{"onUnpublish", h.OnUnpublish},
+			{"onPlay", h.OnPlay},
+			{"onStop", h.OnStop},
+			{"onHlsSegment", h.OnHlsSegment},
+			{"onClose", h.OnClose},
+		} {
+			for i, u := range v.urls {
+				if _, e := url.ParseRequestURI(u); e != nil {
+					invalid("hooks.%s[%d]=%s is invalid", v.name, i, u)
+				}
+			}
+		}
+	}
+
+	if true {
+		s := &t.Stats
+
+		if 0 == len(s.Host) {
+			s.Host = defaultStatsHost
+		}
+
+		// the admin api can disconnect clients and drop streams, only the local clients can access without token.
+		if ip := net.ParseIP(s.Host); 0 != len(s.Listen) && 0 == len(s.Token) &&
+			"localhost" != s.Host && (nil == ip || !ip.IsLoopback()) {
+			invalid("stats.token is required when stats.host=%s is not loopback", s.Host)
+		}
+	}
+
+	if 0 != len(errs) {
+		err = errors.New("invalid config: " + strings.Join(errs, "; "))
+	}
+
+	return
+}
+
+// checkStream check the settings of streams, which can be overridden by vhost and app,
+// the name of settings in error is prefixed by scope.
+func (t *Config) checkStream(scope string, invalid func(format string, v ...interface{})) {
+	if t.Rtmp.TimeJitter > maxRtmpTimeJitter {
+		invalid("%srtmp.timeJitter=%d must be 1(full), 2(zero) or 3(off)", scope, t.Rtmp.TimeJitter)
+	}
+
+	if true {
+		h := &t.Hls
+
+		checkSwitch(h.Enable, scope+"hls.enable", invalid)
+
+		if h.HlsFragment < 0 {
+			invalid("%shls.hlsFragment=%d must be positive", scope, h.HlsFragment)
+		}
+		if h.HlsWindow < h.HlsFragment {
+			invalid("%shls.hlsWindow=%d must not be less than hls.hlsFragment=%d", scope, h.HlsWindow, h.HlsFragment)
+		}
+	}
+
+	for i, v := range t.Forward {
+		if 0 == len(v.App) {
+			invalid("%sforward[%d].app is required", scope, i)
+		}
+		if 0 == len(v.Destinations) {
+			invalid("%sforward[%d].destinations is required", scope, i)
+		}
+		for j, d := range v.Destinations {
+			if e := checkRtmpURL(d, false); e != nil {
+				invalid("%sforward[%d].destinations[%d]=%s %v", scope, i, j, d, e)
+			}
+		}
+	}
+
+	for i, v := range t.GopCache {
+		checkSwitch(v.Enable, fmt.Sprintf("%sgopCache[%d].enable", scope, i), invalid)
+	}
+
+	if true {
+		a := &t.Auth
+
+		checkSwitch(a.Connect, scope+"auth.connect", invalid)
+		checkSwitch(a.Publish, scope+"auth.publish", invalid)
+		checkSwitch(a.Play, scope+"auth.play", invalid)
+
+		switch a.Type {
+		case "", "none":
+		case "secret":
+			if 0 == len(a.Secret) {
+				invalid("%sauth.secret is required when auth.type=secret", scope)
+			}
+		case "http":
+			if _, e := url.ParseRequestURI(a.HTTPURL); e != nil {
+				invalid("%sauth.httpUrl=%s is invalid when auth.type=http", scope, a.HTTPURL)
+			}
+		default:
+			invalid("%sauth.type=%s must be none, secret or http", scope, a.Type)
+		}
+	}
+}
+
+// defaultGopCache fill the defaults of the gop cache entries.
+func defaultGopCache(entries []gopCacheConfInfo) {
+	for i := range entries {
+		g := &entries[i]
+
+		if 0 == g.Gops {
+			g.Gops = defaultGopCacheGops
+		}
+
+		defaultSwitch(&g.Enable, "true")
+	}
+}
+
+// defaultSwitch fill the default of the switch if empty.
+func defaultSwitch(v *string, def string) {
+	if 0 == len(*v) {
+		*v = def
+	}
+}
+
+// checkSwitch the switch must be true or false.
+func checkSwitch(v string, name string, invalid func(format string, v ...interface{})) {
+	if "true" != v && "false" != v {
+		invalid("%s=%s must be true or false", name, v)
+	}
+}
+
+// checkRtmpURL check the url is rtmp://host[:port]/app/stream if full, otherwise rtmp://host[:port][/app].
+func checkRtmpURL(s string, full bool) (err error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return
+	}
+
+	if !full {
+		if "rtmp" != u.Scheme || 0 == len(u.Host) {
+			err = errors.New("must be rtmp://host[:port][/app]")
+		}
+		return
+	}
+
+	p := strings.Trim(u.Path, "/")
+	i := strings.LastIndex(p, "/")
+	if "rtmp" != u.Scheme || 0 == len(u.Host) || i <= 0 || i == len(p)-1 {
+		err = errors.New("must be rtmp://host[:port]/app/stream")
+	}
+
+	return
+}
+
+// LoadEnv override the settings by the environment variables, the name of the setting
+// is SEAL_ and the path of yaml keys in upper snake case, the value is in yaml,
+// e.g. SEAL_RTMP_CHUNK_SIZE=4096, SEAL_HLS_ENABLE=false, SEAL_EDGE_ORIGINS="[127.0.0.1:19350]",
+// SEAL_INGEST="[{url: rtmp://127.0.0.1/live/test, stream: live/test}]".
+func (t *Config) LoadEnv() (err error) {
+	return loadEnv(reflect.ValueOf(t).Elem(), envPrefix)
+}
+
+// loadEnv override the fields of struct v by the environment variables of prefix.
+func loadEnv(v reflect.Value, prefix string) (err error) {
+	for i := 0; i < v.NumField(); i++ {
+		tag := v.Type().Field(i).Tag.Get("yaml")
+		if 0 == len(tag) {
+			continue
+		}
+
+		name := prefix + "_" + envName(tag)
+		field := v.Field(i)
+
+		if reflect.Struct == field.Kind() {
+			if err = loadEnv(field, name); err != nil {
+				return
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		if err = yaml.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+			return fmt.Errorf("env %s=%s is invalid, err=%v", name, value, err)
+		}
+	}
+
+	return
+}
+
+// envName the upper snake case of the yaml key, e.g. chunkSize to CHUNK_SIZE.
+func envName(key string) string {
+	var b strings.Builder
+
+	for i, r := range key {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteString("_")
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,177 @@
+package conf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yaml"
+)
+
+func TestValidateDefaults(t *testing.T) {
+	c := &Config{}
+	if err := yaml.Unmarshal([]byte(`
+rtmp:
+  chunkSize: 4096
+gopCache:
+  - app: live
+stats:
+  listen: "7002"
+`), c); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		name   string
+		got    interface{}
+		expect interface{}
+	}{
+		{"system.shutdownTimeout", c.System.ShutdownTimeout, uint32(defaultShutdownTimeout)},
+		{"rtmp.listen", c.Rtmp.Listen, defaultRtmpListen},
+		{"rtmp.timeout", c.Rtmp.TimeOut, uint32(defaultRtmpTimeout)},
+		{"rtmp.chunkSize", c.Rtmp.ChunkSize, uint32(4096)},
+		{"rtmp.timeJitter", c.Rtmp.TimeJitter, uint32(defaultRtmpTimeJitter)},
+		{"hls.enable", c.Hls.Enable, "false"},
+		{"hls.hlsFragment", c.Hls.HlsFragment, defaultHlsFragment},
+		{"hls.hlsWindow", c.Hls.HlsWindow, defaultHlsWindow},
+		{"hls.hlsPath", c.Hls.HlsPath, defaultHlsPath},
+		{"hls.httpListen", c.Hls.HttpListen, defaultHlsHTTPListen},
+		{"edge.enable", c.Edge.Enable, "false"},
+		{"edge.idleTimeout", c.Edge.IdleTimeout, uint32(defaultEdgeIdleTimeout)},
+		{"gopCache[0].enable", c.GopCache[0].Enable, "true"},
+		{"gopCache[0].gops", c.GopCache[0].Gops, uint32(defaultGopCacheGops)},
+		{"auth.publish", c.Auth.Publish, "false"},
+		{"hooks.queueSize", c.Hooks.QueueSize, uint32(defaultHooksQueueSize)},
+		{"stats.host", c.Stats.Host, "127.0.0.1"},
+	} {
+		if !reflect.DeepEqual(v.expect, v.got) {
+			t.Errorf("%s=%v, expect %v", v.name, v.got, v.expect)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, v := range []struct {
+		name string
+		conf string
+		err  string // the invalid setting in error, empty if valid.
+	}{
+		{"empty", "", ""},
+		{"chunk size min", "rtmp: {chunkSize: 128}", ""},
+		{"chunk size max", "rtmp: {chunkSize: 65535}", ""},
+		{"chunk size too small", "rtmp: {chunkSize: 127}", "rtmp.chunkSize=127"},
+		{"chunk size too large", "rtmp: {chunkSize: 65536}", "rtmp.chunkSize=65536"},
+		{"tls without cert", "rtmp: {tlsListen: '1936'}", "rtmp.tlsCert"},
+		{"port not number", "rtmp: {listen: ':1935'}", "rtmp.listen=:1935"},
+		{"port out of range", "rtmp: {listen: '65536'}", "rtmp.listen=65536"},
+		{"port conflicts", "hls: {enable: 'true', httpListen: '1935'}", "hls.httpListen=1935 conflicts with rtmp.listen"},
+		{"port of disabled", "hls: {enable: 'false', httpListen: '1935'}", ""},
+		{"random ports", "rtmp: {listen: '0'}\nstats: {listen: '0'}", ""},
+		{"switch", "hls: {rtmpt: 'yes'}", "hls.rtmpt=yes"},
+		{"ingest url", "ingest: [{url: 'rtmp://127.0.0.1/live', stream: live/test}]", "ingest[0].url"},
+		{"ingest url scheme", "ingest: [{url: 'http://127.0.0.1/live/test'}]", "ingest[0].url"},
+		{"ingest stream", "ingest: [{url: 'rtmp://127.0.0.1/live/test', stream: test}]", "ingest[0].stream=test"},
+		{"ingest of vhost", "ingest: [{url: 'rtmp://127.0.0.1/live/test', stream: v1/live/test}]", ""},
+		{"forward destination", "forward: [{app: live, destinations: ['127.0.0.1:19350']}]", "forward[0].destinations[0]"},
+		{"edge without origins", "edge: {enable: 'true'}", "edge.origins"},
+		{"hooks url", "hooks: {onPublish: ['127.0.0.1/hook']}", "hooks.onPublish[0]"},
+		{"auth type", "auth: {type: token}", "auth.type=token"},
+		{"auth secret", "auth: {type: secret}", "auth.secret"},
+		{"auth http url", "auth: {type: http, httpUrl: 'auth'}", "auth.httpUrl=auth"},
+		{"auth http", "auth: {type: http, httpUrl: 'http://127.0.0.1/auth', play: 'true'}", ""},
+		{"time jitter", "rtmp: {timeJitter: 4}", "rtmp.timeJitter=4"},
+		{"hls window", "hls: {hlsFragment: 10, hlsWindow: 5}", "hls.hlsWindow=5"},
+		{"stats loopback", "stats: {listen: '7002', host: '::1'}", ""},
+		{"stats localhost", "stats: {listen: '7002', host: localhost}", ""},
+		{"stats public", "stats: {listen: '7002', host: 0.0.0.0}", "stats.token"},
+		{"stats public with token", "stats: {listen: '7002', host: 0.0.0.0, token: abc}", ""},
+	} {
+		c := &Config{}
+		if err := yaml.Unmarshal([]byte(v.conf), c); err != nil {
+			t.Fatal(err)
+		}
+
+		err := c.Validate()
+		if 0 == len(v.err) {
+			if err != nil {
+				t.Errorf("%s: should be valid, err=%v", v.name, err)
+			}
+			continue
+		}
+
+		if nil == err || !strings.Contains(err.Error(), v.err) {
+			t.Errorf("%s: err=%v, expect %s", v.name, err, v.err)
+		}
+	}
+
+	// all the invalid settings are in the error.
+	c := &Config{}
+	c.Rtmp.ChunkSize = 1
+	c.Auth.Type = "token"
+	if err := c.Validate(); nil == err || !strings.Contains(err.Error(), "rtmp.chunkSize") || !strings.Contains(err.Error(), "auth.type") {
+		t.Errorf("err=%v, expect rtmp.chunkSize and auth.type", err)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("SEAL_RTMP_CHUNK_SIZE", "4096")
+	t.Setenv("SEAL_RTMP_ATC", "true")
+	t.Setenv("SEAL_HLS_ENABLE", "false")
+	t.Setenv("SEAL_EDGE_ORIGINS", "[127.0.0.1:19350, 127.0.0.1:19351]")
+	t.Setenv("SEAL_INGEST", "[{url: rtmp://127.0.0.1/live/test, stream: live/ingest}]")
+	t.Setenv("SEAL_STATS_TOKEN", "abc")
+
+	c := &Config{}
+	if err := yaml.Unmarshal([]byte(`
+rtmp:
+  listen: "1935"
+  chunkSize: 60000
+hls:
+  enable: "true"
+stats:
+  token: xyz
+`), c); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.LoadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the settings without env are kept.
+	if "1935" != c.Rtmp.Listen || 4096 != c.Rtmp.ChunkSize || !c.Rtmp.Atc || "false" != c.Hls.Enable || "abc" != c.Stats.Token {
+		t.Errorf("rtmp=%+v, hls=%+v, stats=%+v", c.Rtmp, c.Hls, c.Stats)
+	}
+
+	if !reflect.DeepEqual([]string{"127.0.0.1:19350", "127.0.0.1:19351"}, c.Edge.Origins) {
+		t.Errorf("edge.origins=%v", c.Edge.Origins)
+	}
+
+	if 1 != len(c.Ingest) || "rtmp://127.0.0.1/live/test" != c.Ingest[0].URL || "live/ingest" != c.Ingest[0].Stream {
+		t.Errorf("ingest=%+v", c.Ingest)
+	}
+
+	// the invalid value is an error.
+	t.Setenv("SEAL_RTMP_CHUNK_SIZE", "abc")
+	if err := c.LoadEnv(); nil == err || !strings.Contains(err.Error(), "SEAL_RTMP_CHUNK_SIZE") {
+		t.Errorf("err=%v, expect SEAL_RTMP_CHUNK_SIZE invalid", err)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for k, v := range map[string]string{
+		"listen":            "LISTEN",
+		"chunkSize":         "CHUNK_SIZE",
+		"httpUrl":           "HTTP_URL",
+		"consumerQueueSize": "CONSUMER_QUEUE_SIZE",
+		"onHlsSegment":      "ON_HLS_SEGMENT",
+	} {
+		if name := envName(k); v != name {
+			t.Errorf("env name of %s is %s, expect %s", k, name, v)
+		}
+	}
+}
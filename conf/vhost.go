@@ -0,0 +1,125 @@
+package conf
+
+import (
+	"path"
+	"strings"
+)
+
+// DefaultVhost the vhost of the clients whose host is not configured in vhosts,
+// also used as the name of vhost section to override the settings of apps of the default vhost.
+const DefaultVhost = "__defaultVhost__"
+
+// Vhost the vhost configured of the host, e.g. the host of tcUrl or the param vhost,
+// DefaultVhost if not configured.
+func (t *Config) Vhost(host string) string {
+	if v := t.findVhost(host); nil != v {
+		return v.Name
+	}
+
+	return DefaultVhost
+}
+
+func (t *Config) findVhost(name string) *vhostConfInfo {
+	for i := range t.Vhosts {
+		if strings.EqualFold(t.Vhosts[i].Name, name) {
+			return &t.Vhosts[i]
+		}
+	}
+
+	return nil
+}
+
+func (v *vhostConfInfo) findApp(name string) *appConfInfo {
+	for i := range v.Apps {
+		if v.Apps[i].Name == name {
+			return &v.Apps[i]
+		}
+	}
+
+	return nil
+}
+
+// StreamConfig the config of the streams of vhost and app, the global overridden by the vhost and app sections.
+// the hls files of vhost are in the sub dir of vhost in the global hlsPath, unless hlsPath is overridden,
+// so that the vhosts can use the same app and stream.
+// notice that the config returned is shared, must not be changed.
+func (t *Config) StreamConfig(vhost string, app string) *Config {
+	v := t.findVhost(vhost)
+	if nil == v {
+		return t
+	}
+
+	c := *t
+	v.apply(&c)
+
+	if 0 != len(v.GopCache) {
+		c.GopCache = v.GopCache
+	}
+
+	if 0 != len(v.Forward) {
+		c.Forward = v.Forward
+	}
+
+	if a := v.findApp(app); nil != a {
+		a.apply(&c)
+	}
+
+	if DefaultVhost != v.Name && c.Hls.HlsPath == t.Hls.HlsPath {
+		c.Hls.HlsPath = path.Join(t.Hls.HlsPath, v.Name)
+	}
+
+	return &c
+}
+
+// apply override the settings of c by the section.
+func (o *overrideConfInfo) apply(c *Config) {
+	overrideRtmp(&c.Rtmp, &o.Rtmp)
+	overrideHls(&c.Hls, &o.Hls)
+	overrideAuth(&c.Auth, &o.Auth)
+}
+
+func overrideRtmp(c *rtmpConfInfo, o *rtmpOverrideConfInfo) {
+	if nil != o.Atc {
+		c.Atc = *o.Atc
+	}
+	if nil != o.AtcAuto {
+		c.AtcAuto = *o.AtcAuto
+	}
+	overrideUint32(&c.TimeJitter, o.TimeJitter)
+	overrideUint32(&c.ConsumerQueueSize, o.ConsumerQueueSize)
+}
+
+func overrideHls(c *hlsConfInfo, o *hlsOverrideConfInfo) {
+	overrideString(&c.Enable, o.Enable)
+	overrideInt(&c.HlsFragment, o.HlsFragment)
+	overrideInt(&c.HlsWindow, o.HlsWindow)
+	overrideString(&c.HlsPath, o.HlsPath)
+}
+
+func overrideAuth(c *authConfInfo, o *authConfInfo) {
+	overrideString(&c.Type, o.Type)
+	overrideString(&c.Connect, o.Connect)
+	overrideString(&c.Publish, o.Publish)
+	overrideString(&c.Play, o.Play)
+	overrideString(&c.Secret, o.Secret)
+	overrideString(&c.HTTPURL, o.HTTPURL)
+	overrideUint32(&c.HTTPTimeout, o.HTTPTimeout)
+}
+
+func overrideString(v *string, o string) {
+	if 0 != len(o) {
+		*v = o
+	}
+}
+
+func overrideInt(v *int, o int) {
+	if 0 != o {
+		*v = o
+	}
+}
+
+func overrideUint32(v *uint32, o uint32) {
+	if 0 != o {
+		*v = o
+	}
+}
@@ -0,0 +1,163 @@
+package conf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yaml"
+)
+
+// testVhosts the config of the default vhost, tenant1 and tenant2, the apps of tenant1 override it.
+const testVhosts = `
+rtmp:
+  timeJitter: 1
+hls:
+  enable: "false"
+  hlsFragment: 5
+  hlsWindow: 30
+  hlsPath: ./html
+gopCache:
+  - app: live
+    gops: 2
+forward:
+  - app: live
+    destinations: [rtmp://127.0.0.1:19350]
+auth:
+  type: none
+vhosts:
+  - name: Tenant1.example.com
+    rtmp:
+      atc: true
+      timeJitter: 3
+    hls:
+      enable: "true"
+    gopCache:
+      - app: live
+        enable: "false"
+    forward:
+      - app: backup
+        destinations: [rtmp://127.0.0.1:19351]
+    auth:
+      type: secret
+      secret: s1
+    apps:
+      - name: low
+        rtmp:
+          atc: false
+          timeJitter: 2
+        hls:
+          hlsFragment: 2
+          hlsPath: /data/low
+        auth:
+          secret: s2
+  - name: tenant2.example.com
+`
+
+func TestStreamConfig(t *testing.T) {
+	c := &Config{}
+	if err := yaml.Unmarshal([]byte(testVhosts), c); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	type expect struct {
+		atc        bool
+		timeJitter uint32
+		hlsEnable  string
+		fragment   int
+		hlsPath    string
+		gopApp     string
+		gopEnable  string
+		forwardApp string
+		authType   string
+		secret     string
+	}
+
+	for _, v := range []struct {
+		name  string
+		vhost string
+		app   string
+		expect
+	}{
+		{"default vhost", DefaultVhost, "live", expect{false, 1, "false", 5, "./html", "live", "true", "live", "none", ""}},
+		{"not configured", "unknown.example.com", "live", expect{false, 1, "false", 5, "./html", "live", "true", "live", "none", ""}},
+		{"vhost", "tenant1.example.com", "live", expect{true, 3, "true", 5, "html/Tenant1.example.com", "live", "false", "backup", "secret", "s1"}},
+		{"app", "tenant1.example.com", "low", expect{false, 2, "true", 2, "/data/low", "live", "false", "backup", "secret", "s2"}},
+		{"vhost without settings", "tenant2.example.com", "live", expect{false, 1, "false", 5, "html/tenant2.example.com", "live", "true", "live", "none", ""}},
+	} {
+		sc := c.StreamConfig(c.Vhost(v.vhost), v.app)
+
+		got := expect{
+			atc:        sc.Rtmp.Atc,
+			timeJitter: sc.Rtmp.TimeJitter,
+			hlsEnable:  sc.Hls.Enable,
+			fragment:   sc.Hls.HlsFragment,
+			hlsPath:    sc.Hls.HlsPath,
+			authType:   sc.Auth.Type,
+			secret:     sc.Auth.Secret,
+		}
+		if 1 == len(sc.GopCache) {
+			got.gopApp, got.gopEnable = sc.GopCache[0].App, sc.GopCache[0].Enable
+		}
+		if 1 == len(sc.Forward) {
+			got.forwardApp = sc.Forward[0].App
+		}
+
+		if !reflect.DeepEqual(v.expect, got) {
+			t.Errorf("%s: got %+v, expect %+v", v.name, got, v.expect)
+		}
+	}
+
+	// the global is not changed by the overrides.
+	if c.Rtmp.Atc || 1 != c.Rtmp.TimeJitter || "./html" != c.Hls.HlsPath || "live" != c.Forward[0].App {
+		t.Errorf("the global is changed, rtmp=%+v, hls=%+v", c.Rtmp, c.Hls)
+	}
+}
+
+func TestVhost(t *testing.T) {
+	c := &Config{}
+	if err := yaml.Unmarshal([]byte(testVhosts), c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		host  string
+		vhost string
+	}{
+		{"tenant1.example.com", "Tenant1.example.com"},
+		{"TENANT2.example.com", "tenant2.example.com"},
+		{"127.0.0.1", DefaultVhost},
+		{"", DefaultVhost},
+	} {
+		if vhost := c.Vhost(v.host); v.vhost != vhost {
+			t.Errorf("vhost of %s is %s, expect %s", v.host, vhost, v.vhost)
+		}
+	}
+}
+
+func TestValidateVhosts(t *testing.T) {
+	for _, v := range []struct {
+		name  string
+		conf  string
+		valid bool
+	}{
+		{"ok", testVhosts, true},
+		{"no name", "vhosts: [{hls: {hlsFragment: 2}}]", false},
+		{"duplicated", "vhosts: [{name: a.com}, {name: A.com}]", false},
+		{"duplicated app", "vhosts: [{name: a.com, apps: [{name: live}, {name: live}]}]", false},
+		{"invalid vhost", "vhosts: [{name: a.com, rtmp: {timeJitter: 4}}]", false},
+		{"invalid app", "vhosts: [{name: a.com, apps: [{name: live, auth: {type: secret}}]}]", false},
+		{"forward without app", "vhosts: [{name: a.com, forward: [{destinations: [rtmp://127.0.0.1]}]}]", false},
+	} {
+		c := &Config{}
+		if err := yaml.Unmarshal([]byte(v.conf), c); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Validate(); v.valid != (nil == err) {
+			t.Errorf("%s: err=%v, expect valid=%v", v.name, err, v.valid)
+		}
+	}
+}
@@ -2,6 +2,10 @@ package hls
 
 import (
 	"log"
+	"net/url"
+	"seal/conf"
+	"seal/hooks"
+	"seal/metrics"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
@@ -28,25 +32,47 @@ type SourceStream struct {
 	// so when publish or republish it must start at stream dts,
 	// not zero dts.
 	streamDts int64
+
+	logger *log.Logger
+}
+
+// Env the logger, metrics and hooks of the server, which the hls streams belong to.
+type Env struct {
+	Logger  *log.Logger
+	Metrics *metrics.Server
+	Hooks   *hooks.Hooks
 }
 
-// NewSourceStream new a hls source stream
-func NewSourceStream() *SourceStream {
+// NewSourceStream new a hls source stream of vhost, with the hls config of c.
+func NewSourceStream(c *conf.Config, vhost string, env *Env) *SourceStream {
 	return &SourceStream{
-		muxer: newHlsMuxer(),
-		cache: newHlsCache(),
+		muxer: newHlsMuxer(vhost, env),
+		cache: newHlsCache(c, env.Logger),
 
-		codec:  newAvcAacCodec(),
-		sample: newCodecSample(),
+		codec:  newAvcAacCodec(env.Logger),
+		sample: newCodecSample(env.Logger),
 		jitter: pt.NewTimeJitter(),
+
+		logger: env.Logger,
 	}
 }
 
+// URL the http url of the hls file of app, the param vhost is added for the vhost configured,
+// whose hls files are in the sub dir of vhost, e.g. /live/test.m3u8?vhost=xxx
+func URL(vhost string, app string, file string) string {
+	u := "/" + app + "/" + file
+	if conf.DefaultVhost != vhost {
+		u += "?vhost=" + url.QueryEscape(vhost)
+	}
+
+	return u
+}
+
 // OnMeta process metadata
 func (hls *SourceStream) OnMeta(pkt *pt.OnMetaDataPacket) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hls.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -65,25 +91,25 @@ func (hls *SourceStream) OnMeta(pkt *pt.OnMetaDataPacket) (err error) {
 func (hls *SourceStream) OnAudio(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hls.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
 	hls.sample.clear()
 	if err = hls.codec.audioAacDemux(msg.Payload.Payload, hls.sample); err != nil {
-		log.Println("hls codec demux audio failed, err=", err)
+		hls.logger.Println("hls codec demux audio failed, err=", err)
 		return
 	}
 
 	if hls.codec.audioCodecID != pt.RtmpCodecAudioAAC {
-		//log.Println("codec audio codec id is not aac, codeID=", hls.codec.audioCodecID)
+		//hls.logger.Println("codec audio codec id is not aac, codeID=", hls.codec.audioCodecID)
 		return
 	}
 
 	// ignore sequence header
 	if pt.RtmpCodecAudioTypeSequenceHeader == hls.sample.aacPacketType {
 		if err = hls.cache.onSequenceHeader(hls.muxer); err != nil {
-			log.Println("hls cache on sequence header failed, err=", err)
+			hls.logger.Println("hls cache on sequence header failed, err=", err)
 			return
 		}
 
@@ -99,7 +125,7 @@ func (hls *SourceStream) OnAudio(msg *pt.Message) (err error) {
 	hls.streamDts = pts
 
 	if err = hls.cache.writeAudio(hls.codec, hls.muxer, pts, hls.sample); err != nil {
-		log.Println("hls cache write audio failed, err=", err)
+		hls.logger.Println("hls cache write audio failed, err=", err)
 		return
 	}
 
@@ -110,13 +136,13 @@ func (hls *SourceStream) OnAudio(msg *pt.Message) (err error) {
 func (hls *SourceStream) OnVideo(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hls.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
 	hls.sample.clear()
 	if err = hls.codec.videoAvcDemux(msg.Payload.Payload, hls.sample); err != nil {
-		log.Println("hls codec demuxer video failed, err=", err)
+		hls.logger.Println("hls codec demuxer video failed, err=", err)
 		return
 	}
 
@@ -141,7 +167,7 @@ func (hls *SourceStream) OnVideo(msg *pt.Message) (err error) {
 	hls.streamDts = int64(dts)
 
 	if err = hls.cache.writeVideo(hls.codec, hls.muxer, int64(dts), hls.sample); err != nil {
-		log.Println("hls cache write video failed")
+		hls.logger.Println("hls cache write video failed")
 		return
 	}
 
@@ -153,7 +179,7 @@ func (hls *SourceStream) OnVideo(msg *pt.Message) (err error) {
 func (hls *SourceStream) OnPublish(app string, stream string) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hls.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -169,7 +195,7 @@ func (hls *SourceStream) OnPublish(app string, stream string) (err error) {
 func (hls *SourceStream) OnUnPublish() (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hls.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -183,7 +209,7 @@ func (hls *SourceStream) OnUnPublish() (err error) {
 func (hls *SourceStream) hlsMux() {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hls.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 	return
@@ -15,11 +15,13 @@ type hlsAacJitter struct {
 	basePts   int64
 	nbSamples int64
 	syncMs    int
+	logger    *log.Logger
 }
 
-func newHlsAacJitter() *hlsAacJitter {
+func newHlsAacJitter(logger *log.Logger) *hlsAacJitter {
 	return &hlsAacJitter{
 		syncMs: hlsConfDefaultAacSync,
+		logger: logger,
 	}
 }
 
@@ -31,7 +33,7 @@ func newHlsAacJitter() *hlsAacJitter {
 func (ha *hlsAacJitter) onBufferStart(flvPts int64, sampleRate int, aacSampleRate int) (calcCorrectPts int64) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			ha.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -75,7 +77,7 @@ func (ha *hlsAacJitter) onBufferStart(flvPts int64, sampleRate int, aacSampleRat
 func (ha *hlsAacJitter) onBufferContinue() {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			ha.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
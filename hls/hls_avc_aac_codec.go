@@ -64,21 +64,36 @@ type avcAacCodec struct {
 	// @see: ffmpeg, AVCodecContext::extradata
 	aacExtraSize int
 	aacExtraData []byte
+
+	logger *log.Logger
 }
 
-func newAvcAacCodec() *avcAacCodec {
+func newAvcAacCodec(logger *log.Logger) *avcAacCodec {
 	return &avcAacCodec{
 		aacSampleRate: hlsAacSampleRateUnset,
+		logger:        logger,
 	}
 }
 
+// metadata the fields of metadata used by codec, the data rate is in kbps.
+type metadata struct {
+	Duration      float64 `amf:"duration"`
+	Width         float64 `amf:"width"`
+	Height        float64 `amf:"height"`
+	FrameRate     float64 `amf:"framerate"`
+	VideoCodecID  float64 `amf:"videocodecid"`
+	VideoDataRate float64 `amf:"videodatarate"`
+	AudioCodecID  float64 `amf:"audiocodecid"`
+	AudioDataRate float64 `amf:"audiodatarate"`
+}
+
 // demux the metadata, to get the stream info,
 // for instance, the width/height, sample rate.
 // @param metadata, the metadata amf0 object. assert not NULL.
 func (codec *avcAacCodec) metaDataDemux(pkt *pt.OnMetaDataPacket) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			codec.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -86,37 +101,29 @@ func (codec *avcAacCodec) metaDataDemux(pkt *pt.OnMetaDataPacket) (err error) {
 		return
 	}
 
-	if v := pkt.GetProperty("duration"); v != nil {
-		codec.duration = int(v.(float64))
-	}
-
-	if v := pkt.GetProperty("width"); v != nil {
-		codec.width = int(v.(float64))
-	}
-
-	if v := pkt.GetProperty("height"); v != nil {
-		codec.height = int(v.(float64))
-	}
-
-	if v := pkt.GetProperty("framerate"); v != nil {
-		codec.frameRate = int(v.(float64))
-	}
-
-	if v := pkt.GetProperty("videocodecid"); v != nil {
-		codec.videoCodecID = int(v.(float64))
-	}
-
-	if v := pkt.GetProperty("videodatarate"); v != nil {
-		codec.videoDataRate = int(1000 * v.(float64))
+	// keep the values of fields not in metadata.
+	meta := metadata{
+		Duration:      float64(codec.duration),
+		Width:         float64(codec.width),
+		Height:        float64(codec.height),
+		FrameRate:     float64(codec.frameRate),
+		VideoCodecID:  float64(codec.videoCodecID),
+		VideoDataRate: float64(codec.videoDataRate) / 1000,
+		AudioCodecID:  float64(codec.audioCodecID),
+		AudioDataRate: float64(codec.audioDataRate) / 1000,
 	}
-
-	if v := pkt.GetProperty("audiocodecid"); v != nil {
-		codec.audioCodecID = int(v.(float64))
+	if err = pkt.Unmarshal(&meta); err != nil {
+		return
 	}
 
-	if v := pkt.GetProperty("audiodatarate"); v != nil {
-		codec.audioDataRate = int(1000 * v.(float64))
-	}
+	codec.duration = int(meta.Duration)
+	codec.width = int(meta.Width)
+	codec.height = int(meta.Height)
+	codec.frameRate = int(meta.FrameRate)
+	codec.videoCodecID = int(meta.VideoCodecID)
+	codec.videoDataRate = int(1000 * meta.VideoDataRate)
+	codec.audioCodecID = int(meta.AudioCodecID)
+	codec.audioDataRate = int(1000 * meta.AudioDataRate)
 
 	return
 }
@@ -129,7 +136,7 @@ func (codec *avcAacCodec) metaDataDemux(pkt *pt.OnMetaDataPacket) (err error) {
 func (codec *avcAacCodec) audioAacDemux(data []byte, sample *codecSample) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			codec.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -162,7 +169,7 @@ func (codec *avcAacCodec) audioAacDemux(data []byte, sample *codecSample) (err e
 
 	// only support for aac
 	if pt.RtmpCodecAudioAAC != codec.audioCodecID {
-		//log.Println("hls only support audio aac, actual is ", codec.audioCodecID)
+		//codec.logger.Println("hls only support audio aac, actual is ", codec.audioCodecID)
 		return
 	}
 
@@ -256,7 +263,7 @@ func (codec *avcAacCodec) audioAacDemux(data []byte, sample *codecSample) (err e
 func (codec *avcAacCodec) videoAvcDemux(data []byte, sample *codecSample) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			codec.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -35,13 +35,20 @@ type hlsCache struct {
 	audioBufferStartPts int64
 	// time jitter for aac
 	aacJitter *hlsAacJitter
+
+	// conf the config of server, for the hls fragment, window and path.
+	conf *conf.Config
+
+	logger *log.Logger
 }
 
-func newHlsCache() *hlsCache {
+func newHlsCache(c *conf.Config, logger *log.Logger) *hlsCache {
 	return &hlsCache{
+		conf:      c,
 		af:        newMpegTsFrame(),
 		vf:        newMpegTsFrame(),
-		aacJitter: newHlsAacJitter(),
+		aacJitter: newHlsAacJitter(logger),
+		logger:    logger,
 	}
 }
 
@@ -49,13 +56,13 @@ func newHlsCache() *hlsCache {
 func (hc *hlsCache) onPublish(muxer *hlsMuxer, app string, stream string, segmentStartDts int64) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	hlsFragment := conf.GlobalConfInfo.Hls.HlsFragment
-	hlsWindow := conf.GlobalConfInfo.Hls.HlsWindow
-	hlsPath := conf.GlobalConfInfo.Hls.HlsPath
+	hlsFragment := hc.conf.Hls.HlsFragment
+	hlsWindow := hc.conf.Hls.HlsWindow
+	hlsPath := hc.conf.Hls.HlsPath
 
 	// open muxer
 	if err = muxer.updateConfig(app, stream, hlsPath, hlsFragment, hlsWindow); err != nil {
@@ -63,7 +70,7 @@ func (hc *hlsCache) onPublish(muxer *hlsMuxer, app string, stream string, segmen
 	}
 
 	if err = muxer.segmentOpen(segmentStartDts); err != nil {
-		log.Println("segment open failed, err=", err)
+		hc.logger.Println("segment open failed, err=", err)
 		return
 	}
 
@@ -74,12 +81,12 @@ func (hc *hlsCache) onPublish(muxer *hlsMuxer, app string, stream string, segmen
 func (hc *hlsCache) onUnPublish(muxer *hlsMuxer) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
 	if err = muxer.flushAudio(hc.af, &hc.ab); err != nil {
-		log.Println("m3u8 muxer flush audio failed, err=", err)
+		hc.logger.Println("m3u8 muxer flush audio failed, err=", err)
 		return
 	}
 
@@ -97,7 +104,7 @@ func (hc *hlsCache) onUnPublish(muxer *hlsMuxer) (err error) {
 func (hc *hlsCache) onSequenceHeader(muxer *hlsMuxer) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -112,7 +119,7 @@ func (hc *hlsCache) onSequenceHeader(muxer *hlsMuxer) (err error) {
 func (hc *hlsCache) writeAudio(codec *avcAacCodec, muxer *hlsMuxer, pts int64, sample *codecSample) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -131,13 +138,13 @@ func (hc *hlsCache) writeAudio(codec *avcAacCodec, muxer *hlsMuxer, pts int64, s
 
 	// write audio to cache
 	if err = hc.cacheAudio(codec, sample); err != nil {
-		log.Println("hls cache audio failed, err=", err)
+		hc.logger.Println("hls cache audio failed, err=", err)
 		return
 	}
 
 	if len(hc.ab) > hlsAudioCacheSize {
 		if err = muxer.flushAudio(hc.af, &hc.ab); err != nil {
-			log.Println("flush audio failed, err=", err)
+			hc.logger.Println("flush audio failed, err=", err)
 			return
 		}
 
@@ -160,10 +167,10 @@ func (hc *hlsCache) writeAudio(codec *avcAacCodec, muxer *hlsMuxer, pts int64, s
 	// we use absolutely overflow of segment to make jwplayer/ffplay happy
 	if muxer.isSegmentAbsolutelyOverflow() {
 		if err = hc.reapSegment("audio", muxer, hc.af.pts); err != nil {
-			log.Println("reap segment failed, err=", err)
+			hc.logger.Println("reap segment failed, err=", err)
 			return
 		}
-		log.Println("reap segment success")
+		hc.logger.Println("reap segment success")
 	}
 
 	return
@@ -173,7 +180,7 @@ func (hc *hlsCache) writeAudio(codec *avcAacCodec, muxer *hlsMuxer, pts int64, s
 func (hc *hlsCache) writeVideo(codec *avcAacCodec, muxer *hlsMuxer, dts int64, sample *codecSample) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -198,7 +205,7 @@ func (hc *hlsCache) writeVideo(codec *avcAacCodec, muxer *hlsMuxer, dts int64, s
 
 	// flush video when got one
 	if err = muxer.flushVideo(hc.af, hc.ab, hc.vf, &hc.vb); err != nil {
-		log.Println("m3u8 muxer flush video failed")
+		hc.logger.Println("m3u8 muxer flush video failed")
 		return
 	}
 
@@ -212,17 +219,17 @@ func (hc *hlsCache) writeVideo(codec *avcAacCodec, muxer *hlsMuxer, dts int64, s
 func (hc *hlsCache) reapSegment(logDesc string, muxer *hlsMuxer, segmentStartDts int64) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
 	if err = muxer.segmentClose(logDesc); err != nil {
-		log.Println("m3u8 muxer close segment failed, err=", err)
+		hc.logger.Println("m3u8 muxer close segment failed, err=", err)
 		return
 	}
 
 	if err = muxer.segmentOpen(segmentStartDts); err != nil {
-		log.Println("m3u8 muxer open segment failed, err=", err)
+		hc.logger.Println("m3u8 muxer open segment failed, err=", err)
 		return
 	}
 
@@ -230,7 +237,7 @@ func (hc *hlsCache) reapSegment(logDesc string, muxer *hlsMuxer, segmentStartDts
 	// @see: ngx_rtmp_hls_open_fragment
 	/* start fragment with audio to make iPhone happy */
 	if err = muxer.flushAudio(hc.af, &hc.ab); err != nil {
-		log.Println("m3u8 muxer flush audio failed, err=", err)
+		hc.logger.Println("m3u8 muxer flush audio failed, err=", err)
 		return
 	}
 
@@ -240,7 +247,7 @@ func (hc *hlsCache) reapSegment(logDesc string, muxer *hlsMuxer, segmentStartDts
 func (hc *hlsCache) cacheAudio(codec *avcAacCodec, sample *codecSample) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -311,7 +318,7 @@ func (hc *hlsCache) cacheAudio(codec *avcAacCodec, sample *codecSample) (err err
 func (hc *hlsCache) cacheVideo(codec *avcAacCodec, sample *codecSample) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
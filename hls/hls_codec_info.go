@@ -0,0 +1,60 @@
+package hls
+
+import (
+	"log"
+	"seal/rtmp/pt"
+)
+
+// CodecInfo the codec info of the stream, for stats.
+type CodecInfo struct {
+	VideoCodecID int `json:"videoCodecId"`
+	Width        int `json:"width"`
+	Height       int `json:"height"`
+	FrameRate    int `json:"frameRate"`
+	AvcProfile   int `json:"avcProfile"`
+	AvcLevel     int `json:"avcLevel"`
+
+	AudioCodecID int `json:"audioCodecId"`
+	AacProfile   int `json:"aacProfile"`
+	SampleRate   int `json:"sampleRate"`
+	Channels     int `json:"channels"`
+}
+
+// ParseCodecInfo demux the metadata and sequence headers to get the codec info,
+// the msgs can be nil if not received yet, the errors of demux are logged to logger.
+func ParseCodecInfo(meta *pt.Message, video *pt.Message, audio *pt.Message, logger *log.Logger) (info CodecInfo) {
+	codec := newAvcAacCodec(logger)
+	sample := newCodecSample(logger)
+
+	if nil != meta {
+		var pkt pt.OnMetaDataPacket
+		if err := pkt.Decode(meta.Payload.Payload); nil == err {
+			codec.metaDataDemux(&pkt)
+		}
+	}
+
+	if nil != video {
+		codec.videoAvcDemux(video.Payload.Payload, sample)
+	}
+
+	if nil != audio {
+		sample.clear()
+		codec.audioAacDemux(audio.Payload.Payload, sample)
+	}
+
+	info.VideoCodecID = codec.videoCodecID
+	info.Width = codec.width
+	info.Height = codec.height
+	info.FrameRate = codec.frameRate
+	info.AvcProfile = int(codec.avcProfile)
+	info.AvcLevel = int(codec.avcLevel)
+
+	info.AudioCodecID = codec.audioCodecID
+	info.AacProfile = int(codec.aacProfile)
+	info.Channels = int(codec.aacChannels)
+	if hlsAacSampleRateUnset != codec.aacSampleRate && int(codec.aacSampleRate) < len(aacSampleRates) {
+		info.SampleRate = aacSampleRates[codec.aacSampleRate]
+	}
+
+	return
+}
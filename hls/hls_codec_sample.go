@@ -43,10 +43,13 @@ type codecSample struct {
 	soundSize     int
 	soundType     int
 	aacPacketType int
+
+	logger *log.Logger
 }
 
-func newCodecSample() *codecSample {
+func newCodecSample(logger *log.Logger) *codecSample {
 	return &codecSample{
+		logger:        logger,
 		isVideo:       false,
 		frameType:     pt.RtmpCodecVideoAVCFrameReserved,
 		avcPacketType: pt.RtmpCodecVideoAVCTypeReserved,
@@ -79,7 +82,7 @@ func (sample *codecSample) clear() (err error) {
 func (sample *codecSample) addSampleUnit(data []byte) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			sample.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
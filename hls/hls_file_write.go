@@ -11,16 +11,20 @@ import (
 type fileWriter struct {
 	file string
 	f    *os.File
+
+	logger *log.Logger
 }
 
-func newFileWriter() *fileWriter {
-	return &fileWriter{}
+func newFileWriter(logger *log.Logger) *fileWriter {
+	return &fileWriter{
+		logger: logger,
+	}
 }
 
 func (fw *fileWriter) open(file string) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			fw.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -31,7 +35,7 @@ func (fw *fileWriter) open(file string) (err error) {
 
 	fw.f, err = os.OpenFile(file, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
 	if err != nil {
-		log.Println("open file error, file=", file)
+		fw.logger.Println("open file error, file=", file)
 		return
 	}
 
@@ -43,7 +47,7 @@ func (fw *fileWriter) open(file string) (err error) {
 func (fw *fileWriter) close() {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			fw.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -66,12 +70,12 @@ func (fw *fileWriter) isOpen() bool {
 func (fw *fileWriter) write(buf []byte) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			fw.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
 	if _, err = fw.f.Write(buf); err != nil {
-		log.Println("write to file failed, file=", fw.file, ",err=", err)
+		fw.logger.Println("write to file failed, file=", fw.file, ",err=", err)
 		return
 	}
 
@@ -1,8 +1,6 @@
 package hls
 
 import (
-	"log"
-
 	"github.com/calabashdad/utiltools"
 )
 
@@ -78,12 +76,12 @@ var mpegtsHeader = []uint8{
 func mpegtsWriteHeader(writer *fileWriter) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			writer.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
 	if err = writer.write(mpegtsHeader); err != nil {
-		log.Println("write ts file header failed, err=", err)
+		writer.logger.Println("write ts file header failed, err=", err)
 		return
 	}
 
@@ -93,7 +91,7 @@ func mpegtsWriteHeader(writer *fileWriter) (err error) {
 func mpegtsWriteFrame(writer *fileWriter, frame *mpegTsFrame, buffer []byte) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			writer.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -237,7 +235,7 @@ func mpegtsWriteFrame(writer *fileWriter, frame *mpegTsFrame, buffer []byte) (er
 
 		// write ts packet
 		if err = writer.write(pkt[:]); err != nil {
-			log.Println("write ts file failed, err=", err)
+			writer.logger.Println("write ts file failed, err=", err)
 			return
 		}
 	}
@@ -3,6 +3,8 @@ package hls
 import (
 	"log"
 	"os"
+	"seal/hooks"
+	"seal/metrics"
 	"strconv"
 	"syscall"
 
@@ -16,6 +18,7 @@ import (
 // that is, user must use HlsCache, which will control the methods of muxer,
 // and provides HLS mechenisms.
 type hlsMuxer struct {
+	vhost  string
 	app    string
 	stream string
 
@@ -31,10 +34,19 @@ type hlsMuxer struct {
 
 	//current segment
 	current *hlsSegment
+
+	metrics *metrics.Server
+	hooks   *hooks.Hooks
+	logger  *log.Logger
 }
 
-func newHlsMuxer() *hlsMuxer {
-	return &hlsMuxer{}
+func newHlsMuxer(vhost string, env *Env) *hlsMuxer {
+	return &hlsMuxer{
+		vhost:   vhost,
+		metrics: env.Metrics,
+		hooks:   env.Hooks,
+		logger:  env.Logger,
+	}
 }
 
 func (hm *hlsMuxer) getSequenceNo() int {
@@ -57,7 +69,7 @@ func (hm *hlsMuxer) updateConfig(app string, stream string, path string, fragmen
 func (hm *hlsMuxer) segmentOpen(segmentStartDts int64) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -68,12 +80,12 @@ func (hm *hlsMuxer) segmentOpen(segmentStartDts int64) (err error) {
 
 	// create dir for app
 	if err = hm.createDir(); err != nil {
-		log.Println("create dir faile,err=", err)
+		hm.logger.Println("create dir faile,err=", err)
 		return
 	}
 
 	// new segment
-	hm.current = newHlsSegment()
+	hm.current = newHlsSegment(hm.logger)
 	hm.current.sequenceNo = hm.sequenceNo
 	hm.sequenceNo++
 	hm.current.segmentStartDts = segmentStartDts
@@ -86,7 +98,7 @@ func (hm *hlsMuxer) segmentOpen(segmentStartDts int64) (err error) {
 
 	tmpFile := hm.current.fullPath + ".tmp"
 	if err = hm.current.muxer.open(tmpFile); err != nil {
-		log.Println("open hls muxer failed, err=", err)
+		hm.logger.Println("open hls muxer failed, err=", err)
 		return
 	}
 
@@ -96,7 +108,7 @@ func (hm *hlsMuxer) segmentOpen(segmentStartDts int64) (err error) {
 func (hm *hlsMuxer) onSequenceHeader() (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -112,7 +124,7 @@ func (hm *hlsMuxer) onSequenceHeader() (err error) {
 func (hm *hlsMuxer) isSegmentOverflow() bool {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -124,12 +136,12 @@ func (hm *hlsMuxer) isSegmentOverflow() bool {
 func (hm *hlsMuxer) isSegmentAbsolutelyOverflow() bool {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
 	if nil == hm.current {
-		log.Println("current is null is impossible, there must be a mistake")
+		hm.logger.Println("current is null is impossible, there must be a mistake")
 		return true
 	}
 
@@ -141,13 +153,13 @@ func (hm *hlsMuxer) isSegmentAbsolutelyOverflow() bool {
 func (hm *hlsMuxer) flushAudio(af *mpegTsFrame, ab *[]byte) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
 	// if current is NULL, segment is not open, ignore the flush event.
 	if nil == hm.current {
-		log.Println("hls segment is not open, ignore the flush event.")
+		hm.logger.Println("hls segment is not open, ignore the flush event.")
 		return
 	}
 
@@ -158,7 +170,7 @@ func (hm *hlsMuxer) flushAudio(af *mpegTsFrame, ab *[]byte) (err error) {
 	hm.current.updateDuration(af.pts)
 
 	if err = hm.current.muxer.writeAudio(af, *ab); err != nil {
-		log.Println("current muxer write audio faile, err=", err)
+		hm.logger.Println("current muxer write audio faile, err=", err)
 		return
 	}
 
@@ -171,7 +183,7 @@ func (hm *hlsMuxer) flushAudio(af *mpegTsFrame, ab *[]byte) (err error) {
 func (hm *hlsMuxer) flushVideo(af *mpegTsFrame, ab []byte, vf *mpegTsFrame, vb *[]byte) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -196,18 +208,22 @@ func (hm *hlsMuxer) flushVideo(af *mpegTsFrame, ab []byte, vf *mpegTsFrame, vb *
 func (hm *hlsMuxer) segmentClose(logDesc string) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
 	if nil == hm.current {
-		log.Println("ignore the segment close, for segment is not open.")
+		hm.logger.Println("ignore the segment close, for segment is not open.")
 		return
 	}
 
+	// the segment reaped, notify after the m3u8 refreshed.
+	var reaped *hlsSegment
+
 	// valid, add to segments if segment duration is ok
 	if hm.current.duration*1000 >= hlsSegmentMinDurationMs {
 		hm.segments = append(hm.segments, hm.current)
+		reaped = hm.current
 
 		// close the muxer of finished segment
 		fullPath := hm.current.fullPath
@@ -216,7 +232,7 @@ func (hm *hlsMuxer) segmentClose(logDesc string) (err error) {
 		// rename from tmp to real path
 		tmpFile := fullPath + ".tmp"
 		if err = os.Rename(tmpFile, fullPath); err != nil {
-			log.Println("rename file failed, err=", err)
+			hm.logger.Println("rename file failed, err=", err)
 			return
 		}
 	} else {
@@ -226,7 +242,7 @@ func (hm *hlsMuxer) segmentClose(logDesc string) (err error) {
 		// rename from tmp to real path
 		tmpFile := hm.current.fullPath + ".tmp"
 		if err = syscall.Unlink(tmpFile); err != nil {
-			log.Println("syscall unlink tmpfile=", tmpFile, " failed, err=", err)
+			hm.logger.Println("syscall unlink tmpfile=", tmpFile, " failed, err=", err)
 		}
 	}
 
@@ -252,7 +268,25 @@ func (hm *hlsMuxer) segmentClose(logDesc string) (err error) {
 
 	// refresh the m3u8, do not contains the removed ts
 	if err = hm.refreshM3u8(); err != nil {
-		log.Println("refresh m3u8 failed, err=", err)
+		hm.logger.Println("refresh m3u8 failed, err=", err)
+		hm.metrics.M3u8RefreshErrors.Inc()
+	}
+
+	if nil != reaped {
+		hm.metrics.HlsSegments.Inc()
+		hm.metrics.HlsSegmentDuration.Observe(reaped.duration)
+
+		hm.hooks.Notify(&hooks.Event{
+			Action:   hooks.OnHlsSegment,
+			Vhost:    hm.vhost,
+			App:      hm.app,
+			Stream:   hm.stream,
+			File:     reaped.fullPath,
+			URL:      URL(hm.vhost, hm.app, reaped.uri),
+			M3u8:     hm.m3u8,
+			Duration: reaped.duration,
+			SeqNo:    reaped.sequenceNo,
+		})
 	}
 
 	// remove the ts file
@@ -268,7 +302,7 @@ func (hm *hlsMuxer) segmentClose(logDesc string) (err error) {
 func (hm *hlsMuxer) refreshM3u8() (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -284,13 +318,13 @@ func (hm *hlsMuxer) refreshM3u8() (err error) {
 
 	var f *os.File
 	if f, err = hm._refreshM3u8(m3u8File); err != nil {
-		log.Println("refresh m3u8 file faile, err=", err)
+		hm.logger.Println("refresh m3u8 file faile, err=", err)
 		return
 	}
 	if nil != f {
 		f.Close()
 		if err = os.Rename(m3u8File, hm.m3u8); err != nil {
-			log.Println("rename m3u8 file failed, old file=", m3u8File, ",new file=", hm.m3u8)
+			hm.logger.Println("rename m3u8 file failed, old file=", m3u8File, ",new file=", hm.m3u8)
 			return
 		}
 	}
@@ -303,7 +337,7 @@ func (hm *hlsMuxer) refreshM3u8() (err error) {
 func (hm *hlsMuxer) _refreshM3u8(m3u8File string) (f *os.File, err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -314,7 +348,7 @@ func (hm *hlsMuxer) _refreshM3u8(m3u8File string) (f *os.File, err error) {
 
 	f, err = os.OpenFile(m3u8File, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
 	if err != nil {
-		log.Println("_refreshM3u8: open file error, file=", m3u8File)
+		hm.logger.Println("_refreshM3u8: open file error, file=", m3u8File)
 		return
 	}
 
@@ -331,7 +365,7 @@ func (hm *hlsMuxer) _refreshM3u8(m3u8File string) (f *os.File, err error) {
 	}
 
 	if _, err = f.Write(header); err != nil {
-		log.Println("write m3u8 header failed, err=", err)
+		hm.logger.Println("write m3u8 header failed, err=", err)
 		return
 	}
 
@@ -346,7 +380,7 @@ func (hm *hlsMuxer) _refreshM3u8(m3u8File string) (f *os.File, err error) {
 	var duration string
 	duration = "#EXT-X-TARGETDURATION:" + strconv.Itoa(targetDuration) + "\n"
 	if _, err = f.Write([]byte(duration)); err != nil {
-		log.Println("write m3u8 duration failed, err=", err)
+		hm.logger.Println("write m3u8 duration failed, err=", err)
 		return
 	}
 
@@ -358,7 +392,7 @@ func (hm *hlsMuxer) _refreshM3u8(m3u8File string) (f *os.File, err error) {
 			// #EXT-X-DISCONTINUITY\n
 			extDiscon := "#EXT-X-DISCONTINUITY\n"
 			if _, err = f.Write([]byte(extDiscon)); err != nil {
-				log.Println("write m3u8 segment discontinuity failed, err=", err)
+				hm.logger.Println("write m3u8 segment discontinuity failed, err=", err)
 				return
 			}
 		}
@@ -366,14 +400,14 @@ func (hm *hlsMuxer) _refreshM3u8(m3u8File string) (f *os.File, err error) {
 		// "#EXTINF:4294967295.208,\n"
 		extInfo := "#EXTINF:" + strconv.FormatFloat(s.duration, 'f', 3, 64) + "\n"
 		if _, err = f.Write([]byte(extInfo)); err != nil {
-			log.Println("write m3u8 segment info failed, err=", err)
+			hm.logger.Println("write m3u8 segment info failed, err=", err)
 			return
 		}
 
 		// file name
 		filename := s.uri + "\n"
 		if _, err = f.Write([]byte(filename)); err != nil {
-			log.Println("write m3u8 uri failed, err=", err)
+			hm.logger.Println("write m3u8 uri failed, err=", err)
 			return
 		}
 
@@ -385,7 +419,7 @@ func (hm *hlsMuxer) _refreshM3u8(m3u8File string) (f *os.File, err error) {
 func (hm *hlsMuxer) createDir() (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			hm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -395,7 +429,8 @@ func (hm *hlsMuxer) createDir() (err error) {
 
 	_, errLocal := os.Stat(appDir)
 	if os.IsNotExist(errLocal) {
-		if err = os.Mkdir(appDir, os.ModePerm); err != nil {
+		// the parent may not exist, e.g. the sub dir of vhost.
+		if err = os.MkdirAll(appDir, os.ModePerm); err != nil {
 			return
 		}
 	}
@@ -1,5 +1,9 @@
 package hls
 
+import (
+	"log"
+)
+
 // the wrapper of m3u8 segment from specification:
 // 3.3.2.  EXTINF
 // The EXTINF tag specifies the duration of a media segment.
@@ -20,9 +24,9 @@ type hlsSegment struct {
 	isSequenceHeader bool
 }
 
-func newHlsSegment() *hlsSegment {
+func newHlsSegment(logger *log.Logger) *hlsSegment {
 	return &hlsSegment{
-		muxer: newTsMuxer(),
+		muxer: newTsMuxer(logger),
 	}
 }
 
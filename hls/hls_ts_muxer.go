@@ -10,18 +10,20 @@ import (
 type tsMuxer struct {
 	writer *fileWriter
 	path   string
+	logger *log.Logger
 }
 
-func newTsMuxer() *tsMuxer {
+func newTsMuxer(logger *log.Logger) *tsMuxer {
 	return &tsMuxer{
-		writer: newFileWriter(),
+		writer: newFileWriter(logger),
+		logger: logger,
 	}
 }
 
 func (tm *tsMuxer) open(path string) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			tm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -30,13 +32,13 @@ func (tm *tsMuxer) open(path string) (err error) {
 	tm.close()
 
 	if err = tm.writer.open(tm.path); err != nil {
-		log.Println("opem ts muxer path failed, err=", err)
+		tm.logger.Println("opem ts muxer path failed, err=", err)
 		return
 	}
 
 	// write mpegts header
 	if err = mpegtsWriteHeader(tm.writer); err != nil {
-		log.Println("write mpegts header failed, err=", err)
+		tm.logger.Println("write mpegts header failed, err=", err)
 		return
 	}
 
@@ -46,12 +48,12 @@ func (tm *tsMuxer) open(path string) (err error) {
 func (tm *tsMuxer) writeAudio(af *mpegTsFrame, ab []byte) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			tm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
 	if err = mpegtsWriteFrame(tm.writer, af, ab); err != nil {
-		log.Println("mpegts write frame faile, err=", err)
+		tm.logger.Println("mpegts write frame faile, err=", err)
 		return
 	}
 
@@ -61,7 +63,7 @@ func (tm *tsMuxer) writeAudio(af *mpegTsFrame, ab []byte) (err error) {
 func (tm *tsMuxer) writeVideo(vf *mpegTsFrame, vb *[]byte) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			tm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -75,7 +77,7 @@ func (tm *tsMuxer) writeVideo(vf *mpegTsFrame, vb *[]byte) (err error) {
 func (tm *tsMuxer) close() (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			tm.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -0,0 +1,279 @@
+// Package hooks post the events of the streams to the http callbacks,
+// e.g. the control plane wants to know when a stream is published.
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"seal/conf"
+	"sync"
+	"time"
+
+	"github.com/calabashdad/utiltools"
+)
+
+const (
+	// OnConnect the client connect the app.
+	OnConnect = "on_connect"
+	// OnPublish the client publish the stream, can be denied.
+	OnPublish = "on_publish"
+	// OnUnpublish the client stop publishing the stream.
+	OnUnpublish = "on_unpublish"
+	// OnPlay the client play the stream, can be denied.
+	OnPlay = "on_play"
+	// OnStop the client stop playing the stream.
+	OnStop = "on_stop"
+	// OnHlsSegment a new hls segment(ts file) is reaped.
+	OnHlsSegment = "on_hls_segment"
+	// OnClose the client connection is closed.
+	OnClose = "on_close"
+)
+
+const (
+	// hookDefaultTimeoutSeconds the default timeout of one http callback.
+	hookDefaultTimeoutSeconds = 3
+	// hookDefaultRetry the default retry times of the notify events.
+	hookDefaultRetry = 3
+	// hookDefaultQueueSize the default max events waiting to notify, drop the new events when full.
+	hookDefaultQueueSize = 1024
+)
+
+// Event the json body posted to the callbacks.
+type Event struct {
+	Action     string  `json:"action"`
+	Vhost      string  `json:"vhost,omitempty"`
+	App        string  `json:"app"`
+	Stream     string  `json:"stream,omitempty"`
+	Param      string  `json:"param,omitempty"`
+	TcURL      string  `json:"tcUrl,omitempty"`
+	PageURL    string  `json:"pageUrl,omitempty"`
+	RemoteAddr string  `json:"remoteAddr,omitempty"`
+	File       string  `json:"file,omitempty"`
+	URL        string  `json:"url,omitempty"`
+	M3u8       string  `json:"m3u8,omitempty"`
+	Duration   float64 `json:"duration,omitempty"`
+	SeqNo      int     `json:"seqNo,omitempty"`
+	Time       int64   `json:"time"`
+}
+
+// Hooks post the events of a server to the callbacks of its config, each server has its own.
+type Hooks struct {
+	// lock protect the config, client and retry, which are replaced when reload.
+	lock   sync.RWMutex
+	config *conf.Config
+	client *http.Client
+	retry  int
+
+	// queue the events to notify, the worker is started when notify first time.
+	queue chan *Event
+	start sync.Once
+	// closed the queue is closed, protected by lock.
+	closed bool
+
+	logger *log.Logger
+}
+
+// New create the hooks of config c, log to the std log.
+func New(c *conf.Config) *Hooks {
+	queueSize := c.Hooks.QueueSize
+	if 0 == queueSize {
+		queueSize = hookDefaultQueueSize
+	}
+
+	h := &Hooks{
+		queue:  make(chan *Event, queueSize),
+		logger: log.Default(),
+	}
+	h.Reload(c)
+
+	return h
+}
+
+// SetLogger set the logger, must be called before used.
+func (h *Hooks) SetLogger(l *log.Logger) {
+	h.logger = l
+}
+
+// Reload apply the callbacks, timeout and retry of config c,
+// the queue size is not changed until restart.
+func (h *Hooks) Reload(c *conf.Config) {
+	cfg := &c.Hooks
+
+	timeout := cfg.Timeout
+	if 0 == timeout {
+		timeout = hookDefaultTimeoutSeconds
+	}
+
+	r := int(cfg.Retry)
+	if 0 == r {
+		r = hookDefaultRetry
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.config = c
+	h.client = &http.Client{
+		Timeout: time.Duration(timeout) * time.Second,
+	}
+	h.retry = r
+}
+
+// Close stop the worker after the events queued are notified, the events after are dropped.
+func (h *Hooks) Close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+
+	close(h.queue)
+}
+
+// httpClient the client and retry times of config.
+func (h *Hooks) httpClient() (*http.Client, int) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	return h.client, h.retry
+}
+
+// urls the callbacks of the action in config.
+func (h *Hooks) urls(action string) []string {
+	h.lock.RLock()
+	c := h.config
+	h.lock.RUnlock()
+
+	if nil == c {
+		return nil
+	}
+
+	cfg := &c.Hooks
+
+	switch action {
+	case OnConnect:
+		return cfg.OnConnect
+	case OnPublish:
+		return cfg.OnPublish
+	case OnUnpublish:
+		return cfg.OnUnpublish
+	case OnPlay:
+		return cfg.OnPlay
+	case OnStop:
+		return cfg.OnStop
+	case OnHlsSegment:
+		return cfg.OnHlsSegment
+	case OnClose:
+		return cfg.OnClose
+	}
+
+	return nil
+}
+
+// Call post the event to the callbacks and wait for the response,
+// the action is denied if any callback response is not 2xx.
+// used by on_publish and on_play.
+func (h *Hooks) Call(e *Event) (err error) {
+	e.Time = time.Now().Unix()
+
+	for _, u := range h.urls(e.Action) {
+		if err = h.post(u, e); err != nil {
+			h.logger.Printf("hooks %s denied, app=%s, stream=%s, url=%s, err=%v\n", e.Action, e.App, e.Stream, u, err)
+			return
+		}
+	}
+
+	return
+}
+
+// Notify post the event to the callbacks in background, ignore the response.
+// the event is dropped if too many events are waiting.
+func (h *Hooks) Notify(e *Event) {
+	if 0 == len(h.urls(e.Action)) {
+		return
+	}
+
+	h.start.Do(func() {
+		go h.notifyCycle()
+	})
+
+	e.Time = time.Now().Unix()
+
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if h.closed {
+		return
+	}
+
+	select {
+	case h.queue <- e:
+	default:
+		h.logger.Printf("hooks queue is full, drop event %s, app=%s, stream=%s\n", e.Action, e.App, e.Stream)
+	}
+}
+
+func (h *Hooks) notifyCycle() {
+	defer func() {
+		if err := recover(); err != nil {
+			h.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	for e := range h.queue {
+		for _, u := range h.urls(e.Action) {
+			h.notify(u, e)
+		}
+	}
+}
+
+// notify post the event to the url, retry with backoff(1s, 2s ...) when failed.
+func (h *Hooks) notify(u string, e *Event) {
+	var err error
+
+	_, retry := h.httpClient()
+
+	for i := 0; i < retry; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * time.Second)
+		}
+
+		if err = h.post(u, e); nil == err {
+			return
+		}
+	}
+
+	h.logger.Printf("hooks notify %s failed, url=%s, retry=%d, err=%v\n", e.Action, u, retry, err)
+}
+
+func (h *Hooks) post(u string, e *Event) (err error) {
+	var body []byte
+	if body, err = json.Marshal(e); err != nil {
+		return
+	}
+
+	client, _ := h.httpClient()
+
+	var res *http.Response
+	if res, err = client.Post(u, "application/json", bytes.NewReader(body)); err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	// the reason of deny, limit the size in case of the big response.
+	reason, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		err = fmt.Errorf("status=%d, reason=%s", res.StatusCode, bytes.TrimSpace(reason))
+		return
+	}
+
+	return
+}
@@ -0,0 +1,111 @@
+package kernel
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// minBufferClassSize the size of the smallest class.
+	minBufferClassSize = 512
+	// maxBufferClassSize the size of the largest class, the larger buffers are allocated directly, not pooled.
+	maxBufferClassSize = 1024 * 1024
+)
+
+var (
+	// bufferClassSizes the sizes of classes, 4 classes between the powers of 2,
+	// e.g. 512, 640, 768, 896, 1024, so at most 1/4 space wasted.
+	bufferClassSizes []uint32
+	// bufferPools the pools of buffers, one for each size class.
+	bufferPools []sync.Pool
+)
+
+func init() {
+	for size := uint32(minBufferClassSize); size < maxBufferClassSize; size *= 2 {
+		for i := uint32(0); i < 4; i++ {
+			bufferClassSizes = append(bufferClassSizes, size+size/4*i)
+		}
+	}
+	bufferClassSizes = append(bufferClassSizes, maxBufferClassSize)
+
+	bufferPools = make([]sync.Pool, len(bufferClassSizes))
+}
+
+// Buffer the buffer allocated from pool with reference count,
+// it's put back to pool when the last reference released, the bytes must not be used any more.
+type Buffer struct {
+	B     []byte
+	refs  int32
+	class int // the size class, -1 if not pooled.
+}
+
+// AllocBuffer get a buffer of size from pool, the reference count is 1.
+func AllocBuffer(size uint32) *Buffer {
+	class := bufferClass(size)
+	if class < 0 {
+		return &Buffer{
+			B:     make([]byte, size),
+			refs:  1,
+			class: -1,
+		}
+	}
+
+	b, ok := bufferPools[class].Get().(*Buffer)
+	if !ok {
+		b = &Buffer{
+			B:     make([]byte, bufferClassSizes[class]),
+			class: class,
+		}
+	}
+
+	b.B = b.B[:size]
+	b.refs = 1
+
+	return b
+}
+
+// bufferClass the size class of size, -1 if too large.
+func bufferClass(size uint32) int {
+	class := sort.Search(len(bufferClassSizes), func(i int) bool {
+		return bufferClassSizes[i] >= size
+	})
+
+	if class >= len(bufferClassSizes) {
+		return -1
+	}
+
+	return class
+}
+
+// Retain add a reference of buffer, nil is ok.
+func (b *Buffer) Retain() {
+	if nil == b {
+		return
+	}
+
+	atomic.AddInt32(&b.refs, 1)
+}
+
+// Release release a reference of buffer, put it back to pool if no reference, nil is ok.
+// panic if released more than retained, which means the bytes may be used after put back to pool.
+func (b *Buffer) Release() {
+	if nil == b {
+		return
+	}
+
+	refs := atomic.AddInt32(&b.refs, -1)
+	if refs > 0 {
+		return
+	}
+
+	if refs < 0 {
+		panic(fmt.Sprintf("buffer released more than retained, refs=%d", refs))
+	}
+
+	if b.class >= 0 {
+		b.B = b.B[:cap(b.B)]
+		bufferPools[b.class].Put(b)
+	}
+}
@@ -0,0 +1,109 @@
+package kernel
+
+import (
+	"testing"
+)
+
+func TestBufferClass(t *testing.T) {
+	for _, v := range []struct {
+		size  uint32
+		class uint32 // the size of class, 0 if not pooled.
+	}{
+		{0, 512},
+		{1, 512},
+		{512, 512},
+		{513, 640},
+		{640, 640},
+		{641, 768},
+		{1024, 1024},
+		{1025, 1280},
+		{100 * 1024, 112 * 1024},
+		{maxBufferClassSize - 1, maxBufferClassSize},
+		{maxBufferClassSize, maxBufferClassSize},
+		{maxBufferClassSize + 1, 0},
+	} {
+		b := AllocBuffer(v.size)
+
+		if int(v.size) != len(b.B) {
+			t.Errorf("size=%d, len=%d", v.size, len(b.B))
+		}
+
+		if 0 == v.class && -1 != b.class {
+			t.Errorf("size=%d should not be pooled, class=%d", v.size, b.class)
+		} else if 0 != v.class && (b.class < 0 || v.class != bufferClassSizes[b.class] || int(v.class) != cap(b.B)) {
+			t.Errorf("size=%d, class=%d, cap=%d, expect %d", v.size, b.class, cap(b.B), v.class)
+		}
+
+		b.Release()
+	}
+
+	// the classes are sorted, and waste at most 1/4 space.
+	for i := 1; i < len(bufferClassSizes); i++ {
+		if prev, size := bufferClassSizes[i-1], bufferClassSizes[i]; size <= prev || size-prev > prev/4 {
+			t.Errorf("class %d size=%d, prev=%d", i, size, prev)
+		}
+	}
+}
+
+func TestBufferRefs(t *testing.T) {
+	b := AllocBuffer(1000)
+	b.Retain()
+	b.Retain()
+
+	// put back to pool only when the last reference released.
+	for i := 0; i < 2; i++ {
+		b.Release()
+
+		if 1000 != len(b.B) || 2-i != int(b.refs) {
+			t.Fatalf("released %d, len=%d, refs=%d", i+1, len(b.B), b.refs)
+		}
+	}
+
+	b.Release()
+	if cap(b.B) != len(b.B) || 0 != b.refs {
+		t.Fatalf("not put back to pool, len=%d, refs=%d", len(b.B), b.refs)
+	}
+
+	// the nil is not from pool.
+	var nb *Buffer
+	nb.Retain()
+	nb.Release()
+}
+
+func TestBufferOverRelease(t *testing.T) {
+	for _, size := range []uint32{1000, maxBufferClassSize + 1} {
+		b := AllocBuffer(size)
+		b.Release()
+
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Errorf("size=%d released twice, should panic", size)
+				}
+			}()
+
+			b.Release()
+		}()
+	}
+}
+
+func BenchmarkAllocBuffer(b *testing.B) {
+	for _, v := range []struct {
+		name string
+		size uint32
+	}{
+		{"audio", 400},
+		{"video", 40 * 1024},
+	} {
+		b.Run(v.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				buf := AllocBuffer(v.size)
+				buf.Retain()
+				buf.Release()
+				buf.Release()
+			}
+		})
+	}
+}
@@ -1,23 +1,61 @@
 package kernel
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"seal/metrics"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	// recvBufferSize the size of recv buffer, the small fields of chunk header are read from it,
+	// and the large payload is read directly from socket.
+	recvBufferSize = 8 * 1024
+	// sendBufferSize the bytes buffered to send, flushed when exceed.
+	sendBufferSize = 8 * 1024
+)
+
+// ErrInterrupted the recv is interrupted, e.g. the server is shutting down.
+var ErrInterrupted = errors.New("socket recv interrupted")
+
 // TCPSock socket
 type TCPSock struct {
 	net.Conn
 	recvTimeOut  uint32
 	sendTimeOut  uint32
 	recvBytesSum uint64
+	sendBytesSum uint64
+
+	// reader buffered recv, alloc when recv first time.
+	reader *bufio.Reader
+
+	// sendLock protect the send buffer, the send and recv may be in different goroutines,
+	// and the recv flushes the send buffer before waiting for peer.
+	sendLock sync.Mutex
+	sendBuf  []uint8
+	// sendPending the bytes in send buffer, read without lock.
+	sendPending int32
+	// interrupted the recv is interrupted, the send is still ok.
+	interrupted int32
+
+	// RecvCounter and SendCounter count the bytes of all connections of server, ignored if nil.
+	RecvCounter *metrics.Counter
+	SendCounter *metrics.Counter
 }
 
 // GetRecvBytesSum return the recv bytes of conn
 func (conn *TCPSock) GetRecvBytesSum() uint64 {
-	return conn.recvBytesSum
+	return atomic.LoadUint64(&conn.recvBytesSum)
+}
+
+// GetSendBytesSum return the send bytes of conn
+func (conn *TCPSock) GetSendBytesSum() uint64 {
+	return atomic.LoadUint64(&conn.sendBytesSum)
 }
 
 // SetRecvTimeout set tcp socket recv timeout
@@ -30,37 +68,152 @@ func (conn *TCPSock) SetSendTimeout(timeoutUs uint32) {
 	conn.sendTimeOut = timeoutUs
 }
 
-// ExpectBytesFull recv exactly size or error
+// ExpectBytesFull recv exactly size or error,
+// read from the recv buffer, only recv from socket when the buffer is empty.
 func (conn *TCPSock) ExpectBytesFull(buf []uint8, size uint32) (err error) {
 
-	if err = conn.SetDeadline(time.Now().Add(time.Duration(conn.recvTimeOut) * time.Microsecond)); err != nil {
-		return
+	if nil == conn.reader {
+		conn.reader = bufio.NewReaderSize(sockReader{conn}, recvBufferSize)
 	}
 
 	var n int
-	if n, err = io.ReadFull(conn.Conn, buf[:size]); err != nil {
+	if n, err = io.ReadFull(conn.reader, buf[:size]); err != nil {
 		return
 	}
 
-	conn.recvBytesSum += uint64(n)
+	atomic.AddUint64(&conn.recvBytesSum, uint64(n))
+	conn.RecvCounter.Add(uint64(n))
+
+	return
+}
+
+// sockReader recv from socket with the recv timeout,
+// and flush the send buffer first, for the peer may wait for it before sending.
+type sockReader struct {
+	conn *TCPSock
+}
+
+func (r sockReader) Read(p []byte) (n int, err error) {
+	conn := r.conn
+
+	if 0 != atomic.LoadInt32(&conn.sendPending) {
+		if err = conn.Flush(); err != nil {
+			return
+		}
+	}
+
+	if err = conn.SetReadDeadline(time.Now().Add(time.Duration(conn.recvTimeOut) * time.Microsecond)); err != nil {
+		return
+	}
+
+	// check after the deadline set, or the deadline of Interrupt wakeup the read.
+	if 0 != atomic.LoadInt32(&conn.interrupted) {
+		err = ErrInterrupted
+		return
+	}
+
+	return conn.Conn.Read(p)
+}
+
+// Interrupt wakeup the recv blocked, and the later recvs fail with ErrInterrupted,
+// the send is still ok, e.g. to notify the peer before close.
+func (conn *TCPSock) Interrupt() {
+	atomic.StoreInt32(&conn.interrupted, 1)
+	conn.SetReadDeadline(time.Now())
+}
+
+// BufferBytes copy buf to the send buffer, which is sent when full, flushed,
+// with the next SendBytes or SendBuffers, or before waiting for peer to recv.
+func (conn *TCPSock) BufferBytes(buf []uint8) (err error) {
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
+
+	conn.sendBuf = append(conn.sendBuf, buf...)
+	atomic.StoreInt32(&conn.sendPending, int32(len(conn.sendBuf)))
+
+	if len(conn.sendBuf) >= sendBufferSize {
+		err = conn.writeBuffers(nil)
+	}
 
 	return
 }
 
-// SendBytes send buf
+// Flush send the bytes in send buffer.
+func (conn *TCPSock) Flush() (err error) {
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
+
+	return conn.writeBuffers(nil)
+}
+
+// SendBytes send buf, after the bytes in send buffer.
 func (conn *TCPSock) SendBytes(buf []uint8) (err error) {
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
+
+	bufs := net.Buffers{buf}
+	return conn.writeBuffers(&bufs)
+}
+
+// SendBuffers send the buffers in one writev if supported, e.g. the chunks of msgs,
+// after the bytes in send buffer. the buffers are consumed.
+func (conn *TCPSock) SendBuffers(bufs *net.Buffers) (err error) {
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
+
+	return conn.writeBuffers(bufs)
+}
+
+// writeBuffers send the send buffer and bufs in one writev, must hold the send lock.
+func (conn *TCPSock) writeBuffers(bufs *net.Buffers) (err error) {
+
+	var all net.Buffers
+	var size int64
 
-	if err = conn.SetDeadline(time.Now().Add(time.Duration(conn.sendTimeOut) * time.Microsecond)); err != nil {
+	if len(conn.sendBuf) > 0 {
+		all = append(all, conn.sendBuf)
+		size += int64(len(conn.sendBuf))
+	}
+
+	if nil != bufs {
+		for _, v := range *bufs {
+			size += int64(len(v))
+		}
+
+		if 0 == len(all) {
+			all = *bufs
+		} else {
+			all = append(all, *bufs...)
+		}
+	}
+
+	if 0 == size {
 		return
 	}
 
-	var n int
-	if n, err = conn.Conn.Write(buf); err != nil {
+	if err = conn.SetWriteDeadline(time.Now().Add(time.Duration(conn.sendTimeOut) * time.Microsecond)); err != nil {
+		return
+	}
+
+	var n int64
+	n, err = all.WriteTo(conn.Conn)
+	atomic.AddUint64(&conn.sendBytesSum, uint64(n))
+	conn.SendCounter.Add(uint64(n))
+
+	// the send buffer is dropped when failed, the conn is unusable any more.
+	conn.sendBuf = conn.sendBuf[:0]
+	atomic.StoreInt32(&conn.sendPending, 0)
+
+	if nil != bufs {
+		*bufs = (*bufs)[len(*bufs):]
+	}
+
+	if err != nil {
 		return
 	}
 
-	if n != len(buf) {
-		err = fmt.Errorf("tcp sock, send bytes error, need send %d, actually send %d", len(buf), n)
+	if n != size {
+		err = fmt.Errorf("tcp sock, send bytes error, need send %d, actually send %d", size, n)
 		return
 	}
 
@@ -0,0 +1,132 @@
+package kernel
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+// benchPair the sockets of both sides of a loopback tcp connection.
+func benchPair(b *testing.B) (c *TCPSock, peer net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	c = &TCPSock{Conn: conn}
+	c.SetRecvTimeout(10 * 1000 * 1000)
+	c.SetSendTimeout(10 * 1000 * 1000)
+	peer = <-accepted
+
+	b.Cleanup(func() {
+		c.Close()
+		peer.Close()
+	})
+
+	return
+}
+
+// the fields of a chunk header(fmt 0) and the payload of a 128 bytes chunk.
+var benchFields = []uint32{1, 3, 3, 1, 4, 128}
+
+// BenchmarkRecvFields recv the small fields of chunks, from the recv buffer or the socket directly.
+func BenchmarkRecvFields(b *testing.B) {
+	var size int
+	for _, v := range benchFields {
+		size += int(v)
+	}
+
+	for _, buffered := range []bool{true, false} {
+		name := "socket"
+		if buffered {
+			name = "buffered"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			c, peer := benchPair(b)
+
+			go func() {
+				data := make([]byte, size*64)
+				for {
+					if _, err := peer.Write(data); err != nil {
+						return
+					}
+				}
+			}()
+
+			buf := make([]byte, 128)
+
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				for _, v := range benchFields {
+					var err error
+					if buffered {
+						err = c.ExpectBytesFull(buf, v)
+					} else {
+						_, err = io.ReadFull(c.Conn, buf[:v])
+					}
+
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSendPackets send the small protocol packets, e.g. ack and user control,
+// copied to the send buffer and flushed every 16 packets, or sent to the socket one by one.
+func BenchmarkSendPackets(b *testing.B) {
+	for _, buffered := range []bool{true, false} {
+		name := "socket"
+		if buffered {
+			name = "buffered"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			c, peer := benchPair(b)
+
+			go io.Copy(ioutil.Discard, peer)
+
+			pkt := make([]byte, 16)
+
+			b.SetBytes(int64(len(pkt)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				var err error
+				if buffered {
+					if err = c.BufferBytes(pkt); nil == err && 15 == i%16 {
+						err = c.Flush()
+					}
+				} else {
+					err = c.SendBytes(pkt)
+				}
+
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			if err := c.Flush(); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
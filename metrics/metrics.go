@@ -0,0 +1,300 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// collector the metric which can write itself in prometheus text format.
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry the metrics to expose, in the order registered, e.g. the metrics of a server.
+type Registry struct {
+	collectors []collector
+	lock       sync.Mutex
+}
+
+func (r *Registry) register(c collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteText write all metrics in prometheus text format, e.g. for the /metrics api.
+func (r *Registry) WriteText(w io.Writer) (err error) {
+	r.lock.Lock()
+	collectors := r.collectors
+	r.lock.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+
+	return bw.Flush()
+}
+
+// ContentType the content type of prometheus text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Counter the value only increase, e.g. the accepted connections.
+type Counter struct {
+	value uint64
+	name  string
+	help  string
+}
+
+// NewCounter create and register a counter.
+func (r *Registry) NewCounter(name string, help string) *Counter {
+	c := &Counter{
+		name: name,
+		help: help,
+	}
+	r.register(c)
+
+	return c
+}
+
+// Inc increase the counter by 1, nothing if c is nil.
+func (c *Counter) Inc() {
+	if nil == c {
+		return
+	}
+
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Add increase the counter by n, nothing if c is nil.
+func (c *Counter) Add(n uint64) {
+	if nil == c {
+		return
+	}
+
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value the current value of counter.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", c.name, c.Value())
+}
+
+// CounterVec the counters with one label, e.g. the handshake failures of type simple or complex.
+type CounterVec struct {
+	name   string
+	help   string
+	label  string
+	values map[string]*uint64
+	lock   sync.RWMutex
+}
+
+// NewCounterVec create and register a counter vec,
+// the values of label to be exposed as 0 before increased.
+func (r *Registry) NewCounterVec(name string, help string, label string, values ...string) *CounterVec {
+	c := &CounterVec{
+		name:   name,
+		help:   help,
+		label:  label,
+		values: make(map[string]*uint64),
+	}
+
+	for _, v := range values {
+		c.values[v] = new(uint64)
+	}
+
+	r.register(c)
+
+	return c
+}
+
+// Inc increase the counter of label value by 1, nothing if c is nil.
+func (c *CounterVec) Inc(value string) {
+	if nil == c {
+		return
+	}
+
+	c.lock.RLock()
+	p, ok := c.values[value]
+	c.lock.RUnlock()
+
+	if !ok {
+		c.lock.Lock()
+		if p, ok = c.values[value]; !ok {
+			p = new(uint64)
+			c.values[value] = p
+		}
+		c.lock.Unlock()
+	}
+
+	atomic.AddUint64(p, 1)
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, v := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", c.name, c.label, escape(v), atomic.LoadUint64(c.values[v]))
+	}
+}
+
+// GaugeVec the gauges with labels, the values are collected when exposed,
+// e.g. the active publishers of each vhost and app.
+type GaugeVec struct {
+	name    string
+	help    string
+	labels  []string
+	collect func() []GaugeValue
+}
+
+// GaugeValue the value of gauge, with the values of labels in the order of labels.
+type GaugeValue struct {
+	Labels []string
+	Value  float64
+}
+
+// NewGaugeVec create and register a gauge vec, collect return the value of each label values.
+func (r *Registry) NewGaugeVec(name string, help string, labels []string, collect func() []GaugeValue) *GaugeVec {
+	g := &GaugeVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		collect: collect,
+	}
+	r.register(g)
+
+	return g
+}
+
+func (g *GaugeVec) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+
+	values := g.collect()
+	sort.Slice(values, func(i, j int) bool {
+		return strings.Join(values[i].Labels, "\n") < strings.Join(values[j].Labels, "\n")
+	})
+
+	for _, v := range values {
+		pairs := make([]string, len(g.labels))
+		for i, label := range g.labels {
+			var value string
+			if i < len(v.Labels) {
+				value = v.Labels[i]
+			}
+
+			pairs[i] = fmt.Sprintf("%s=\"%s\"", label, escape(value))
+		}
+
+		fmt.Fprintf(w, "%s{%s} %s\n", g.name, strings.Join(pairs, ","), formatFloat(v.Value))
+	}
+}
+
+// Histogram count the observed values in buckets, e.g. the duration of hls segments.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64 // the upper bounds, sorted.
+	counts  []uint64  // the count of each bucket, not cumulative.
+	count   uint64
+	sum     float64
+	lock    sync.Mutex
+}
+
+// NewHistogram create and register a histogram with the upper bounds of buckets.
+func (r *Registry) NewHistogram(name string, help string, buckets []float64) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		buckets: append([]float64{}, buckets...),
+		counts:  make([]uint64, len(buckets)),
+	}
+	sort.Float64s(h.buckets)
+
+	r.register(h)
+
+	return h
+}
+
+// Observe add a value to histogram, nothing if h is nil.
+func (h *Histogram) Observe(v float64) {
+	if nil == h {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i]++
+	}
+
+	h.count++
+	h.sum += v
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	writeHeader(w, h.name, h.help, "histogram")
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var cumulative uint64
+	for i, le := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(le), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+func writeHeader(w *bufio.Writer, name string, help string, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escape the label value, the backslash, double quote and line feed must be escaped.
+func escape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func sortedKeys(m interface{}) (keys []string) {
+	switch v := m.(type) {
+	case map[string]*uint64:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]float64:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return
+}
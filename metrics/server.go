@@ -0,0 +1,76 @@
+package metrics
+
+// Server the metrics of a seal server, each server has its own,
+// the active publishers and players are registered by rtmp/co.
+type Server struct {
+	*Registry
+
+	// ConnsAccepted the rtmp connections accepted, include rtmps.
+	ConnsAccepted *Counter
+
+	// HandshakeFailures the rtmp handshake failures, type is simple, complex or unknown(failed before c1 parsed).
+	HandshakeFailures *CounterVec
+
+	// RecvBytes the bytes received of all rtmp connections.
+	RecvBytes *Counter
+
+	// SendBytes the bytes sent of all rtmp connections.
+	SendBytes *Counter
+
+	// ConsumerDrops the msgs dropped for the consumer is too slow, overwritten in the ring of source.
+	ConsumerDrops *Counter
+
+	// GopCacheClears the gop cache cleared, when pure audio guessed or the last gop exceed the limits.
+	GopCacheClears *Counter
+
+	// GopCacheTrims the oldest gops dropped from gop cache, when got key frame or exceed the limits.
+	GopCacheTrims *Counter
+
+	// HlsSegments the hls segments reaped.
+	HlsSegments *Counter
+
+	// HlsSegmentDuration the duration in seconds of hls segments reaped.
+	HlsSegmentDuration *Histogram
+
+	// M3u8RefreshErrors the m3u8 refresh failures.
+	M3u8RefreshErrors *Counter
+}
+
+// NewServer create the metrics of a server, registered in the registry of server.
+func NewServer() *Server {
+	r := &Registry{}
+
+	return &Server{
+		Registry: r,
+
+		ConnsAccepted: r.NewCounter("seal_rtmp_connections_accepted_total",
+			"The rtmp connections accepted."),
+
+		HandshakeFailures: r.NewCounterVec("seal_rtmp_handshake_failures_total",
+			"The rtmp handshake failures.", "type", "simple", "complex"),
+
+		RecvBytes: r.NewCounter("seal_rtmp_received_bytes_total",
+			"The bytes received of rtmp connections."),
+
+		SendBytes: r.NewCounter("seal_rtmp_sent_bytes_total",
+			"The bytes sent of rtmp connections."),
+
+		ConsumerDrops: r.NewCounter("seal_consumer_dropped_msgs_total",
+			"The msgs dropped for the consumer is too slow."),
+
+		GopCacheClears: r.NewCounter("seal_gop_cache_clears_total",
+			"The gop cache clears."),
+
+		GopCacheTrims: r.NewCounter("seal_gop_cache_trims_total",
+			"The gop cache trims of the oldest gops."),
+
+		HlsSegments: r.NewCounter("seal_hls_segments_total",
+			"The hls segments reaped."),
+
+		HlsSegmentDuration: r.NewHistogram("seal_hls_segment_duration_seconds",
+			"The duration of hls segments reaped.", []float64{1, 2, 4, 6, 8, 10, 15, 20, 30}),
+
+		M3u8RefreshErrors: r.NewCounter("seal_hls_m3u8_refresh_errors_total",
+			"The m3u8 refresh errors."),
+	}
+}
@@ -0,0 +1,128 @@
+package co
+
+import (
+	"seal/rtmp/pt"
+)
+
+// kick disconnect the connection from outside, e.g. by admin api,
+// the Cycle of connection will quit and clean.
+func (rc *RtmpConn) kick() {
+	if c := rc.loadState().consumer; nil != c {
+		c.Kick()
+	}
+
+	if err := rc.tcpConn.Close(); err != nil {
+		rc.logger.Println("kick connection, close socket err=", err)
+	}
+}
+
+// Kick disconnect the rtmp connection or stop the http-flv player of id, false if not found.
+func (h *ConnHub) Kick(id uint64) bool {
+	h.lock.RLock()
+	rc := h.hub[id]
+	fs := h.flvs[id]
+	h.lock.RUnlock()
+
+	if nil != fs {
+		fs.kick()
+		return true
+	}
+
+	if nil == rc {
+		return false
+	}
+
+	rc.kick()
+	rc.logger.Println("kick connection, id=", id, ", remote=", rc.tcpConn.RemoteAddr())
+
+	return true
+}
+
+// KickAll disconnect all the rtmp connections and stop the http-flv players, e.g. when server shutdown.
+func (h *ConnHub) KickAll() {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for _, rc := range h.hub {
+		rc.kick()
+	}
+
+	for _, fs := range h.flvs {
+		fs.kick()
+	}
+}
+
+// kickPublisher disconnect the connections which publish to the source.
+func (h *ConnHub) kickPublisher(source *SourceStream) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for _, rc := range h.hub {
+		st := rc.loadState()
+		if source != st.source {
+			continue
+		}
+
+		switch st.role {
+		case pt.RtmpRoleFMLEPublisher, pt.RtmpRoleFlashPublisher, pt.RtmpRolePuller:
+			rc.kick()
+		}
+	}
+}
+
+// kickPlayers ask all the players of source to stop playing, return the count of players.
+// the internal consumers like forwarder are not kicked.
+func (s *SourceStream) kickPlayers() (n int) {
+	s.consumerLock.RLock()
+	defer s.consumerLock.RUnlock()
+
+	for c := range s.consumers {
+		if c.internal {
+			continue
+		}
+
+		c.Kick()
+		n++
+	}
+
+	return
+}
+
+// KickPlayers ask all the players of the stream to stop playing,
+// return the count of players, false if stream not found.
+func (s *SourceHub) KickPlayers(key string) (n int, ok bool) {
+	s.lock.RLock()
+	source := s.hub[key]
+	s.lock.RUnlock()
+
+	if nil == source {
+		return
+	}
+
+	n = source.kickPlayers()
+	s.logger.Println("kick players of stream=", key, ", players=", n)
+
+	return n, true
+}
+
+// Drop force unpublish the stream, disconnect the publisher and players,
+// and delete the source, include the hls and forwarders, false if stream not found.
+func (s *SourceHub) Drop(key string) bool {
+	s.lock.Lock()
+	source := s.hub[key]
+	if nil != source {
+		s.remove(key)
+	}
+	s.lock.Unlock()
+
+	if nil == source {
+		return false
+	}
+
+	s.conns.kickPublisher(source)
+	source.kickPlayers()
+
+	s.logger.Println("drop stream=", key)
+
+	return true
+}
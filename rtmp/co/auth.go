@@ -0,0 +1,81 @@
+package co
+
+import (
+	"fmt"
+	"net/url"
+	"seal/auth"
+	"seal/rtmp/pt"
+)
+
+// authenticate check whether the client can do the action.
+func (rc *RtmpConn) authenticate(action string, params url.Values) (err error) {
+	info := auth.Info{
+		Action:     action,
+		Vhost:      rc.connInfo.vhost,
+		App:        rc.connInfo.app,
+		Stream:     rc.streamName,
+		Params:     params,
+		TcURL:      rc.connInfo.tcURL,
+		PageURL:    rc.connInfo.pageURL,
+		RemoteAddr: rc.tcpConn.Conn.RemoteAddr().String(),
+	}
+
+	return rc.sources.auth.Check(&info)
+}
+
+// streamWithToken the stream name with the token, e.g. test?token=xxx
+func streamWithToken(stream string, token string) string {
+	if 0 == len(token) {
+		return stream
+	}
+
+	return stream + pt.TokenStr + token
+}
+
+// rejectConnect response connect with NetConnection.Connect.Rejected,
+// and return the error to close the connection.
+func (rc *RtmpConn) rejectConnect(reason error) (err error) {
+	var pkt pt.ConnectResPacket
+	pkt.CommandName = pt.RtmpAmf0CommandError
+	pkt.TransactionID = 1
+
+	if err = pkt.MarshalInfo(&pt.StatusInfo{
+		Level:       pt.StatusLevelError,
+		Code:        pt.StatusCodeConnectRejected,
+		Description: reason.Error(),
+	}); err != nil {
+		return
+	}
+
+	if err = rc.sendPacket(&pkt, 0); err != nil {
+		rc.logger.Println("response connect rejected failed, err=", err)
+		return
+	}
+
+	err = fmt.Errorf("connect rejected, app=%s, err=%v", rc.connInfo.app, reason)
+
+	return
+}
+
+// rejectStream response publish or play with the error status, e.g. NetStream.Publish.BadName,
+// and return the error to close the connection.
+func (rc *RtmpConn) rejectStream(code string, reason error) (err error) {
+	var pkt pt.OnStatusCallPacket
+	pkt.CommandName = pt.RtmpAmf0CommandOnStatus
+	if err = pkt.Marshal(&pt.StatusInfo{
+		Level:       pt.StatusLevelError,
+		Code:        code,
+		Description: reason.Error(),
+	}); err != nil {
+		return
+	}
+
+	if err = rc.sendPacket(&pkt, uint32(rc.defaultStreamID)); err != nil {
+		rc.logger.Println("response stream rejected failed, err=", err)
+		return
+	}
+
+	err = fmt.Errorf("stream=%s rejected, code=%s, err=%v", rc.getSourceKey(), code, reason)
+
+	return
+}
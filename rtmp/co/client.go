@@ -0,0 +1,340 @@
+package co
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"seal/rtmp/pt"
+	"strings"
+	"time"
+
+	"github.com/calabashdad/utiltools"
+)
+
+// rtmpURL the parsed rtmp url.
+// e.g. rtmp://127.0.0.1:1935/live/test?token=xxx
+// host is 127.0.0.1:1935, tcUrl is rtmp://127.0.0.1:1935/live,
+// app is live, stream is test, param is token=xxx.
+// the param vhost is also in tcUrl, e.g. rtmp://127.0.0.1:1935/live?vhost=xxx
+type rtmpURL struct {
+	host   string
+	tcURL  string
+	app    string
+	stream string
+	param  string
+}
+
+func parseRtmpURL(s string) (u *rtmpURL, err error) {
+	var pu *url.URL
+	if pu, err = url.Parse(s); err != nil {
+		return
+	}
+
+	if "rtmp" != pu.Scheme {
+		err = fmt.Errorf("url scheme is not rtmp, url=%s", s)
+		return
+	}
+
+	p := strings.Trim(pu.Path, "/")
+	i := strings.LastIndex(p, "/")
+	if i <= 0 || i == len(p)-1 {
+		err = fmt.Errorf("rtmp url must be rtmp://host[:port]/app/stream, url=%s", s)
+		return
+	}
+
+	u = &rtmpURL{
+		host:   pu.Host,
+		app:    p[:i],
+		stream: p[i+1:],
+		param:  pu.RawQuery,
+	}
+
+	if 0 == len(pu.Port()) {
+		u.host = net.JoinHostPort(pu.Hostname(), rtmpDefaultPort)
+	}
+
+	u.tcURL = "rtmp://" + pu.Host + "/" + u.app
+
+	// the vhost of remote, e.g. rtmp://127.0.0.1/live/test?vhost=xxx
+	if v := pu.Query().Get("vhost"); 0 != len(v) {
+		u.tcURL += "?vhost=" + v
+	}
+
+	return
+}
+
+// streamWithParam the stream name with param, e.g. test?token=xxx
+func (u *rtmpURL) streamWithParam() string {
+	if 0 == len(u.param) {
+		return u.stream
+	}
+
+	return u.stream + "?" + u.param
+}
+
+// dialRtmp dial the remote rtmp server, and do the client handshake,
+// the connection belongs to the hub of sources.
+func dialRtmp(sources *SourceHub, host string) (rc *RtmpConn, err error) {
+	timeout := time.Duration(sources.Config().Rtmp.TimeOut) * time.Second
+
+	var c net.Conn
+	if c, err = net.DialTimeout("tcp", host, timeout); err != nil {
+		return
+	}
+
+	rc = sources.NewRtmpConnection(c)
+	rc.tcpConn.SetRecvTimeout(sources.Config().Rtmp.TimeOut * 1000 * 1000)
+	rc.tcpConn.SetSendTimeout(sources.Config().Rtmp.TimeOut * 1000 * 1000)
+
+	if err = rc.clientHandShake(); err != nil {
+		rc.tcpConn.Close()
+		rc = nil
+		return
+	}
+
+	return
+}
+
+// connectApp send connect to remote and wait for the response.
+func (rc *RtmpConn) connectApp(u *rtmpURL) (err error) {
+	defer func() {
+		if err := recover(); err != nil {
+			rc.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	rc.connInfo.tcURL = u.tcURL
+	rc.connInfo.app = u.app
+	rc.storeState()
+
+	var pkt pt.ConnectPacket
+	pkt.CommandName = pt.RtmpAmf0CommandConnect
+	pkt.TransactionID = 1
+
+	if pkt.CommandObject, err = pt.MarshalObject(&connectRequestObject{
+		App:            u.app,
+		FlashVer:       "FMLE/3.0 (compatible; seal)",
+		TcURL:          u.tcURL,
+		Capabilities:   239,
+		AudioCodecs:    3575,
+		VideoCodecs:    252,
+		VideoFunction:  1,
+		ObjectEncoding: rc.connInfo.objectEncoding,
+	}); err != nil {
+		return
+	}
+
+	if err = rc.sendPacket(&pkt, 0); err != nil {
+		return
+	}
+
+	// set window ack size to remote.
+	var ack pt.SetWindowAckSizePacket
+	ack.AckowledgementWindowSize = 2500000
+	if err = rc.sendPacket(&ack, 0); err != nil {
+		return
+	}
+
+	var msg *pt.Message
+	if msg, err = rc.expectResult(pkt.TransactionID); err != nil {
+		return
+	}
+
+	p := pt.ConnectResPacket{}
+	if err = p.Decode(msg.Payload.Payload); err != nil {
+		rc.logger.Println("decode connect response failed, remote may reject the connect. err=", err)
+		return
+	}
+
+	rc.logger.Println("connect app success, tcUrl=", u.tcURL)
+
+	return
+}
+
+// createStream send createStream to remote, and use the stream id in response.
+func (rc *RtmpConn) createStream(transactionID float64) (err error) {
+	defer func() {
+		if err := recover(); err != nil {
+			rc.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	var pkt pt.CreateStreamPacket
+	pkt.CommandName = pt.RtmpAmf0CommandCreateStream
+	pkt.TransactionID = transactionID
+
+	if err = rc.sendPacket(&pkt, 0); err != nil {
+		return
+	}
+
+	var msg *pt.Message
+	if msg, err = rc.expectResult(pkt.TransactionID); err != nil {
+		return
+	}
+
+	p := pt.CreateStreamResPacket{}
+	if err = p.Decode(msg.Payload.Payload); err != nil {
+		return
+	}
+
+	rc.defaultStreamID = p.StreamID
+	rc.logger.Println("create stream success, stream id=", p.StreamID)
+
+	return
+}
+
+// play send play to remote, the stream data will come after.
+func (rc *RtmpConn) play(stream string) (err error) {
+	defer func() {
+		if err := recover(); err != nil {
+			rc.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	streamID := uint32(rc.defaultStreamID)
+
+	// SetBufferLength, 3000ms
+	if true {
+		var pkt pt.UserControlPacket
+		pkt.EventType = pt.SrcPCUCSetBufferLength
+		pkt.EventData = streamID
+		pkt.ExtraData = 3000
+
+		if err = rc.sendPacket(&pkt, 0); err != nil {
+			return
+		}
+	}
+
+	var pkt pt.PlayPacket
+	pkt.CommandName = pt.RtmpAmf0CommandPlay
+	pkt.StreamName = stream
+	pkt.Start = -2
+	pkt.Duration = -1
+	pkt.Reset = true
+
+	if err = rc.sendPacket(&pkt, streamID); err != nil {
+		return
+	}
+
+	rc.logger.Println("send play success, stream=", stream)
+
+	return
+}
+
+// publish send releaseStream, FCPublish, createStream and publish to remote like FMLE,
+// then wait for the NetStream.Publish.Start status.
+func (rc *RtmpConn) publish(stream string) (err error) {
+	defer func() {
+		if err := recover(); err != nil {
+			rc.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	// releaseStream
+	if true {
+		var pkt pt.FmleStartPacket
+		pkt.CommandName = pt.RtmpAmf0CommandReleaseStream
+		pkt.TransactionID = 2
+		pkt.StreamName = stream
+
+		if err = rc.sendPacket(&pkt, 0); err != nil {
+			return
+		}
+	}
+
+	// FCPublish
+	if true {
+		var pkt pt.FmleStartPacket
+		pkt.CommandName = pt.RtmpAmf0CommandFcPublish
+		pkt.TransactionID = 3
+		pkt.StreamName = stream
+
+		if err = rc.sendPacket(&pkt, 0); err != nil {
+			return
+		}
+	}
+
+	if err = rc.createStream(4); err != nil {
+		return
+	}
+
+	var pkt pt.PublishPacket
+	pkt.CommandName = pt.RtmpAmf0CommandPublish
+	pkt.TransactionID = 5
+	pkt.StreamName = stream
+	pkt.Type = "live"
+
+	if err = rc.sendPacket(&pkt, uint32(rc.defaultStreamID)); err != nil {
+		return
+	}
+
+	var msg *pt.Message
+	if msg, err = rc.expectAmf0Command(func(command string, transactionID float64) bool {
+		return pt.RtmpAmf0CommandOnStatus == command
+	}); err != nil {
+		return
+	}
+
+	p := pt.OnStatusCallPacket{}
+	if err = p.Decode(msg.Payload.Payload); err != nil {
+		return
+	}
+
+	var info pt.StatusInfo
+	if err = p.Unmarshal(&info); err != nil {
+		return
+	}
+
+	if pt.StatusCodePublishStart != info.Code {
+		err = fmt.Errorf("publish stream=%s failed, code=%s", stream, info.Code)
+		return
+	}
+
+	rc.logger.Println("publish success, stream=", stream)
+
+	return
+}
+
+// expectResult recv msgs until got the _result or _error of the transaction id.
+func (rc *RtmpConn) expectResult(transactionID float64) (msg *pt.Message, err error) {
+	return rc.expectAmf0Command(func(command string, tid float64) bool {
+		return (pt.RtmpAmf0CommandResult == command || pt.RtmpAmf0CommandError == command) && transactionID == tid
+	})
+}
+
+// expectAmf0Command recv msgs until got the amf0 command accepted by the filter,
+// the results of other requests are ignored, and
+// the other msgs are handled as usual, e.g. set chunk size, window ack size.
+func (rc *RtmpConn) expectAmf0Command(accept func(command string, transactionID float64) bool) (msg *pt.Message, err error) {
+	for {
+		msg = &pt.Message{}
+
+		if err = rc.recvMsg(&msg.Header, &msg.Payload); err != nil {
+			return
+		}
+
+		if msg.Header.IsAmf0Command() {
+			var offset uint32
+
+			command, _ := pt.Amf0ReadString(msg.Payload.Payload, &offset)
+			tid, _ := pt.Amf0ReadNumber(msg.Payload.Payload, &offset)
+
+			if accept(command, tid) {
+				return
+			}
+
+			if pt.RtmpAmf0CommandResult == command || pt.RtmpAmf0CommandError == command {
+				rc.logger.Println("ignore response of request, command=", command, ", transaction id=", tid)
+				msg.Release()
+				continue
+			}
+		}
+
+		err = rc.onRecvMsg(msg)
+		msg.Release()
+
+		if err != nil {
+			return
+		}
+	}
+}
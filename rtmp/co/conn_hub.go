@@ -0,0 +1,93 @@
+package co
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnHub the rtmp connections, include the clients and the connections to remote,
+// e.g. pull and forward, and the http-flv players.
+type ConnHub struct {
+	//key: the id of connection.
+	hub map[uint64]*RtmpConn
+	//key: the id of http-flv player, allocated as the rtmp connections.
+	flvs map[uint64]*FlvSession
+	lock sync.RWMutex
+}
+
+// FlvSession the http-flv player, listed and kicked by id as the rtmp connections.
+type FlvSession struct {
+	id         uint64
+	startTime  time.Time
+	remoteAddr string
+	vhost      string
+	app        string
+	stream     string
+	consumer   *Consumer
+	// cancel stop the playing, called when kicked.
+	cancel func()
+}
+
+// connLastID the last id of the connections, increase when a connection created.
+var connLastID uint64
+
+func nextConnID() uint64 {
+	return atomic.AddUint64(&connLastID, 1)
+}
+
+func (h *ConnHub) add(rc *RtmpConn) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.hub[rc.id] = rc
+}
+
+func (h *ConnHub) remove(rc *RtmpConn) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	delete(h.hub, rc.id)
+}
+
+// AddFlvSession register the http-flv player of stream which plays by the consumer,
+// cancel is called when the player is kicked, remove it by RemoveFlvSession when quit.
+func (h *ConnHub) AddFlvSession(remoteAddr string, vhost string, app string, stream string,
+	consumer *Consumer, cancel func()) *FlvSession {
+	fs := &FlvSession{
+		id:         nextConnID(),
+		startTime:  time.Now(),
+		remoteAddr: remoteAddr,
+		vhost:      vhost,
+		app:        app,
+		stream:     stream,
+		consumer:   consumer,
+		cancel:     cancel,
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.flvs[fs.id] = fs
+
+	return fs
+}
+
+// RemoveFlvSession unregister the http-flv player when it quit.
+func (h *ConnHub) RemoveFlvSession(fs *FlvSession) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	delete(h.flvs, fs.id)
+}
+
+// ID the id of the http-flv player.
+func (fs *FlvSession) ID() uint64 {
+	return fs.id
+}
+
+// kick stop the playing of http-flv player.
+func (fs *FlvSession) kick() {
+	fs.consumer.Kick()
+	fs.cancel()
+}
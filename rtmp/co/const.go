@@ -1,2 +1,29 @@
 package co
 
+const (
+	// rtmpDefaultPort the default port of rtmp, used when no port in url.
+	rtmpDefaultPort = "1935"
+
+	// pullRetryIntervalSeconds the interval to retry when pull stream from remote failed.
+	pullRetryIntervalSeconds = 3
+
+	// forwardRetryMinSeconds the first interval to reconnect when forward to remote failed,
+	// it will be doubled when failed again, until forwardRetryMaxSeconds.
+	forwardRetryMinSeconds = 1
+	// forwardRetryMaxSeconds the max interval to reconnect when forward to remote failed.
+	forwardRetryMaxSeconds = 30
+
+	// edgeIdleTimeoutSeconds the default seconds to stop pulling from origin after the last player left.
+	edgeIdleTimeoutSeconds = 10
+
+	// sourceRingSize the count of msgs in the ring of source shared by consumers,
+	// about 15 seconds for 25fps video and 44.1kHz aac, the slower consumers drop msgs.
+	sourceRingSize = 1024
+	// consumerBatchSize the max count of msgs read from ring once by consumer.
+	consumerBatchSize = 64
+
+	// playBatchMaxMsgs the max count of msgs sent to player in one writev.
+	playBatchMaxMsgs = 32
+	// playBatchMaxBytes the max bytes sent to player in one writev.
+	playBatchMaxBytes = 64 * 1024
+)
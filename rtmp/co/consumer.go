@@ -1,126 +1,308 @@
 package co
 
 import (
-	"log"
-	"seal/conf"
+	"seal/rtmp/flv"
 	"seal/rtmp/pt"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 //Consumer is the consumer of source
 type Consumer struct {
+	sendBytes      uint64 //bytes sent to the client, first field for 64 bits atomic align.
+	cursor         uint64 //the sequence of next msg to read from ring.
+	dropped        uint64 //the audio and video msgs dropped, for the consumer is too slow.
 	stream         string
-	queueSizeMills uint32
-	avStartTime    int64
-	avEndTime      int64
-	msgQuene       chan *pt.Message
+	queueSizeMills uint32        //the max duration of msgs queued, shrink the queue if exceed, set when created.
+	dropping       bool          //dropping the audio and video msgs until the next key frame.
+	hasVideo       bool          //whether got video, the pure audio stream has no key frame.
+	queue          []*pt.Message //the msgs only for this consumer, e.g. the cache of source, dumped before the msgs of ring.
+	queueLock      sync.Mutex
+	ring           *msgRing                       //the msgs of source shared by all consumers, set when created.
+	batch          [consumerBatchSize]*pt.Message //the msgs read from ring, not dumped yet.
+	batchIndex     int
+	batchSize      int
 	jitter         *pt.TimeJitter
+	dumped         []*pt.Message //the msgs dumped not released, their payloads may be still in use.
+	out            pt.Message    //the msg dumped, a copy of the shared msg with timestamp corrected for this consumer.
 	paused         bool
 	duration       float64
+	source         *SourceStream //the source of consumer, set when created.
+	internal       bool          //internal consumer, e.g. forwarder, not a player.
+	kicked         chan struct{} //closed when kicked by admin.
+	kickOnce       sync.Once
 }
 
 func NewConsumer(key string) *Consumer {
 	return &Consumer{
-		stream:         key,
-		queueSizeMills: conf.GlobalConfInfo.Rtmp.ConsumerQueueSize * 1000,
-		avStartTime:    -1,
-		avEndTime:      -1,
-		msgQuene:       make(chan *pt.Message, 4096),
-		jitter:         &pt.TimeJitter{},
+		stream: key,
+		jitter: &pt.TimeJitter{},
+		kicked: make(chan struct{}),
 	}
 }
 
+// Clean release the msgs not dumped and dumped.
 func (c *Consumer) Clean() {
-	close(c.msgQuene)
+	c.queueLock.Lock()
+	for _, v := range c.queue {
+		v.Release()
+	}
+	c.queue = nil
+	c.queueLock.Unlock()
+
+	for i := c.batchIndex; i < c.batchSize; i++ {
+		c.batch[i].Release()
+	}
+	c.batch = [consumerBatchSize]*pt.Message{}
+	c.batchIndex, c.batchSize = 0, 0
+
+	c.Release()
+}
+
+// Release release the msgs dumped, call it when their payloads are sent.
+func (c *Consumer) Release() {
+	for i, v := range c.dumped {
+		v.Release()
+		c.dumped[i] = nil
+	}
+
+	c.dumped = c.dumped[:0]
 }
 
-// Atc whether Atc, donot use jitter correct if true
-// tba timebase of audio. used to calc the audio time delta if time-jitter detected.
-// tbv timebase of video. used to calc the video time delta if time-jitter detected.
-func (c *Consumer) Enquene(msg *pt.Message, atc bool, tba float64, tbv float64, timeJitter uint32) {
+// Enquene enquene the msg only for this consumer, e.g. the cached gop,
+// the msgs published are shared by the ring of source, not enquened.
+func (c *Consumer) Enquene(msg *pt.Message) {
 
 	if nil == msg {
 		return
 	}
 
-	if !atc {
-		//c.jitter.Correct(msg, tba, tbv, timeJitter)
+	c.queueLock.Lock()
+	defer c.queueLock.Unlock()
+
+	msg.Retain()
+	c.queue = append(c.queue, msg)
+}
+
+// Dump the next msg to send, return nil immediately if there is no msg now,
+// use Wait to wait for the msgs.
+// the msg dumped is owned by the consumer and valid until the next Dump, the header can be changed,
+// its timestamp is corrected by the time jitter of consumer, unless the source is atc.
+// the payload is valid until Release.
+func (c *Consumer) Dump() (msg *pt.Message) {
+
+	if c.paused {
+		return
 	}
 
-	if msg.Header.IsVideo() || msg.Header.IsAudio() {
-		if -1 == c.avStartTime {
-			c.avStartTime = int64(msg.Header.Timestamp)
-		}
+	if msg = c.dequeue(); nil == msg {
+		msg = c.dumpRing()
+	}
 
-		c.avEndTime = int64(msg.Header.Timestamp)
+	if nil == msg {
+		return
 	}
 
-	select {
-	// incase block, and influence others.
-	case <-time.After(time.Duration(3) * time.Millisecond):
-		log.Println("enquene to channel timeout, channel may be full, key=", c.stream)
-		break
-	case c.msgQuene <- msg:
-		break
+	c.dumped = append(c.dumped, msg)
+
+	// the msg is shared by all consumers, never change it.
+	c.out = *msg
+
+	if s := c.source; nil != s && !s.Atc {
+		// tba timebase of audio. used to calc the audio time delta if time-jitter detected.
+		// tbv timebase of video. used to calc the video time delta if time-jitter detected.
+		c.out.Header.Timestamp = c.jitter.CorrectTimestamp(msg, s.SampleRate, s.FrameRate, s.TimeJitter)
 	}
+
+	return &c.out
 }
 
-func (c *Consumer) Dump() (msg *pt.Message) {
+// Wait wait until there may be msgs to dump, return false when timeout or kicked.
+func (c *Consumer) Wait(timeout time.Duration) bool {
+	if nil == c.ring {
+		return false
+	}
 
-	if c.paused {
-		log.Println("client paused now")
-		return
+	// get the notify before check, in case the msg written after check.
+	notify := c.ring.wait()
+
+	if c.available() {
+		return true
 	}
 
 	select {
-	case <-time.After(time.Duration(10) * time.Millisecond):
-		// in case block
+	case <-notify:
+		return true
+	case <-c.kicked:
+		return false
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// available whether there are msgs to dump now.
+func (c *Consumer) available() bool {
+	if c.paused {
+		return false
+	}
+
+	if c.batchIndex < c.batchSize {
+		return true
+	}
+
+	c.queueLock.Lock()
+	n := len(c.queue)
+	c.queueLock.Unlock()
+
+	return n > 0 || (nil != c.ring && c.ring.pending(atomic.LoadUint64(&c.cursor)) > 0)
+}
+
+// dequeue the msg only for this consumer, nil if empty.
+func (c *Consumer) dequeue() (msg *pt.Message) {
+	c.queueLock.Lock()
+	defer c.queueLock.Unlock()
+
+	if 0 == len(c.queue) {
 		return
-	case msg = <-c.msgQuene:
-		break
-	}
-
-	//for {
-	//	var msgLocal *pt.Message
-	//
-	//	select {
-	//	case <-time.After(time.Duration(10) * time.Millisecond):
-	//		// in case block
-	//		return
-	//	case msgLocal = <-c.msgQuene:
-	//		break
-	//	}
-	//
-	//	c.avStartTime = int64(msgLocal.Header.Timestamp)
-	//
-	//	if uint32(c.avEndTime-c.avStartTime) > c.queueSizeMills {
-	//
-	//		// for metadata, key frame, audio sequence header, we do not shrink it.
-	//		if msgLocal.Header.IsAmf0Data() ||
-	//			flv.VideoH264IsKeyframe(msgLocal.Payload.Payload) ||
-	//			flv.AudioIsSequenceHeader(msgLocal.Payload.Payload) {
-	//
-	//			msg = msgLocal
-	//			log.Printf("key=%s dump a frame even it's timestamp is too old. msg type=%d, msg time=%d, avStatrtTime=%d, queue len=%d\n",
-	//				c.stream, msg.Header.MessageType, msgLocal.Header.Timestamp, c.avStartTime, c.queueSizeMills)
-	//
-	//			return
-	//		} else {
-	//			// msg is too old, drop it directly, we store the latest i frame into cache already
-	//			continue
-	//		}
-	//	} else {
-	//		msg = msgLocal
-	//		return
-	//	}
-	//
-	//}
+	}
+
+	msg = c.queue[0]
+	c.queue[0] = nil
+	c.queue = c.queue[1:]
+
+	return
+}
+
+// dumpRing dump the msg from the batch read from ring, read a new batch if all dumped,
+// the msgs are dropped when shrinking the queue.
+func (c *Consumer) dumpRing() (msg *pt.Message) {
+	for c.batchIndex < c.batchSize || c.readBatch() {
+		msg = c.batch[c.batchIndex]
+		c.batch[c.batchIndex] = nil
+		c.batchIndex++
+
+		if !c.shouldDrop(msg) {
+			return
+		}
+
+		msg.Release()
+		atomic.AddUint64(&c.dropped, 1)
+		c.source.hub.metrics.ConsumerDrops.Inc()
+	}
+
+	return nil
+}
+
+// readBatch read a batch of msgs from ring, and start to shrink the queue
+// when the msgs are overwritten or the duration of msgs queued exceed the queue size.
+func (c *Consumer) readBatch() bool {
+	if nil == c.ring {
+		return false
+	}
+
+	n, next, lost, duration := c.ring.read(atomic.LoadUint64(&c.cursor), c.batch[:])
+	atomic.StoreUint64(&c.cursor, next)
+	c.batchIndex, c.batchSize = 0, n
+
+	// the pure audio stream has no key frame, drop batch by batch until the duration is ok.
+	if !c.hasVideo {
+		c.dropping = false
+	}
+
+	if lost > 0 {
+		atomic.AddUint64(&c.dropped, lost)
+		c.source.hub.metrics.ConsumerDrops.Add(lost)
+
+		c.source.hub.logger.Println("consumer is too slow, msgs overwritten=", lost, ", drop until next key frame, key=", c.stream)
+		c.dropping = true
+	}
+
+	if !c.dropping && c.queueSizeMills > 0 && duration > uint64(c.queueSizeMills) {
+		c.source.hub.logger.Printf("consumer queue duration=%dms exceed %dms, drop until next key frame, key=%s\n",
+			duration, c.queueSizeMills, c.stream)
+		c.dropping = true
+	}
+
+	return n > 0
+}
+
+// shouldDrop whether drop the msg when shrinking the queue,
+// the metadata and sequence headers are always kept, stop dropping when got the key frame
+// which the duration of msgs queued from is in the queue size.
+func (c *Consumer) shouldDrop(msg *pt.Message) bool {
+	isVideo := msg.Header.IsVideo()
+	if isVideo {
+		c.hasVideo = true
+	}
+
+	if !c.dropping {
+		return false
+	}
+
+	if !isVideo && !msg.Header.IsAudio() {
+		return false
+	}
+
+	if flv.VideoH264IsKeyFrameAndSequenceHeader(msg.Payload.Payload) || flv.AudioIsSequenceHeader(msg.Payload.Payload) {
+		return false
+	}
+
+	if isVideo && flv.VideoH264IsKeyframe(msg.Payload.Payload) {
+		if c.queueSizeMills > 0 && c.ring.lastTimestamp() > msg.Header.Timestamp+uint64(c.queueSizeMills) {
+			return true
+		}
+
+		c.dropping = false
+		c.source.hub.logger.Println("consumer got key frame, stop dropping, dropped=", atomic.LoadUint64(&c.dropped), ", key=", c.stream)
+		return false
+	}
+
+	return true
+}
+
+// queueDepth the count of msgs not dumped, for stats.
+func (c *Consumer) queueDepth() (n int) {
+	c.queueLock.Lock()
+	n = len(c.queue)
+	c.queueLock.Unlock()
+
+	if nil != c.ring {
+		n += int(c.ring.pending(atomic.LoadUint64(&c.cursor)))
+	}
 
 	return
 }
 
+// Kick ask the player of consumer to stop playing, e.g. by admin api.
+func (c *Consumer) Kick() {
+	c.kickOnce.Do(func() {
+		close(c.kicked)
+	})
+}
+
+// Kicked whether the consumer is kicked, the player should stop playing if true.
+func (c *Consumer) Kicked() bool {
+	select {
+	case <-c.kicked:
+		return true
+	default:
+	}
+
+	return false
+}
+
+// AddSendBytes count the bytes of msgs sent to the client.
+func (c *Consumer) AddSendBytes(n uint32) {
+	atomic.AddUint64(&c.sendBytes, uint64(n))
+
+	if nil != c.source {
+		atomic.AddUint64(&c.source.sendBytes, uint64(n))
+	}
+}
+
 func (c *Consumer) onPlayPause(isPause bool) (err error) {
 	c.paused = isPause
-	log.Println("consumer changed pause status to ", isPause)
+	c.source.hub.logger.Println("consumer changed pause status to ", isPause)
 	return
 }
@@ -0,0 +1,151 @@
+package co
+
+import (
+	"encoding/binary"
+	"reflect"
+	"seal/conf"
+	"seal/rtmp/pt"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yaml"
+)
+
+// testAudio a aac audio msg of index at timestamp ts, the index is in the payload.
+func testAudio(index int, ts uint32, sequenceHeader bool) *pt.Message {
+	p := []byte{0xaf, 0x01, 0, 0, 0, 0, 0, 0, 0}
+	if sequenceHeader {
+		p[1] = 0x00
+	}
+	binary.BigEndian.PutUint32(p[5:], uint32(index))
+
+	msg := &pt.Message{}
+	msg.Payload.Payload = p
+	msg.Header.InitializeAudio(uint32(len(p)), ts, 1)
+
+	return msg
+}
+
+// testMeta a metadata msg of index at timestamp ts, the index is in the payload.
+func testMeta(index int, ts uint32) *pt.Message {
+	p := []byte{0x02, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(p[5:], uint32(index))
+
+	msg := &pt.Message{}
+	msg.Payload.Payload = p
+	msg.Header.InitializeAmf0Script(uint32(len(p)), 1)
+	msg.Header.Timestamp = uint64(ts)
+
+	return msg
+}
+
+func TestConsumerShrink(t *testing.T) {
+	// 4s of av, the key frame every 800ms, with the sequence headers and metadata in the middle.
+	av := func(i int) *pt.Message {
+		switch {
+		case 0 == i:
+			msg := testVideo(i, true)
+			msg.Payload.Payload[1] = 0x00
+			return msg
+		case 1 == i || 40 == i:
+			return testAudio(i, uint32(i*40), true)
+		case 30 == i:
+			return testMeta(i, uint32(i*40))
+		case 1 == i%2:
+			return testAudio(i, uint32(i*40), false)
+		}
+
+		return testVideo(i, 2 == i%20)
+	}
+
+	// 4s of pure audio, 20ms per msg.
+	audio := func(i int) *pt.Message {
+		return testAudio(i, uint32(i*20), 0 == i)
+	}
+
+	seq := func(from, to int, v ...int) []int {
+		for i := from; i <= to; i++ {
+			v = append(v, i)
+		}
+		return v
+	}
+
+	for _, v := range []struct {
+		name      string
+		queueSize uint32
+		total     int
+		msg       func(i int) *pt.Message
+		dumped    []int
+	}{
+		// keep all when the queue is not limited.
+		{"unlimited", 0, 100, av, seq(0, 99)},
+		{"in queue size", 5, 100, av, seq(0, 99)},
+		// the key frames before 3280 are too old for the last 3960, stop dropping at 3280.
+		{"skip to key frame", 1, 100, av, seq(82, 99, 0, 1, 30, 40)},
+		// no key frame, drop the batches until the duration of batch is in the queue size.
+		{"pure audio", 1, 200, audio, seq(192, 199, 0)},
+	} {
+		c := &conf.Config{}
+		c.Rtmp.ConsumerQueueSize = v.queueSize
+		source := newTestSource(t, c)
+
+		consumer := NewConsumer(v.name)
+		source.CreateConsumer(consumer)
+
+		for i := 0; i < v.total; i++ {
+			source.copyToAllConsumers(v.msg(i), nil)
+		}
+
+		var got []int
+		for msg := consumer.Dump(); nil != msg; msg = consumer.Dump() {
+			got = append(got, testIndex(msg))
+		}
+		consumer.Release()
+
+		sort.Ints(v.dumped)
+		if !reflect.DeepEqual(v.dumped, got) {
+			t.Errorf("%s: dumped %v, expect %v", v.name, got, v.dumped)
+		}
+
+		if dropped := atomic.LoadUint64(&consumer.dropped); v.total != int(dropped)+len(got) {
+			t.Errorf("%s: dropped=%d, dumped=%d, total=%d", v.name, dropped, len(got), v.total)
+		}
+	}
+}
+
+func TestConsumerQueueSizeOfVhost(t *testing.T) {
+	c := &conf.Config{}
+	if err := yaml.Unmarshal([]byte(`
+rtmp:
+  consumerQueueSize: 5
+vhosts:
+  - name: v1
+    rtmp:
+      consumerQueueSize: 2
+    apps:
+      - name: low
+        rtmp:
+          consumerQueueSize: 1
+`), c); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := newTestHub(t, c)
+
+	for _, v := range []struct {
+		key       string
+		queueSize uint32
+	}{
+		{"live/test", 5000},
+		{"v1/live/test", 2000},
+		{"v1/low/test", 1000},
+	} {
+		consumer := NewConsumer(v.key)
+		hub.newSourceStream(v.key, "test").CreateConsumer(consumer)
+
+		if v.queueSize != consumer.queueSizeMills {
+			t.Errorf("%s: queue size=%dms, expect %dms", v.key, consumer.queueSizeMills, v.queueSize)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package co
 
 import (
+	"fmt"
 	"log"
 	"net"
+	"net/url"
 	"seal/conf"
+	"seal/hooks"
 	"seal/kernel"
 	"seal/rtmp/pt"
+	"sync/atomic"
+	"time"
 
 	"github.com/calabashdad/utiltools"
 )
@@ -16,15 +21,73 @@ type ackWindowSize struct {
 }
 
 type connectInfo struct {
+	vhost          string //the vhost configured of the host of tcUrl or param vhost.
 	tcURL          string
 	pageURL        string
 	swfURL         string
 	app            string
 	objectEncoding float64
+	params         url.Values //query params of app and tcUrl, e.g. token.
+}
+
+// connectCommandObject the command object of connect, only the fields used by server,
+// the others are ignored, for the clients may send them in any type, e.g. fpad as number.
+type connectCommandObject struct {
+	App            string  `amf:"app"`
+	TcURL          string  `amf:"tcUrl"`
+	PageURL        string  `amf:"pageUrl"`
+	SwfURL         string  `amf:"swfUrl"`
+	ObjectEncoding float64 `amf:"objectEncoding"`
+}
+
+// connectResponseProps the properties of connect response sent by server.
+type connectResponseProps struct {
+	FmsVer       string  `amf:"fmsVer"`
+	Capabilities float64 `amf:"capabilities"`
+	Mode         float64 `amf:"mode"`
+	pt.StatusInfo
+	ObjectEncoding float64 `amf:"objectEncoding"`
+	Version        string  `amf:"version"`
+	License        string  `amf:"seal_license"`
+	Authors        string  `amf:"seal_authors"`
+	Email          string  `amf:"seal_email"`
+	Copyright      string  `amf:"seal_copyright"`
+	Sig            string  `amf:"seal_sig"`
+}
+
+// streamMetadata the fields of metadata used by source.
+type streamMetadata struct {
+	AudioSampleRate float64     `amf:"audiosamplerate"`
+	FrameRate       float64     `amf:"framerate"`
+	BravoAtc        interface{} `amf:"bravo_atc"`
+}
+
+// serverMetadata the server info added to metadata.
+type serverMetadata struct {
+	Server  string `amf:"server"`
+	Primary string `amf:"primary"`
+	Author  string `amf:"author"`
+}
+
+// connectRequestObject the command object of connect sent by client.
+type connectRequestObject struct {
+	App            string  `amf:"app"`
+	FlashVer       string  `amf:"flashVer,omitempty"`
+	SwfURL         string  `amf:"swfUrl,omitempty"`
+	TcURL          string  `amf:"tcUrl"`
+	Fpad           bool    `amf:"fpad"`
+	Capabilities   float64 `amf:"capabilities"`
+	AudioCodecs    float64 `amf:"audioCodecs"`
+	VideoCodecs    float64 `amf:"videoCodecs"`
+	VideoFunction  float64 `amf:"videoFunction"`
+	PageURL        string  `amf:"pageUrl,omitempty"`
+	ObjectEncoding float64 `amf:"objectEncoding"`
 }
 
 // RtmpConn rtmp connection info
 type RtmpConn struct {
+	id              uint64    //the unique id of connection, for stats.
+	startTime       time.Time //the time connection created.
 	tcpConn         *kernel.TCPSock
 	chunkStreams    map[uint32]*pt.ChunkStream //key:cs id
 	inChunkSize     uint32                     //default 128, set by peer
@@ -34,18 +97,39 @@ type RtmpConn struct {
 	role            uint8              //publisher or player.
 	streamName      string
 	tokenStr        string        //token str for authentication. it's optional.
+	params          url.Values    //query params of stream and connect, e.g. token.
 	duration        float64       //for player.used to specified the stop when exceed the duration.
 	defaultStreamID float64       //default stream id for request.
 	connInfo        *connectInfo  //connect info.
+	stopping        int32         //stopped by server, e.g. shutdown, notify the client when clean.
+	sources         *SourceHub    //the hub of server which the connection belongs to.
+	logger          *log.Logger   //the logger of hub.
 	source          *SourceStream //data source info.
 	consumer        *Consumer     //for consumer, like player.
+	state           atomic.Value  //the *connState, read by other goroutines, e.g. admin api.
+}
+
+// connState the snapshot of role, vhost, app, stream, source and consumer of connection,
+// read by the admin api, metrics and shutdown in other goroutines.
+// it is not changed after stored, the connection stores a new one when changed.
+type connState struct {
+	role     uint8
+	vhost    string
+	app      string
+	stream   string
+	source   *SourceStream
+	consumer *Consumer
 }
 
-// NewRtmpConnection create rtmp conncetion
-func NewRtmpConnection(c net.Conn) *RtmpConn {
-	return &RtmpConn{
+// NewRtmpConnection create rtmp conncetion which belongs to the hub
+func (s *SourceHub) NewRtmpConnection(c net.Conn) *RtmpConn {
+	rc := &RtmpConn{
+		id:        nextConnID(),
+		startTime: time.Now(),
 		tcpConn: &kernel.TCPSock{
-			Conn: c,
+			Conn:        c,
+			RecvCounter: s.metrics.RecvBytes,
+			SendCounter: s.metrics.SendBytes,
 		},
 		chunkStreams: make(map[uint32]*pt.ChunkStream),
 		inChunkSize:  pt.RtmpDefalutChunkSize,
@@ -57,31 +141,78 @@ func NewRtmpConnection(c net.Conn) *RtmpConn {
 		role:            pt.RtmpRoleUnknown,
 		defaultStreamID: 1.0,
 		connInfo: &connectInfo{
+			vhost:          conf.DefaultVhost,
 			objectEncoding: pt.RtmpSigAmf0Ver,
 		},
+		sources: s,
+		logger:  s.logger,
 	}
+	rc.storeState()
+
+	return rc
+}
+
+// storeState store the snapshot of the state of connection,
+// called by the goroutine of connection after the role, vhost, app, stream, source or consumer changed.
+func (rc *RtmpConn) storeState() {
+	rc.state.Store(&connState{
+		role:     rc.role,
+		vhost:    rc.connInfo.vhost,
+		app:      rc.connInfo.app,
+		stream:   rc.streamName,
+		source:   rc.source,
+		consumer: rc.consumer,
+	})
+}
+
+// loadState the snapshot of the state of connection last stored, safe in any goroutine.
+func (rc *RtmpConn) loadState() *connState {
+	return rc.state.Load().(*connState)
 }
 
 // Cycle rtmp service cycle
 func (rc *RtmpConn) Cycle() {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	rc.tcpConn.SetRecvTimeout(conf.GlobalConfInfo.Rtmp.TimeOut * 1000 * 1000)
-	rc.tcpConn.SetSendTimeout(conf.GlobalConfInfo.Rtmp.TimeOut * 1000 * 1000)
+	rc.tcpConn.SetRecvTimeout(rc.sources.Config().Rtmp.TimeOut * 1000 * 1000)
+	rc.tcpConn.SetSendTimeout(rc.sources.Config().Rtmp.TimeOut * 1000 * 1000)
+
+	rc.sources.conns.add(rc)
+	defer rc.sources.conns.remove(rc)
 
 	var err error
 
 	if err = rc.handShake(); err != nil {
-		log.Println("rtmp handshake failed.err=", err)
+		rc.logger.Println("rtmp handshake failed.err=", err)
 		return
 	}
-	log.Println("rtmp handshake success.")
+	rc.logger.Println("rtmp handshake success.")
+
+	err = rc.recvCycle()
+
+	rc.logger.Println("rtmp cycle finished, begin clean.err=", err)
+
+	rc.clean()
+
+	if len(rc.connInfo.app) > 0 {
+		rc.sources.hooks.Notify(rc.hookEvent(hooks.OnClose, rc.connInfo.params))
+	}
 
+	rc.logger.Println("rtmp clean finished, remote=", rc.tcpConn.Conn.RemoteAddr())
+}
+
+// recvCycle recv msgs from peer and handle them, until error occur.
+func (rc *RtmpConn) recvCycle() (err error) {
 	for {
+		if 0 != atomic.LoadInt32(&rc.stopping) {
+			err = fmt.Errorf("connection is stopped by server, remote=%s", rc.tcpConn.RemoteAddr())
+			break
+		}
+
 		// notice that the payload has not alloced at init.
 		// one msg alloc once, and do not copy to improve performance.
 		msg := &pt.Message{}
@@ -90,36 +221,68 @@ func (rc *RtmpConn) Cycle() {
 			break
 		}
 
-		if err = rc.onRecvMsg(msg); err != nil {
+		err = rc.onRecvMsg(msg)
+
+		// who keeps the msg retains it, e.g. the ring and gop cache of source.
+		msg.Release()
+
+		if err != nil {
 			break
 		}
 
 	}
 
-	log.Println("rtmp cycle finished, begin clean.err=", err)
-
-	rc.clean()
-
-	log.Println("rtmp clean finished, remote=", rc.tcpConn.Conn.RemoteAddr())
+	return
 }
 
 func (rc *RtmpConn) getSourceKey() string {
 	// e.g. rtmp://127.0.0.1/live/test
-	// key is live/test
-	return rc.connInfo.app + "/" + rc.streamName
+	// key is live/test, or vhost/live/test of the vhost configured.
+	return StreamKey(rc.connInfo.vhost, rc.connInfo.app, rc.streamName)
+}
+
+// streamConfig the config of vhost and app of the connection.
+func (rc *RtmpConn) streamConfig() *conf.Config {
+	return rc.sources.Config().StreamConfig(rc.connInfo.vhost, rc.connInfo.app)
+}
+
+// StreamKey the source key of stream, app/stream of the default vhost, otherwise vhost/app/stream,
+// so that the vhosts can use the same app and stream.
+func StreamKey(vhost string, app string, stream string) string {
+	if 0 == len(vhost) || conf.DefaultVhost == vhost {
+		return app + "/" + stream
+	}
+
+	return vhost + "/" + app + "/" + stream
 }
 
 func (rc *RtmpConn) clean() {
 
+	// notify the client the stream is stopped, e.g. when server shutdown.
+	if 0 != atomic.LoadInt32(&rc.stopping) {
+		if err := rc.notifyStop(); err != nil {
+			rc.logger.Println("notify client stop err=", err)
+		}
+	}
+
+	// send the msgs buffered, e.g. the error status.
+	if err := rc.tcpConn.Flush(); err != nil {
+		rc.logger.Println("flush socket err=", err)
+	}
+
 	if err := rc.tcpConn.Close(); err != nil {
-		log.Println("close socket err=", err)
+		rc.logger.Println("close socket err=", err)
 	}
 
-	if pt.RtmpRoleFlashPublisher == rc.role || pt.RtmpRoleFMLEPublisher == rc.role {
+	if pt.RtmpRoleFlashPublisher == rc.role || pt.RtmpRoleFMLEPublisher == rc.role || pt.RtmpRolePuller == rc.role {
 		if nil != rc.source {
 			key := rc.getSourceKey()
 			rc.deletePublishStream(key)
-			log.Println("delete publisher stream=", key)
+			rc.logger.Println("delete publisher stream=", key)
+
+			if pt.RtmpRolePuller != rc.role {
+				rc.sources.hooks.Notify(rc.hookEvent(hooks.OnUnpublish, rc.params))
+			}
 		}
 	}
 
@@ -127,11 +290,13 @@ func (rc *RtmpConn) clean() {
 		if nil != rc.source {
 			rc.consumer.Clean()
 			rc.source.DestroyConsumer(rc.consumer)
-			log.Println("player clean over")
+			rc.logger.Println("player clean over")
+
+			rc.sources.hooks.Notify(rc.hookEvent(hooks.OnStop, rc.params))
 		}
 	}
 }
 
 func (rc *RtmpConn) deletePublishStream(key string) {
-	GlobalSources.deleteSource(key)
+	rc.sources.deleteSource(key, rc.source)
 }
@@ -0,0 +1,86 @@
+package co
+
+import (
+	"seal/auth"
+	"seal/conf"
+	"seal/rtmp/pt"
+	"testing"
+
+	"github.com/yaml"
+)
+
+func TestConnectCommandObject(t *testing.T) {
+	// the fields not used by server may be any type, e.g. sent by some encoders.
+	var pkt pt.ConnectPacket
+	pkt.CommandName = pt.RtmpAmf0CommandConnect
+	pkt.TransactionID = 1
+	pkt.CommandObject = []pt.Amf0Object{
+		*pt.NewAmf0Object("app", "live", pt.RtmpAmf0String),
+		*pt.NewAmf0Object("flashVer", 1.0, pt.RtmpAmf0Number),
+		*pt.NewAmf0Object("tcUrl", "rtmp://127.0.0.1/live", pt.RtmpAmf0String),
+		*pt.NewAmf0Object("fpad", 0.0, pt.RtmpAmf0Number),
+		*pt.NewAmf0Object("capabilities", "15", pt.RtmpAmf0String),
+		*pt.NewAmf0Object("videoFunction", nil, pt.RtmpAMF0Null),
+		*pt.NewAmf0Object("pageUrl", "http://127.0.0.1/", pt.RtmpAmf0String),
+		*pt.NewAmf0Object("objectEncoding", 3.0, pt.RtmpAmf0Number),
+	}
+
+	var p pt.ConnectPacket
+	if err := p.Decode(pkt.Encode()); err != nil {
+		t.Fatal(err)
+	}
+
+	var info connectCommandObject
+	if err := pt.UnmarshalObject(p.CommandObject, &info); err != nil {
+		t.Fatal(err)
+	}
+
+	if "live" != info.App || "rtmp://127.0.0.1/live" != info.TcURL ||
+		"http://127.0.0.1/" != info.PageURL || 3 != info.ObjectEncoding {
+		t.Fatal("connect command object mismatch, got=", info)
+	}
+}
+
+func TestVhostOfConnect(t *testing.T) {
+	c := &conf.Config{}
+	if err := yaml.Unmarshal([]byte(`
+vhosts:
+  - name: v1.example.com
+`), c); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := newTestHub(t, c)
+
+	for _, v := range []struct {
+		name  string
+		tcURL string
+		app   string
+		vhost string
+		key   string
+	}{
+		{"ip", "rtmp://127.0.0.1/live", "live", conf.DefaultVhost, "live/test"},
+		{"tcUrl host", "rtmp://v1.example.com:1935/live", "live", "v1.example.com", "v1.example.com/live/test"},
+		{"param of app", "rtmp://127.0.0.1/live", "live?vhost=v1.example.com", "v1.example.com", "v1.example.com/live/test"},
+		{"param of tcUrl", "rtmp://127.0.0.1/live?vhost=v1.example.com", "live", "v1.example.com", "v1.example.com/live/test"},
+		// the param vhost is preferred to the host of tcUrl.
+		{"param and host", "rtmp://v1.example.com/live?vhost=v2.example.com", "live", conf.DefaultVhost, "live/test"},
+		{"not configured", "rtmp://v2.example.com/live", "live", conf.DefaultVhost, "live/test"},
+	} {
+		app, params := auth.SplitQuery(v.app)
+		_, tcParams := auth.SplitQuery(v.tcURL)
+		auth.MergeParams(params, tcParams)
+
+		vhost := c.Vhost(vhostHost(v.tcURL, params))
+		key := StreamKey(vhost, app, "test")
+		if v.vhost != vhost || v.key != key {
+			t.Errorf("%s: vhost=%s, key=%s, expect %s, %s", v.name, vhost, key, v.vhost, v.key)
+			continue
+		}
+
+		// the source key is parsed back to the vhost, app and stream.
+		if pv, pa, ps := hub.parseStreamKey(key); vhost != pv || app != pa || "test" != ps {
+			t.Errorf("%s: parse key=%s to %s, %s, %s", v.name, key, pv, pa, ps)
+		}
+	}
+}
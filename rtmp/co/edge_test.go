@@ -0,0 +1,87 @@
+package co
+
+import (
+	"seal/conf"
+	"seal/rtmp/pt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWaitSequenceHeader(t *testing.T) {
+	source := newTestSource(t, &conf.Config{})
+
+	if source.waitSequenceHeader(10 * time.Millisecond) {
+		t.Fatal("no sequence header, should timeout")
+	}
+
+	// the msgs not cached as sequence header never wakeup.
+	source.copyToAllConsumers(testVideo(1, true), nil)
+	if source.waitSequenceHeader(10 * time.Millisecond) {
+		t.Fatal("no sequence header, should timeout")
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() {
+		source.copyToAllConsumers(testSequenceHeader(), &source.CacheVideoSequenceHeader)
+	})
+
+	if !source.waitSequenceHeader(5 * time.Second) {
+		t.Fatal("timeout to wait for the sequence header")
+	}
+
+	// the sequence header updated, and the waiters after it.
+	source.copyToAllConsumers(testSequenceHeader(), &source.CacheVideoSequenceHeader)
+	if !source.waitSequenceHeader(time.Millisecond) {
+		t.Fatal("the sequence header is cached, should not wait")
+	}
+}
+
+func TestEdgePlayers(t *testing.T) {
+	remote, addr := testRemote(t)
+
+	// the stream at origin, only the sequence header.
+	origin := remote.findSourceToPublish("live/edge", "test")
+	origin.copyToAllConsumers(testSequenceHeader(), &origin.CacheVideoSequenceHeader)
+
+	c := &conf.Config{}
+	c.Edge.Enable = "true"
+	c.Edge.Origins = []string{addr}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := newTestHub(t, c)
+
+	// the players at the same time share the edge source.
+	sources := make([]*SourceStream, 8)
+
+	var wg sync.WaitGroup
+	for i := range sources {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sources[i] = hub.FindSourceToPlay("live/edge")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, v := range sources {
+		if nil == v || sources[0] != v {
+			t.Fatalf("player %d got source %p, expect %p", i, v, sources[0])
+		}
+	}
+
+	if nil == sources[0].edge || nil == sources[0].CacheVideoSequenceHeader {
+		t.Fatal("the sequence header is not pulled from origin")
+	}
+
+	hub.deleteSource("live/edge", sources[0])
+}
+
+// testSequenceHeader a h264 sequence header msg.
+func testSequenceHeader() *pt.Message {
+	msg := testVideo(0, true)
+	msg.Payload.Payload[1] = 0
+
+	return msg
+}
@@ -0,0 +1,291 @@
+package co
+
+import (
+	"fmt"
+	"net/url"
+	"seal/conf"
+	"seal/rtmp/pt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/calabashdad/utiltools"
+)
+
+// forwarder forward the stream published to local to remote rtmp server,
+// it's an internal consumer of the source.
+type forwarder struct {
+	// url the remote stream url, e.g. rtmp://127.0.0.1:19350/live/test
+	url    string
+	source *SourceStream
+
+	mu      sync.Mutex
+	rc      *RtmpConn
+	stopped bool
+}
+
+func newForwarder(url string, source *SourceStream) *forwarder {
+	return &forwarder{
+		url:    url,
+		source: source,
+	}
+}
+
+// forwardURLs get the remote urls to forward the stream app/stream to, by the config c.
+func (s *SourceHub) forwardURLs(c *conf.Config, app string, stream string) (urls []string) {
+	for _, v := range c.Forward {
+		if app != v.App {
+			continue
+		}
+
+		for _, dest := range v.Destinations {
+			u, err := url.Parse(dest)
+			if err != nil {
+				s.logger.Println("forward destination is invalid, dest=", dest, ", err=", err)
+				continue
+			}
+
+			s := strings.TrimRight(dest, "/")
+			if 0 == len(strings.Trim(u.Path, "/")) {
+				s += "/" + app
+			}
+
+			urls = append(urls, s+"/"+stream)
+		}
+	}
+
+	return
+}
+
+// start forward in background, reconnect with backoff when failed until stopped.
+func (f *forwarder) start() {
+	go f.cycle()
+}
+
+func (f *forwarder) cycle() {
+	defer func() {
+		if err := recover(); err != nil {
+			f.source.hub.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	interval := forwardRetryMinSeconds
+
+	for {
+		begin := time.Now()
+
+		if err := f.forward(); err != nil {
+			f.source.hub.logger.Println("forward stream failed, url=", f.url, ", err=", err)
+		}
+
+		if f.isStopped() {
+			break
+		}
+
+		// the remote worked for a while, retry quickly.
+		if time.Since(begin) > time.Duration(forwardRetryMaxSeconds)*time.Second {
+			interval = forwardRetryMinSeconds
+		}
+
+		time.Sleep(time.Duration(interval) * time.Second)
+
+		if interval *= 2; interval > forwardRetryMaxSeconds {
+			interval = forwardRetryMaxSeconds
+		}
+
+		if f.isStopped() {
+			break
+		}
+	}
+
+	f.source.hub.logger.Println("forwarder stopped, url=", f.url)
+}
+
+// stop stop forwarding, close the connection to remote.
+func (f *forwarder) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stopped = true
+
+	if nil != f.rc {
+		f.rc.tcpConn.Close()
+	}
+}
+
+func (f *forwarder) isStopped() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.stopped
+}
+
+func (f *forwarder) setConn(rc *RtmpConn) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.stopped {
+		return false
+	}
+
+	f.rc = rc
+
+	return true
+}
+
+func (f *forwarder) forward() (err error) {
+	defer func() {
+		if err := recover(); err != nil {
+			f.source.hub.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	var u *rtmpURL
+	if u, err = parseRtmpURL(f.url); err != nil {
+		return
+	}
+
+	hub := f.source.hub
+
+	var rc *RtmpConn
+	if rc, err = dialRtmp(hub, u.host); err != nil {
+		return
+	}
+	f.source.hub.logger.Println("forwarder handshake success, remote=", u.host)
+
+	if !f.setConn(rc) {
+		rc.tcpConn.Close()
+		return
+	}
+
+	rc.role = pt.RtmpRoleForwarder
+	rc.streamName = u.stream
+	rc.storeState()
+	hub.conns.add(rc)
+
+	defer func() {
+		rc.tcpConn.Close()
+		f.setConn(nil)
+		hub.conns.remove(rc)
+	}()
+
+	if err = rc.connectApp(u); err != nil {
+		return
+	}
+
+	// set chunk size to remote
+	if true {
+		var pkt pt.SetChunkSizePacket
+		pkt.ChunkSize = hub.Config().Rtmp.ChunkSize
+
+		if err = rc.sendPacket(&pkt, 0); err != nil {
+			return
+		}
+
+		rc.outChunkSize = pkt.ChunkSize
+	}
+
+	if err = rc.publish(u.streamWithParam()); err != nil {
+		return
+	}
+
+	consumer := NewConsumer("forward/" + u.stream)
+	consumer.internal = true
+	f.source.CreateConsumer(consumer)
+	defer f.source.DestroyConsumer(consumer)
+	defer consumer.Clean()
+
+	f.source.DumpCache(consumer)
+
+	f.source.hub.logger.Println("now forwarding, url=", f.url)
+
+	// recv the msgs from remote in another goroutine, e.g. ack, ping and set chunk size,
+	// they are handled in this goroutine, so only this goroutine sends to remote.
+	ctrl := make(chan *pt.Message)
+	done := make(chan struct{})
+	defer close(done)
+
+	if err = rc.tcpConn.Flush(); err != nil {
+		return
+	}
+
+	go rc.recvControl(ctrl, done)
+
+	streamID := uint32(rc.defaultStreamID)
+
+	for {
+		if f.isStopped() {
+			break
+		}
+
+		// handle the msgs from remote first.
+		select {
+		case m, ok := <-ctrl:
+			if err = f.onRemoteMsg(rc, m, ok); err != nil {
+				return
+			}
+
+			continue
+		default:
+		}
+
+		// get the notify before dump, in case the msg published after dump.
+		notify := f.source.ring.wait()
+
+		msg := consumer.Dump()
+		if nil == msg {
+			select {
+			case <-notify:
+			case m, ok := <-ctrl:
+				if err = f.onRemoteMsg(rc, m, ok); err != nil {
+					return
+				}
+			case <-time.After(time.Duration(1) * time.Second):
+				// wakeup to check whether stopped.
+			}
+
+			continue
+		}
+
+		// the msg dumped is owned by consumer, use the stream id of remote.
+		msg.Header.StreamID = streamID
+
+		err = rc.sendMsg(msg)
+		consumer.Release()
+
+		if err != nil {
+			break
+		}
+
+		consumer.AddSendBytes(msg.Header.PayloadLength)
+	}
+
+	return
+}
+
+// onRemoteMsg handle the msg recved from remote, e.g. ack, ping, set chunk size and the error status,
+// the media msgs and the responses are ignored. return error when recv failed, to reconnect.
+func (f *forwarder) onRemoteMsg(rc *RtmpConn, msg *pt.Message, ok bool) (err error) {
+	if !ok {
+		return fmt.Errorf("forward recv from remote failed, url=%s", f.url)
+	}
+
+	if msg.Header.IsAudio() || msg.Header.IsVideo() || pt.RtmpMsgAggregateMessage == msg.Header.MessageType {
+		return
+	}
+
+	// the late responses of the requests are ignored, e.g. releaseStream, FCPublish.
+	if msg.Header.IsAmf0Command() {
+		var offset uint32
+		if command, _ := pt.Amf0ReadString(msg.Payload.Payload, &offset); pt.RtmpAmf0CommandResult == command || pt.RtmpAmf0CommandError == command {
+			return
+		}
+	}
+
+	if err = rc.onRecvMsg(msg); err != nil {
+		return
+	}
+
+	// the responses are buffered, e.g. the ping response.
+	return rc.tcpConn.Flush()
+}
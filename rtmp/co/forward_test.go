@@ -0,0 +1,154 @@
+package co
+
+import (
+	"net"
+	"seal/conf"
+	"seal/rtmp/pt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yaml"
+)
+
+// testRemote start a rtmp server of a new hub as the remote, return the hub and the address.
+func testRemote(t *testing.T) (*SourceHub, string) {
+	c := &conf.Config{}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := newTestHub(t, c)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go hub.NewRtmpConnection(c).Cycle()
+		}
+	}()
+
+	return hub, ln.Addr().String()
+}
+
+// waitFor wait until cond is true, fail when timeout.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout to wait for", what)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForwardRecvFromRemote(t *testing.T) {
+	remote, addr := testRemote(t)
+
+	c := &conf.Config{}
+	if err := yaml.Unmarshal([]byte(`
+forward:
+  - app: live
+    destinations: [rtmp://`+addr+`/backup]
+`), c); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	source := newTestHub(t, c).findSourceToPublish("live/fw", "test")
+	if nil == source || 1 != len(source.forwarders) {
+		t.Fatal("no forwarder of source")
+	}
+
+	f := source.forwarders[0]
+	defer f.stop()
+
+	conn := func() *RtmpConn {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		return f.rc
+	}
+
+	// the connection of forwarder at remote, which is publishing.
+	var peer *RtmpConn
+	waitFor(t, "forwarder publishing", func() bool {
+		remote.conns.lock.RLock()
+		defer remote.conns.lock.RUnlock()
+
+		for _, rc := range remote.conns.hub {
+			if st := rc.loadState(); nil != st.source && pt.RtmpRoleFMLEPublisher == st.role {
+				peer = rc
+			}
+		}
+
+		return nil != peer
+	})
+
+	rc := conn()
+	if nil == rc {
+		t.Fatal("forwarder is not connected")
+	}
+
+	// the status larger than the chunk size can only be recved when the set chunk size is handled.
+	var chunk pt.SetChunkSizePacket
+	chunk.ChunkSize = 8192
+
+	var status pt.OnStatusCallPacket
+	status.CommandName = pt.RtmpAmf0CommandOnStatus
+	if err := status.Marshal(&pt.StatusInfo{
+		Level:       pt.StatusLevelStatus,
+		Code:        pt.StatusCodePublishStart,
+		Description: strings.Repeat("x", 6000),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := peer.sendPacket(&chunk, 0); err != nil {
+		t.Fatal(err)
+	}
+	peer.outChunkSize = chunk.ChunkSize
+
+	if err := peer.sendPacket(&status, uint32(peer.defaultStreamID)); err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.tcpConn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	if rc != conn() {
+		t.Fatal("forwarder reconnected after the set chunk size and status")
+	}
+
+	// the error status makes the forwarder reconnect.
+	if err := status.Marshal(&pt.StatusInfo{
+		Level: pt.StatusLevelError,
+		Code:  pt.StatusCodePublishBadName,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := peer.sendPacket(&status, uint32(peer.defaultStreamID)); err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.tcpConn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "forwarder reconnect", func() bool {
+		return rc != conn()
+	})
+}
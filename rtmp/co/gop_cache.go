@@ -2,6 +2,8 @@ package co
 
 import (
 	"log"
+	"seal/conf"
+	"seal/metrics"
 	"seal/rtmp/flv"
 	"seal/rtmp/pt"
 	"sync"
@@ -11,6 +13,16 @@ import (
 type GopCache struct {
 	mu sync.RWMutex
 
+	// enable whether cache the gop, the player starts from the msgs after it comes in if disabled.
+	enable bool
+	// maxGops the count of gops to keep.
+	maxGops int
+	// the limits of cache, 0 to disable. when exceed, drop the oldest gops,
+	// or clear the cache and skip the msgs until the next key frame if the last gop exceed.
+	maxDuration uint64 // in ms.
+	maxBytes    uint64
+	maxFrames   int
+
 	// cachedVideoCount the video frame count, avoid cache for pure audio stream.
 	cachedVideoCount uint32
 	// when user disabled video when publishing, and gop cache enalbed,
@@ -25,11 +37,64 @@ type GopCache struct {
 	audioAfterLastVideoCount uint32
 
 	msgs []*pt.Message
+	// gops the index in msgs of the key frame which each gop starts from,
+	// the msgs before the first gop are cached before got the first key frame.
+	gops []int
+	// bytes the bytes of payloads cached.
+	bytes uint64
+	// skipping skip the msgs until the next key frame, for the last gop exceed the limits.
+	skipping bool
+
+	metrics *metrics.Server
+	logger  *log.Logger
+}
+
+// newGopCache create the gop cache of app, use the config of app or the default in c.
+func newGopCache(c *conf.Config, app string, m *metrics.Server, logger *log.Logger) *GopCache {
+	g := &GopCache{
+		enable:  true,
+		maxGops: 1,
+		metrics: m,
+		logger:  logger,
+	}
+
+	i := gopCacheConfIndex(c, app)
+	if i < 0 {
+		return g
+	}
+
+	v := c.GopCache[i]
+	g.enable = "true" == v.Enable
+	if v.Gops > 1 {
+		g.maxGops = int(v.Gops)
+	}
+	g.maxDuration = uint64(v.MaxDuration) * 1000
+	g.maxBytes = v.MaxBytes
+	g.maxFrames = int(v.MaxFrames)
+
+	return g
+}
+
+// gopCacheConfIndex the index of gop cache config of app, or the default whose app is empty, -1 if none.
+func gopCacheConfIndex(c *conf.Config, app string) (index int) {
+	index = -1
+
+	for i, v := range c.GopCache {
+		if app == v.App {
+			return i
+		}
+
+		if 0 == len(v.App) && index < 0 {
+			index = i
+		}
+	}
+
+	return
 }
 
 func (g *GopCache) cache(msg *pt.Message) {
 
-	if nil == msg {
+	if nil == msg || !g.enable {
 		return
 	}
 
@@ -51,44 +116,142 @@ func (g *GopCache) cache(msg *pt.Message) {
 
 	if g.audioAfterLastVideoCount > pt.PureAudioGuessCount {
 		g.clear()
-		log.Printf("gop cahce pure audio more than %d seconds, clear old caches.\n", pt.PureAudioGuessCount)
+		g.logger.Printf("gop cahce pure audio more than %d seconds, clear old caches.\n", pt.PureAudioGuessCount)
 		return
 	}
 
-	// clear gop cache when got key frame
+	// start a new gop when got key frame, drop the oldest to keep the gops.
 	if msg.Header.IsVideo() && flv.VideoIsH264(msg.Payload.Payload) && flv.VideoH264IsKeyframe(msg.Payload.Payload) {
-		g.clear()
+		cut := len(g.msgs)
+		if keep := g.maxGops - 1; keep > 0 && len(g.gops) > 0 {
+			i := len(g.gops) - keep
+			if i < 0 {
+				i = 0
+			}
+			cut = g.gops[i]
+		}
+		g.trim(cut)
 
-		// curent msg is video frame, so we set to 1.
-		g.cachedVideoCount = 1
+		g.gops = append(g.gops, len(g.msgs))
+		g.skipping = false
+	} else if g.skipping {
+		return
 	}
 
+	msg.Retain()
 	g.msgs = append(g.msgs, msg)
+	g.bytes += uint64(msg.Header.PayloadLength)
+
+	// drop the oldest gops when exceed the limits, clear if the last gop still exceed.
+	for g.exceed() {
+		if len(g.gops) > 1 {
+			g.trim(g.gops[1])
+			continue
+		}
+
+		g.logger.Printf("gop cache exceed the limits, msgs=%d, bytes=%d, clear and skip until next key frame.\n",
+			len(g.msgs), g.bytes)
+		g.clear()
+		g.cachedVideoCount = 1
+		g.skipping = true
+		break
+	}
 }
 
 func (g *GopCache) pureAudio() bool {
 	return 0 == g.cachedVideoCount
 }
 
+// exceed whether the msgs cached exceed the limits.
+func (g *GopCache) exceed() bool {
+	if g.maxFrames > 0 && len(g.msgs) > g.maxFrames {
+		return true
+	}
+
+	if g.maxBytes > 0 && g.bytes > g.maxBytes {
+		return true
+	}
+
+	if g.maxDuration > 0 && g.duration() > g.maxDuration {
+		return true
+	}
+
+	return false
+}
+
+// duration the timestamp delta in ms from the first msg cached to the last.
+func (g *GopCache) duration() uint64 {
+	if len(g.msgs) < 2 {
+		return 0
+	}
+
+	first, last := g.msgs[0].Header.Timestamp, g.msgs[len(g.msgs)-1].Header.Timestamp
+	if last <= first {
+		return 0
+	}
+
+	return last - first
+}
+
+// trim drop the msgs before index cut.
+func (g *GopCache) trim(cut int) {
+	if cut <= 0 {
+		return
+	}
+
+	g.metrics.GopCacheTrims.Inc()
+
+	for _, v := range g.msgs[:cut] {
+		g.bytes -= uint64(v.Header.PayloadLength)
+		v.Release()
+	}
+
+	n := copy(g.msgs, g.msgs[cut:])
+	for i := n; i < len(g.msgs); i++ {
+		g.msgs[i] = nil
+	}
+	g.msgs = g.msgs[:n]
+
+	gops := g.gops[:0]
+	for _, v := range g.gops {
+		if v >= cut {
+			gops = append(gops, v-cut)
+		}
+	}
+	g.gops = gops
+}
+
 func (g *GopCache) clear() {
+	if len(g.msgs) > 0 {
+		g.metrics.GopCacheClears.Inc()
+	}
+
+	for _, v := range g.msgs {
+		if nil != v {
+			v.Release()
+		}
+	}
+
 	g.msgs = nil
+	g.gops = nil
+	g.bytes = 0
 	g.cachedVideoCount = 0
 	g.audioAfterLastVideoCount = 0
 }
 
 func (g *GopCache) Empty() bool {
-	return nil == g.msgs
+	return 0 == len(g.msgs)
 }
 
 func (g *GopCache) StartTime() uint64 {
-	if nil != g.msgs && nil != g.msgs[0] {
+	if len(g.msgs) > 0 && nil != g.msgs[0] {
 		return g.msgs[0].Header.Timestamp
 	}
 
 	return 0
 }
 
-func (g *GopCache) Dump(c *Consumer, atc bool, tba float64, tbv float64, timeJitter uint32) {
+func (g *GopCache) Dump(c *Consumer) {
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -99,6 +262,14 @@ func (g *GopCache) Dump(c *Consumer, atc bool, tba float64, tbv float64, timeJit
 			continue
 		}
 
-		c.Enquene(v, atc, tba, tbv, timeJitter)
+		c.Enquene(v)
 	}
 }
+
+// size the count and bytes of msgs, the count of gops and the duration in ms of cache, for stats.
+func (g *GopCache) size() (count int, bytes uint64, gops int, duration uint64) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return len(g.msgs), g.bytes, len(g.gops), g.duration()
+}
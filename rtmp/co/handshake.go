@@ -1,10 +1,11 @@
 package co
 
 import (
+	"crypto/rand"
 	"encoding/binary"
 	"fmt"
-	"log"
 	"seal/rtmp/pt"
+	"time"
 
 	"github.com/calabashdad/utiltools"
 )
@@ -12,7 +13,15 @@ import (
 func (rc *RtmpConn) handShake() (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	// the handshake type for metrics, unknown before c1 parsed.
+	handshakeType := "unknown"
+	defer func() {
+		if err != nil {
+			rc.sources.metrics.HandshakeFailures.Inc(handshakeType)
 		}
 	}()
 
@@ -44,14 +53,16 @@ func (rc *RtmpConn) handShake() (err error) {
 	//parse c1
 	clientVer := binary.BigEndian.Uint32(c1[4:8])
 	if 0 != clientVer {
+		handshakeType = "complex"
 		if err = pt.ComplexHandShake(c1, s0, s1, s2); err != nil {
 			return
 		}
-		log.Println("complex handshake success.")
+		rc.logger.Println("complex handshake success.")
 
 	} else {
 		//use simple handshake
-		log.Println("0 == clientVer, client use simple handshake.")
+		handshakeType = "simple"
+		rc.logger.Println("0 == clientVer, client use simple handshake.")
 		s0[0] = 3
 		copy(s1, c2)
 		copy(s2, c1)
@@ -71,3 +82,55 @@ func (rc *RtmpConn) handShake() (err error) {
 
 	return
 }
+
+// clientHandShake the handshake when we act as a rtmp client, use simple handshake.
+func (rc *RtmpConn) clientHandShake() (err error) {
+	defer func() {
+		if err := recover(); err != nil {
+			rc.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	var handshakeData [6146]uint8 // c0(1) + c1(1536) + c2(1536) + s0(1) + s1(1536) + s2(1536)
+
+	c0 := handshakeData[:1]
+	c1 := handshakeData[1:1537]
+	c2 := handshakeData[1537:3073]
+
+	s0 := handshakeData[3073:3074]
+	s1 := handshakeData[3074:4610]
+
+	c0c1 := handshakeData[0:1537]
+	s0s1s2 := handshakeData[3073:6146]
+
+	// c0 version is 3, c1 is time(4B) zero(4B) random(1528B).
+	// the zero version tell the server we use simple handshake.
+	c0[0] = 3
+	binary.BigEndian.PutUint32(c1[0:4], uint32(time.Now().Unix()))
+	rand.Read(c1[8:])
+
+	//send c0c1
+	if err = rc.tcpConn.SendBytes(c0c1); err != nil {
+		return
+	}
+
+	//recv s0s1s2
+	if err = rc.tcpConn.ExpectBytesFull(s0s1s2, uint32(len(s0s1s2))); err != nil {
+		return
+	}
+
+	if s0[0] != 3 {
+		err = fmt.Errorf("server s0 is not 3")
+		return
+	}
+
+	//c2 is the copy of s1
+	copy(c2, s1)
+
+	//send c2
+	if err = rc.tcpConn.SendBytes(c2); err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,20 @@
+package co
+
+import (
+	"net/url"
+	"seal/hooks"
+)
+
+// hookEvent the event of the connection for the http callbacks.
+func (rc *RtmpConn) hookEvent(action string, params url.Values) *hooks.Event {
+	return &hooks.Event{
+		Action:     action,
+		Vhost:      rc.connInfo.vhost,
+		App:        rc.connInfo.app,
+		Stream:     rc.streamName,
+		Param:      params.Encode(),
+		TcURL:      rc.connInfo.tcURL,
+		PageURL:    rc.connInfo.pageURL,
+		RemoteAddr: rc.tcpConn.Conn.RemoteAddr().String(),
+	}
+}
@@ -0,0 +1,75 @@
+package co
+
+import (
+	"seal/metrics"
+	"seal/rtmp/pt"
+)
+
+// registerMetrics register the active publishers and players of each vhost and app to the metrics of hub,
+// collected when exposed.
+func (s *SourceHub) registerMetrics() {
+	s.metrics.NewGaugeVec("seal_publishers", "The active publishers, include the ingest pullers.", appLabels,
+		s.conns.publishersOfApps)
+
+	s.metrics.NewGaugeVec("seal_players", "The active players, include the http-flv players.", appLabels,
+		s.playersOfApps)
+}
+
+// appLabels the labels of the metrics of each app, the app is without vhost.
+var appLabels = []string{"vhost", "app"}
+
+// appKey the vhost and app of stream.
+type appKey struct {
+	vhost string
+	app   string
+}
+
+// appValues the gauge values of the apps.
+func appValues(apps map[appKey]float64) (values []metrics.GaugeValue) {
+	for k, v := range apps {
+		values = append(values, metrics.GaugeValue{
+			Labels: []string{k.vhost, k.app},
+			Value:  v,
+		})
+	}
+
+	return
+}
+
+// publishersOfApps the count of publishers of each vhost and app.
+func (h *ConnHub) publishersOfApps() []metrics.GaugeValue {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	apps := make(map[appKey]float64)
+	for _, rc := range h.hub {
+		st := rc.loadState()
+		switch st.role {
+		case pt.RtmpRoleFMLEPublisher, pt.RtmpRoleFlashPublisher, pt.RtmpRolePuller:
+			apps[appKey{st.vhost, st.app}]++
+		}
+	}
+
+	return appValues(apps)
+}
+
+// playersOfApps the count of players of each vhost and app, the internal consumers are ignored.
+func (s *SourceHub) playersOfApps() []metrics.GaugeValue {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	apps := make(map[appKey]float64)
+	for key, source := range s.hub {
+		vhost, app, _ := s.parseStreamKey(key)
+
+		source.consumerLock.RLock()
+		for c := range source.consumers {
+			if !c.internal {
+				apps[appKey{vhost, app}]++
+			}
+		}
+		source.consumerLock.RUnlock()
+	}
+
+	return appValues(apps)
+}
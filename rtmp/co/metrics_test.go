@@ -0,0 +1,73 @@
+package co
+
+import (
+	"bytes"
+	"net"
+	"seal/conf"
+	"seal/rtmp/pt"
+	"strings"
+	"testing"
+
+	"github.com/yaml"
+)
+
+func TestMetricsOfApps(t *testing.T) {
+	c := &conf.Config{}
+	if err := yaml.Unmarshal([]byte(`
+vhosts:
+  - name: v1
+`), c); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := newTestHub(t, c)
+
+	// the publishers and players of the same app of default vhost and v1.
+	for _, v := range []struct {
+		vhost string
+		key   string
+	}{
+		{conf.DefaultVhost, "live/a"},
+		{"v1", "v1/live/b"},
+		{"v1", "v1/live/c"},
+	} {
+		source := hub.findSourceToPublish(v.key, "test")
+
+		client, server := net.Pipe()
+		t.Cleanup(func() {
+			client.Close()
+			server.Close()
+		})
+
+		rc := hub.NewRtmpConnection(server)
+		rc.role = pt.RtmpRoleFMLEPublisher
+		rc.connInfo.vhost = v.vhost
+		rc.connInfo.app = "live"
+		rc.source = source
+		rc.storeState()
+		hub.conns.add(rc)
+
+		source.CreateConsumer(NewConsumer(v.key))
+
+		// the internal consumers are not players.
+		forward := NewConsumer(v.key)
+		forward.internal = true
+		source.CreateConsumer(forward)
+	}
+
+	var b bytes.Buffer
+	if err := hub.metrics.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{
+		`seal_publishers{vhost="__defaultVhost__",app="live"} 1`,
+		`seal_publishers{vhost="v1",app="live"} 2`,
+		`seal_players{vhost="__defaultVhost__",app="live"} 1`,
+		`seal_players{vhost="v1",app="live"} 2`,
+	} {
+		if !strings.Contains(b.String(), v+"\n") {
+			t.Errorf("no %s in metrics:\n%s", v, b.String())
+		}
+	}
+}
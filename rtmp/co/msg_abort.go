@@ -1,7 +1,6 @@
 package co
 
 import (
-	"log"
 	"seal/rtmp/pt"
 	"github.com/calabashdad/utiltools"
 )
@@ -9,11 +8,11 @@ import (
 func (rc *RtmpConn) msgAbort(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("MsgAbort")
+	rc.logger.Println("MsgAbort")
 
 	if nil == msg {
 		return
@@ -3,7 +3,6 @@ package co
 import (
 	"encoding/binary"
 	"fmt"
-	"log"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
@@ -12,11 +11,11 @@ import (
 func (rc *RtmpConn) msgAggregate(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("aggregate")
+	rc.logger.Println("aggregate")
 	if nil == msg {
 		return
 	}
@@ -98,12 +97,12 @@ func (rc *RtmpConn) msgAggregate(msg *pt.Message) (err error) {
 
 		// process has parsed message
 		if o.Header.IsAudio() {
-			log.Println("aggregate audio msg")
+			rc.logger.Println("aggregate audio msg")
 			if err = rc.msgAudio(&o); err != nil {
 				break
 			}
 		} else if o.Header.IsVideo() {
-			log.Println("aggregate video msg")
+			rc.logger.Println("aggregate video msg")
 			if err = rc.msgVideo(&o); err != nil {
 				break
 			}
@@ -2,8 +2,9 @@ package co
 
 import (
 	"fmt"
-	"log"
-	"seal/conf"
+	"net/url"
+	"seal/auth"
+	"seal/hooks"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
@@ -12,7 +13,7 @@ import (
 func (rc *RtmpConn) msgAmf(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -20,12 +21,10 @@ func (rc *RtmpConn) msgAmf(msg *pt.Message) (err error) {
 		return
 	}
 
-	var offset uint32
+	// decode the amf3 command/data as amf0.
+	msg.ConvertAmf3ToAmf0()
 
-	// skip 1bytes to decode the amf3 command.
-	if pt.RtmpMsgAmf3CommandMessage == msg.Header.MessageType && msg.Header.PayloadLength >= 1 {
-		offset++
-	}
+	var offset uint32
 
 	//read the command name.
 	var command string
@@ -38,7 +37,7 @@ func (rc *RtmpConn) msgAmf(msg *pt.Message) (err error) {
 		return
 	}
 
-	log.Println("amf0/3 command or amf0/3 data, msg typeid=", msg.Header.MessageType, ",command =", command)
+	rc.logger.Println("amf0/3 command or amf0/3 data, msg typeid=", msg.Header.MessageType, ",command =", command)
 
 	switch command {
 	case pt.RtmpAmf0CommandResult, pt.RtmpAmf0CommandError:
@@ -77,7 +76,7 @@ func (rc *RtmpConn) msgAmf(msg *pt.Message) (err error) {
 	case pt.RtmpAmf0DataSampleAccess:
 		err = rc.amf0SampleAccess(msg)
 	default:
-		log.Println("msg amf unknown command name=", command)
+		rc.logger.Println("msg amf unknown command name=", command)
 	}
 
 	if err != nil {
@@ -89,11 +88,11 @@ func (rc *RtmpConn) msgAmf(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0ResultError(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0ResultError")
+	rc.logger.Println("Amf0ResultError")
 
 	if nil == msg {
 		return
@@ -103,7 +102,7 @@ func (rc *RtmpConn) amf0ResultError(msg *pt.Message) (err error) {
 
 	var transactionID float64
 	if transactionID, err = pt.Amf0ReadNumber(msg.Payload.Payload, &offset); err != nil {
-		log.Println("read transaction id failed when decode msg.", transactionID)
+		rc.logger.Println("read transaction id failed when decode msg.", transactionID)
 		return
 	}
 
@@ -125,11 +124,11 @@ func (rc *RtmpConn) amf0ResultError(msg *pt.Message) (err error) {
 		p := pt.FmleStartResPacket{}
 		err = p.Decode(msg.Payload.Payload)
 	default:
-		log.Println("result/error: unknown request command name=", reqCommandName)
+		rc.logger.Println("result/error: unknown request command name=", reqCommandName)
 	}
 
 	if err != nil {
-		log.Println("decode result or error response msg failed.")
+		rc.logger.Println("decode result or error response msg failed.")
 		return
 	}
 
@@ -139,11 +138,11 @@ func (rc *RtmpConn) amf0ResultError(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0Connect(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0Connect")
+	rc.logger.Println("Amf0Connect")
 
 	if nil == msg {
 		return
@@ -151,68 +150,103 @@ func (rc *RtmpConn) amf0Connect(msg *pt.Message) (err error) {
 
 	p := pt.ConnectPacket{}
 	if err = p.Decode(msg.Payload.Payload); err != nil {
-		log.Println("decode conncet pkt faile.err=", err)
+		rc.logger.Println("decode conncet pkt faile.err=", err)
 		return
 	}
 
-	if nil == p.GetObjectProperty("tcUrl") {
-		err = fmt.Errorf("no tcUrl info in connect")
+	info := connectCommandObject{
+		ObjectEncoding: rc.connInfo.objectEncoding,
+	}
+	if err = pt.UnmarshalObject(p.CommandObject, &info); err != nil {
+		rc.logger.Println("decode connect command object failed, err=", err)
 		return
 	}
 
-	rc.connInfo.tcURL = p.GetObjectProperty("tcUrl").(string)
-	if o := p.GetObjectProperty("pageUrl"); o != nil {
-		rc.connInfo.pageURL = o.(string)
-	}
-	if o := p.GetObjectProperty("swfUrl"); o != nil {
-		rc.connInfo.swfURL = o.(string)
-	}
-	if o := p.GetObjectProperty("app"); o != nil {
-		rc.connInfo.app = o.(string)
+	if 0 == len(info.TcURL) {
+		err = fmt.Errorf("no tcUrl info in connect")
+		return
 	}
-	if o := p.GetObjectProperty("objectEncoding"); o != nil {
-		rc.connInfo.objectEncoding = o.(float64)
+
+	// the params may be in app or tcUrl, e.g. rtmp://127.0.0.1/live?token=xxx
+	app, params := auth.SplitQuery(info.App)
+	_, tcParams := auth.SplitQuery(info.TcURL)
+	auth.MergeParams(params, tcParams)
+
+	rc.connInfo.vhost = rc.sources.Config().Vhost(vhostHost(info.TcURL, params))
+	rc.connInfo.tcURL = info.TcURL
+	rc.connInfo.pageURL = info.PageURL
+	rc.connInfo.swfURL = info.SwfURL
+	rc.connInfo.app = app
+	rc.connInfo.objectEncoding = info.ObjectEncoding
+	rc.connInfo.params = params
+	rc.storeState()
+
+	rc.logger.Println("decode connect pkt success.", p, ", info=", rc.connInfo)
+
+	if err = rc.authenticate(auth.ActionConnect, params); err != nil {
+		err = rc.rejectConnect(err)
+		return
 	}
 
-	log.Println("decode connect pkt success.", p, ", info=", rc.connInfo)
+	rc.sources.hooks.Notify(rc.hookEvent(hooks.OnConnect, params))
 
 	var pkt pt.ConnectResPacket
 
 	pkt.CommandName = pt.RtmpAmf0CommandResult
 	pkt.TransactionID = 1
 
-	pkt.AddProsObj(pt.NewAmf0Object("fmsVer", "FMS/"+pt.RtmpSigFmsVersion, pt.RtmpAmf0String))
-	pkt.AddProsObj(pt.NewAmf0Object("capabilities", 127.0, pt.RtmpAmf0Number))
-	pkt.AddProsObj(pt.NewAmf0Object("mode", 1.0, pt.RtmpAmf0Number))
-	pkt.AddProsObj(pt.NewAmf0Object(pt.StatusLevel, pt.StatusLevelStatus, pt.RtmpAmf0String))
-	pkt.AddProsObj(pt.NewAmf0Object(pt.StatusCode, pt.StatusCodeConnectSuccess, pt.RtmpAmf0String))
-	pkt.AddProsObj(pt.NewAmf0Object(pt.StatusDescription, "Connection succeeded", pt.RtmpAmf0String))
-	pkt.AddProsObj(pt.NewAmf0Object("objectEncoding", rc.connInfo.objectEncoding, pt.RtmpAmf0Number))
-	pkt.AddProsObj(pt.NewAmf0Object("version", pt.RtmpSigFmsVersion, pt.RtmpAmf0String))
-	pkt.AddProsObj(pt.NewAmf0Object("seal_license", "The MIT License (MIT)", pt.RtmpAmf0String))
-	pkt.AddProsObj(pt.NewAmf0Object("seal_authors", "YangKai", pt.RtmpAmf0String))
-	pkt.AddProsObj(pt.NewAmf0Object("seal_email", "beyondyangkai@gmail.com", pt.RtmpAmf0String))
-	pkt.AddProsObj(pt.NewAmf0Object("seal_copyright", "Copyright (c) 2018 YangKai", pt.RtmpAmf0String))
-	pkt.AddProsObj(pt.NewAmf0Object("seal_sig", "seal", pt.RtmpAmf0String))
+	if err = pkt.MarshalProps(&connectResponseProps{
+		FmsVer:       "FMS/" + pt.RtmpSigFmsVersion,
+		Capabilities: 127,
+		Mode:         1,
+		StatusInfo: pt.StatusInfo{
+			Level:       pt.StatusLevelStatus,
+			Code:        pt.StatusCodeConnectSuccess,
+			Description: "Connection succeeded",
+		},
+		ObjectEncoding: rc.connInfo.objectEncoding,
+		Version:        pt.RtmpSigFmsVersion,
+		License:        "The MIT License (MIT)",
+		Authors:        "YangKai",
+		Email:          "beyondyangkai@gmail.com",
+		Copyright:      "Copyright (c) 2018 YangKai",
+		Sig:            "seal",
+	}); err != nil {
+		return
+	}
 
 	if err = rc.sendPacket(&pkt, 0); err != nil {
-		log.Println("response connect error.", err)
+		rc.logger.Println("response connect error.", err)
 		return
 	}
 
-	log.Println("send connect response success.")
+	rc.logger.Println("send connect response success.")
 
 	return
 }
 
+// vhostHost the host of vhost of the client, the param vhost, or the host of tcUrl,
+// e.g. rtmp://127.0.0.1/live?vhost=xxx
+func vhostHost(tcURL string, params url.Values) string {
+	if host := params.Get("vhost"); 0 != len(host) {
+		return host
+	}
+
+	if u, err := url.Parse(tcURL); nil == err {
+		return u.Hostname()
+	}
+
+	return ""
+}
+
 func (rc *RtmpConn) amf0CreateStream(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0CreateStream")
+	rc.logger.Println("Amf0CreateStream")
 
 	if nil == msg {
 		return
@@ -220,7 +254,7 @@ func (rc *RtmpConn) amf0CreateStream(msg *pt.Message) (err error) {
 
 	p := pt.CreateStreamPacket{}
 	if err = p.Decode(msg.Payload.Payload); nil != err {
-		log.Println("decode create stream failed.")
+		rc.logger.Println("decode create stream failed.")
 		return
 	}
 
@@ -231,10 +265,10 @@ func (rc *RtmpConn) amf0CreateStream(msg *pt.Message) (err error) {
 	pkt.StreamID = rc.defaultStreamID
 
 	if err = rc.sendPacket(&pkt, 0); err != nil {
-		log.Println("send createStream response failed. err=", err)
+		rc.logger.Println("send createStream response failed. err=", err)
 		return
 	}
-	log.Println("send createStream response success.")
+	rc.logger.Println("send createStream response success.")
 
 	return
 }
@@ -242,11 +276,11 @@ func (rc *RtmpConn) amf0CreateStream(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0Play(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0Play")
+	rc.logger.Println("Amf0Play")
 
 	if nil == msg {
 		return
@@ -257,31 +291,48 @@ func (rc *RtmpConn) amf0Play(msg *pt.Message) (err error) {
 		return
 	}
 
-	log.Println("a new player come in, play info=", p)
+	rc.logger.Println("a new player come in, play info=", p)
+
+	// the packet split the token from stream name, join it back to parse all the params.
+	name, params := auth.SplitQuery(streamWithToken(p.StreamName, p.TokenStr))
+	auth.MergeParams(params, rc.connInfo.params)
+
+	rc.streamName = name
+	rc.tokenStr = params.Get("token")
+	rc.params = params
+
+	if err = rc.authenticate(auth.ActionPlay, params); err != nil {
+		err = rc.rejectStream(pt.StatusCodePlayFailed, err)
+		return
+	}
 
-	rc.streamName = p.StreamName
+	if err = rc.sources.hooks.Call(rc.hookEvent(hooks.OnPlay, params)); err != nil {
+		err = rc.rejectStream(pt.StatusCodePlayFailed, err)
+		return
+	}
 
 	// set chunk size to peer.
 	var pkt pt.SetChunkSizePacket
-	pkt.ChunkSize = conf.GlobalConfInfo.Rtmp.ChunkSize
+	pkt.ChunkSize = rc.sources.Config().Rtmp.ChunkSize
 	if err = rc.sendPacket(&pkt, msg.Header.StreamID); err != nil {
 		return
 	}
-	log.Println("player, send request, set chunk size to ", pkt.ChunkSize)
+	rc.logger.Println("player, send request, set chunk size to ", pkt.ChunkSize)
 
 	// after send set chunk size to remote success, set out chunk size
 	rc.outChunkSize = pkt.ChunkSize
 
 	srcKey := rc.getSourceKey()
-	source := GlobalSources.FindSourceToPlay(srcKey)
+	source := rc.sources.FindSourceToPlay(srcKey)
 	if nil == source {
 		err = fmt.Errorf("stream=%s can not play because has not published", rc.streamName)
 		return
 	}
-	log.Println("play success. stream=", srcKey)
+	rc.logger.Println("play success. stream=", srcKey)
 
 	rc.source = source
 	rc.role = pt.RtmpRolePlayer
+	rc.storeState()
 
 	//response start play.
 	// StreamBegin
@@ -293,24 +344,28 @@ func (rc *RtmpConn) amf0Play(msg *pt.Message) (err error) {
 		if err != nil {
 			return
 		}
-		log.Println("send play stream begin pkt success.")
+		rc.logger.Println("send play stream begin pkt success.")
 	}
 
 	// onStatus(NetStream.Play.Reset)
 	if true {
 		var pp pt.OnStatusCallPacket
 		pp.CommandName = pt.RtmpAmf0CommandOnStatus
-		pp.AddObj(pt.NewAmf0Object(pt.StatusLevel, pt.StatusLevelStatus, pt.RtmpAmf0String))
-		pp.AddObj(pt.NewAmf0Object(pt.StatusCode, pt.StatusCodeStreamReset, pt.RtmpAmf0String))
-		pp.AddObj(pt.NewAmf0Object(pt.StatusDescription, "Playing and resetting stream.", pt.RtmpAmf0String))
-		pp.AddObj(pt.NewAmf0Object(pt.StatusDetails, "stream", pt.RtmpAmf0String))
-		pp.AddObj(pt.NewAmf0Object(pt.StatusClientID, pt.RtmpSigClientID, pt.RtmpAmf0String))
+		if err = pp.Marshal(&pt.StatusInfo{
+			Level:       pt.StatusLevelStatus,
+			Code:        pt.StatusCodeStreamReset,
+			Description: "Playing and resetting stream.",
+			Details:     "stream",
+			ClientID:    pt.RtmpSigClientID,
+		}); err != nil {
+			return
+		}
 
 		if err = rc.sendPacket(&pp, uint32(rc.defaultStreamID)); err != nil {
 			return
 		}
 
-		log.Println("send play onStatus(NetStream.Play.Reset) success.")
+		rc.logger.Println("send play onStatus(NetStream.Play.Reset) success.")
 	}
 
 	// onStatus(NetStream.Play.Start)
@@ -318,17 +373,21 @@ func (rc *RtmpConn) amf0Play(msg *pt.Message) (err error) {
 		var pp pt.OnStatusCallPacket
 		pp.CommandName = pt.RtmpAmf0CommandOnStatus
 
-		pp.AddObj(pt.NewAmf0Object(pt.StatusLevel, pt.StatusLevelStatus, pt.RtmpAmf0String))
-		pp.AddObj(pt.NewAmf0Object(pt.StatusCode, pt.StatusCodeStreamStart, pt.RtmpAmf0String))
-		pp.AddObj(pt.NewAmf0Object(pt.StatusDescription, "Started playing stream.", pt.RtmpAmf0String))
-		pp.AddObj(pt.NewAmf0Object(pt.StatusDetails, "stream", pt.RtmpAmf0String))
-		pp.AddObj(pt.NewAmf0Object(pt.StatusClientID, pt.RtmpSigClientID, pt.RtmpAmf0String))
+		if err = pp.Marshal(&pt.StatusInfo{
+			Level:       pt.StatusLevelStatus,
+			Code:        pt.StatusCodeStreamStart,
+			Description: "Started playing stream.",
+			Details:     "stream",
+			ClientID:    pt.RtmpSigClientID,
+		}); err != nil {
+			return
+		}
 
 		if err = rc.sendPacket(&pp, uint32(rc.defaultStreamID)); err != nil {
 			return
 		}
 
-		log.Println("send NetStream.Play.Reset response success.")
+		rc.logger.Println("send NetStream.Play.Reset response success.")
 	}
 
 	// |RtmpSampleAccess(false, false)
@@ -342,7 +401,7 @@ func (rc *RtmpConn) amf0Play(msg *pt.Message) (err error) {
 			return
 		}
 
-		log.Println("send RtmpSampleAccess success")
+		rc.logger.Println("send RtmpSampleAccess success")
 	}
 
 	// onStatus(NetStream.Data.Start)
@@ -350,56 +409,33 @@ func (rc *RtmpConn) amf0Play(msg *pt.Message) (err error) {
 		var pp pt.OnStatusDataPacket
 		pp.CommandName = pt.RtmpAmf0CommandOnStatus
 
-		pp.AddObj(pt.NewAmf0Object(pt.StatusLevel, pt.StatusLevelStatus, pt.RtmpAmf0String))
-		pp.AddObj(pt.NewAmf0Object(pt.StatusCode, pt.StatusCodeDataStart, pt.RtmpAmf0String))
-		pp.AddObj(pt.NewAmf0Object(pt.StatusDescription, "Started playing stream data.", pt.RtmpAmf0String))
+		if err = pp.Marshal(&pt.StatusInfo{
+			Level:       pt.StatusLevelStatus,
+			Code:        pt.StatusCodeDataStart,
+			Description: "Started playing stream data.",
+		}); err != nil {
+			return
+		}
 
 		if err = rc.sendPacket(&pp, uint32(rc.defaultStreamID)); err != nil {
 			return
 		}
 
-		log.Println("send NetStream.Data.Start success.")
+		rc.logger.Println("send NetStream.Data.Start success.")
 	}
 
 	rc.consumer = NewConsumer("rtmp/" + rc.streamName)
+	rc.storeState()
 
 	rc.source.CreateConsumer(rc.consumer)
 
-	if rc.source.Atc && !rc.source.GopCache.Empty() {
-		if nil != rc.source.CacheMetaData {
-			rc.source.CacheMetaData.Header.Timestamp = rc.source.GopCache.StartTime()
-		}
-		if nil != rc.source.CacheVideoSequenceHeader {
-			rc.source.CacheVideoSequenceHeader.Header.Timestamp = rc.source.GopCache.StartTime()
-		}
-		if nil != rc.source.CacheAudioSequenceHeader {
-			rc.source.CacheAudioSequenceHeader.Header.Timestamp = rc.source.GopCache.StartTime()
-		}
-	}
+	rc.source.DumpCache(rc.consumer)
 
-	//cache meta data
-	if nil != rc.source.CacheMetaData {
-		rc.consumer.Enquene(rc.source.CacheMetaData, rc.source.Atc, rc.source.SampleRate, rc.source.FrameRate, rc.source.TimeJitter)
-	}
-
-	//cache video data
-	if nil != rc.source.CacheVideoSequenceHeader {
-		rc.consumer.Enquene(rc.source.CacheVideoSequenceHeader, rc.source.Atc, rc.source.SampleRate, rc.source.FrameRate, rc.source.TimeJitter)
-	}
-
-	//cache audio data
-	if nil != rc.source.CacheAudioSequenceHeader {
-		rc.consumer.Enquene(rc.source.CacheAudioSequenceHeader, rc.source.Atc, rc.source.SampleRate, rc.source.FrameRate, rc.source.TimeJitter)
-	}
-
-	//Dump gop cache to client.
-	rc.source.GopCache.Dump(rc.consumer, rc.source.Atc, rc.source.SampleRate, rc.source.FrameRate, rc.source.TimeJitter)
-
-	log.Println("now playing, stream=", rc.streamName)
+	rc.logger.Println("now playing, stream=", rc.streamName)
 
 	err = rc.playing(&p)
 
-	log.Println("playing over, stream=", rc.streamName)
+	rc.logger.Println("playing over, stream=", rc.streamName)
 
 	return
 }
@@ -407,11 +443,11 @@ func (rc *RtmpConn) amf0Play(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0Pause(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0Pause")
+	rc.logger.Println("Amf0Pause")
 
 	if nil == msg {
 		return
@@ -428,11 +464,11 @@ func (rc *RtmpConn) amf0Pause(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0ReleaseStream(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0ReleaseStream")
+	rc.logger.Println("Amf0ReleaseStream")
 
 	if nil == msg {
 		return
@@ -449,15 +485,15 @@ func (rc *RtmpConn) amf0ReleaseStream(msg *pt.Message) (err error) {
 	if err = rc.sendPacket(&pp, 0); err != nil {
 		return
 	}
-	log.Println("send release stream response success.")
+	rc.logger.Println("send release stream response success.")
 
 	// set chunk size to peer.
 	var pkt pt.SetChunkSizePacket
-	pkt.ChunkSize = conf.GlobalConfInfo.Rtmp.ChunkSize
+	pkt.ChunkSize = rc.sources.Config().Rtmp.ChunkSize
 	if err = rc.sendPacket(&pkt, msg.Header.StreamID); err != nil {
 		return
 	}
-	log.Println("publisher, send request, set chunk size to ", pkt.ChunkSize)
+	rc.logger.Println("publisher, send request, set chunk size to ", pkt.ChunkSize)
 
 	// after set chunk size success, set out chunk size
 	rc.outChunkSize = pkt.ChunkSize
@@ -468,11 +504,11 @@ func (rc *RtmpConn) amf0ReleaseStream(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0FcPublish(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0FcPublish")
+	rc.logger.Println("Amf0FcPublish")
 
 	if nil == msg {
 		return
@@ -489,7 +525,7 @@ func (rc *RtmpConn) amf0FcPublish(msg *pt.Message) (err error) {
 	if err = rc.sendPacket(&pp, 0); err != nil {
 		return
 	}
-	log.Println("send FcPublish response success.")
+	rc.logger.Println("send FcPublish response success.")
 
 	return
 }
@@ -497,11 +533,11 @@ func (rc *RtmpConn) amf0FcPublish(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0Publish(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0Publish")
+	rc.logger.Println("Amf0Publish")
 
 	if nil == msg {
 		return
@@ -512,35 +548,57 @@ func (rc *RtmpConn) amf0Publish(msg *pt.Message) (err error) {
 		return
 	}
 
-	log.Println("a new publisher come in, publish info=", p)
+	rc.logger.Println("a new publisher come in, publish info=", p)
+
+	// the packet split the token from stream name, join it back to parse all the params.
+	name, params := auth.SplitQuery(streamWithToken(p.StreamName, p.TokenStr))
+	auth.MergeParams(params, rc.connInfo.params)
+
+	rc.streamName = name
+	rc.tokenStr = params.Get("token")
+	rc.params = params
+
+	if err = rc.authenticate(auth.ActionPublish, params); err != nil {
+		err = rc.rejectStream(pt.StatusCodePublishBadName, err)
+		return
+	}
 
-	rc.streamName = p.StreamName
+	if err = rc.sources.hooks.Call(rc.hookEvent(hooks.OnPublish, params)); err != nil {
+		err = rc.rejectStream(pt.StatusCodePublishBadName, err)
+		return
+	}
 
 	srcKey := rc.getSourceKey()
-	source := GlobalSources.findSourceToPublish(srcKey)
+	source := rc.sources.findSourceToPublish(srcKey, rc.tcpConn.RemoteAddr().String())
 	if nil == source {
 		err = fmt.Errorf("stream=%s can not publish, find source is nil", rc.streamName)
 		return
 	}
-	log.Println("published success, stream=", srcKey)
+	rc.logger.Println("published success, stream=", srcKey)
 
 	rc.source = source
 	rc.role = pt.RtmpRoleFMLEPublisher
+	rc.storeState()
 
 	var pp pt.OnStatusCallPacket
 	pp.CommandName = pt.RtmpAmf0CommandOnStatus
-	pp.AddObj(pt.NewAmf0Object(pt.StatusCode, pt.StatusCodePublishStart, pt.RtmpAmf0String))
-	pp.AddObj(pt.NewAmf0Object(pt.StatusDescription, "Started publishing stream.", pt.RtmpAmf0String))
+	if err = pp.Marshal(&pt.StatusInfo{
+		Level:       pt.StatusLevelStatus,
+		Code:        pt.StatusCodePublishStart,
+		Description: "Started publishing stream.",
+	}); err != nil {
+		return
+	}
 
 	if err = rc.sendPacket(&pp, uint32(rc.defaultStreamID)); err != nil {
 		return
 	}
 
-	log.Println("send publish response success.")
+	rc.logger.Println("send publish response success.")
 
 	if nil != rc.source.hls {
 		if err = rc.source.hls.OnPublish(rc.connInfo.app, rc.streamName); err != nil {
-			log.Println("hls onpublish failed, err=", err)
+			rc.logger.Println("hls onpublish failed, err=", err)
 			return
 		}
 	}
@@ -551,11 +609,11 @@ func (rc *RtmpConn) amf0Publish(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0UnPublish(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0UnPublish")
+	rc.logger.Println("Amf0UnPublish")
 
 	if nil == msg {
 		return
@@ -572,7 +630,7 @@ func (rc *RtmpConn) amf0UnPublish(msg *pt.Message) (err error) {
 	if err = rc.sendPacket(&pp, 0); err != nil {
 		return
 	}
-	log.Println("send unpublish response success.")
+	rc.logger.Println("send unpublish response success.")
 
 	return
 }
@@ -580,7 +638,7 @@ func (rc *RtmpConn) amf0UnPublish(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0Meta(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -592,24 +650,35 @@ func (rc *RtmpConn) amf0Meta(msg *pt.Message) (err error) {
 	if err = p.Decode(msg.Payload.Payload); err != nil {
 		return
 	}
-	log.Println("decode meta data success, meta=", p)
+	rc.logger.Println("decode meta data success, meta=", p)
+
+	var meta streamMetadata
+	if err = p.Unmarshal(&meta); err != nil {
+		rc.logger.Println("decode meta data failed, err=", err)
+		return
+	}
 
 	//add server info to metadata
-	p.AddObject(*pt.NewAmf0Object("server", "seal rtmp server", pt.RtmpAmf0String))
-	p.AddObject(*pt.NewAmf0Object("primary", "YangKai", pt.RtmpAmf0String))
-	p.AddObject(*pt.NewAmf0Object("author", "YangKai", pt.RtmpAmf0String))
+	if err = p.AddProperties(&serverMetadata{
+		Server:  "seal rtmp server",
+		Primary: "YangKai",
+		Author:  "YangKai",
+	}); err != nil {
+		return
+	}
 
-	if v := p.GetProperty("audiosamplerate"); v != nil {
-		rc.source.SampleRate = v.(float64)
+	if 0 != meta.AudioSampleRate {
+		rc.source.SampleRate = meta.AudioSampleRate
 	}
 
-	if v := p.GetProperty("framerate"); v != nil {
-		rc.source.FrameRate = v.(float64)
+	if 0 != meta.FrameRate {
+		rc.source.FrameRate = meta.FrameRate
 	}
 
-	rc.source.Atc = conf.GlobalConfInfo.Rtmp.Atc
-	if v := p.GetProperty("bravo_atc"); v != nil {
-		if conf.GlobalConfInfo.Rtmp.AtcAuto {
+	c := rc.streamConfig()
+	rc.source.Atc = c.Rtmp.Atc
+	if nil != meta.BravoAtc {
+		if c.Rtmp.AtcAuto {
 			rc.source.Atc = true
 		}
 	}
@@ -621,23 +690,19 @@ func (rc *RtmpConn) amf0Meta(msg *pt.Message) (err error) {
 	if err = p.Decode(msg.Payload.Payload); err != nil {
 		return
 	}
-	log.Println("meta data is ", p)
+	rc.logger.Println("meta data is ", p)
 
 	// hls
 	if nil != rc.source.hls {
 		if err = rc.source.hls.OnMeta(&p); err != nil {
-			log.Println("hls process metadata failed, err=", err)
+			rc.logger.Println("hls process metadata failed, err=", err)
 			return
 		}
 	}
 
-	//cache meta data
-	if nil != rc.source {
-		rc.source.CacheMetaData = msg
-		log.Println("cache metadata")
-	}
-
-	rc.source.copyToAllConsumers(msg)
+	//copy to all consumers, and cache meta data
+	rc.source.copyToAllConsumers(msg, &rc.source.CacheMetaData)
+	rc.logger.Println("cache metadata")
 
 	return
 }
@@ -645,11 +710,11 @@ func (rc *RtmpConn) amf0Meta(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0OnCustomer(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0OnCustomer")
+	rc.logger.Println("Amf0OnCustomer")
 
 	if nil == msg {
 		return
@@ -666,11 +731,11 @@ func (rc *RtmpConn) amf0OnCustomer(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0CloseStream(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0CloseStream")
+	rc.logger.Println("Amf0CloseStream")
 
 	if nil == msg {
 		return
@@ -687,11 +752,11 @@ func (rc *RtmpConn) amf0CloseStream(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0OnBwDone(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0OnBwDone")
+	rc.logger.Println("Amf0OnBwDone")
 
 	if nil == msg {
 		return
@@ -703,27 +768,56 @@ func (rc *RtmpConn) amf0OnBwDone(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0OnStatus(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0Onstats")
+	rc.logger.Println("Amf0Onstats")
 
 	if nil == msg {
 		return
 	}
 
+	var info pt.StatusInfo
+
+	if msg.Header.IsAmf0Data() {
+		p := pt.OnStatusDataPacket{}
+		if err = p.Decode(msg.Payload.Payload); err != nil {
+			return
+		}
+
+		if err = p.Unmarshal(&info); err != nil {
+			return
+		}
+	} else {
+		p := pt.OnStatusCallPacket{}
+		if err = p.Decode(msg.Payload.Payload); err != nil {
+			return
+		}
+
+		if err = p.Unmarshal(&info); err != nil {
+			return
+		}
+	}
+
+	rc.logger.Println("onStatus, level=", info.Level, ", code=", info.Code)
+
+	if pt.StatusLevelError == info.Level {
+		err = fmt.Errorf("peer response onStatus error, code=%s", info.Code)
+		return
+	}
+
 	return
 }
 
 func (rc *RtmpConn) amf0GetStreamLen(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0GetStreamLen")
+	rc.logger.Println("Amf0GetStreamLen")
 
 	if nil == msg {
 		return
@@ -735,11 +829,11 @@ func (rc *RtmpConn) amf0GetStreamLen(msg *pt.Message) (err error) {
 func (rc *RtmpConn) amf0SampleAccess(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("Amf0SampleAccess")
+	rc.logger.Println("Amf0SampleAccess")
 
 	if nil == msg {
 		return
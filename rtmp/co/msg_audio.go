@@ -1,7 +1,6 @@
 package co
 
 import (
-	"log"
 	"seal/rtmp/flv"
 	"seal/rtmp/pt"
 
@@ -11,7 +10,7 @@ import (
 func (rc *RtmpConn) msgAudio(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -22,23 +21,20 @@ func (rc *RtmpConn) msgAudio(msg *pt.Message) (err error) {
 	// hls
 	if nil != rc.source.hls {
 		if err = rc.source.hls.OnAudio(msg); err != nil {
-			log.Println("hls process audio data failed, err=", err)
+			rc.logger.Println("hls process audio data failed, err=", err)
 			return
 		}
 	}
 
-	//copy to all consumers
-	rc.source.copyToAllConsumers(msg)
-
-	//cache the sequence.
+	//copy to all consumers, and cache the sequence, or the audio to gop cache.
 	// do not cache the sequence header to gop cache, return here
 	if flv.AudioIsSequenceHeader(msg.Payload.Payload) {
-		rc.source.CacheAudioSequenceHeader = msg
-		log.Println("cache audio data sequence")
+		rc.source.copyToAllConsumers(msg, &rc.source.CacheAudioSequenceHeader)
+		rc.logger.Println("cache audio data sequence")
 		return
 	}
 
-	rc.source.GopCache.cache(msg)
+	rc.source.copyToAllConsumers(msg, nil)
 
 	//if rc.source.Atc {
 	//	if nil != rc.source.CacheAudioSequenceHeader {
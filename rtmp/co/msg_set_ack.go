@@ -1,7 +1,6 @@
 package co
 
 import (
-	"log"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
@@ -10,11 +9,11 @@ import (
 func (rc *RtmpConn) msgSetAck(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("MsgSetChunk")
+	rc.logger.Println("MsgSetChunk")
 
 	if nil == msg {
 		return
@@ -27,7 +26,7 @@ func (rc *RtmpConn) msgSetAck(msg *pt.Message) (err error) {
 
 	if p.AckowledgementWindowSize > 0 {
 		rc.ack.ackWindowSize = p.AckowledgementWindowSize
-		log.Println("set ack window size=", p.AckowledgementWindowSize)
+		rc.logger.Println("set ack window size=", p.AckowledgementWindowSize)
 	}
 
 	return
@@ -1,7 +1,6 @@
 package co
 
 import (
-	"log"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
@@ -10,11 +9,11 @@ import (
 func (rc *RtmpConn) msgSetBand(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("MsgSetBand")
+	rc.logger.Println("MsgSetBand")
 
 	if nil == msg {
 		return
@@ -1,7 +1,6 @@
 package co
 
 import (
-	"log"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
@@ -10,11 +9,11 @@ import (
 func (rc *RtmpConn) msgSetChunk(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("set chunk size")
+	rc.logger.Println("set chunk size")
 
 	if nil == msg {
 		return
@@ -27,9 +26,9 @@ func (rc *RtmpConn) msgSetChunk(msg *pt.Message) (err error) {
 
 	if p.ChunkSize >= pt.RtmpChunkSizeMin && p.ChunkSize <= pt.RtmpChunkSizeMax {
 		rc.inChunkSize = p.ChunkSize
-		log.Println("peer set chunk size to ", p.ChunkSize)
+		rc.logger.Println("peer set chunk size to ", p.ChunkSize)
 	}
-	log.Println("remote set chunk size to ", rc.inChunkSize)
+	rc.logger.Println("remote set chunk size to ", rc.inChunkSize)
 
 	return
 }
@@ -1,7 +1,6 @@
 package co
 
 import (
-	"log"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
@@ -10,11 +9,11 @@ import (
 func (rc *RtmpConn) msgUserCtrl(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
-	log.Println("MsgUserCtrl")
+	rc.logger.Println("MsgUserCtrl")
 
 	if nil == msg {
 		return
@@ -25,7 +24,7 @@ func (rc *RtmpConn) msgUserCtrl(msg *pt.Message) (err error) {
 		return
 	}
 
-	log.Println("MsgUserCtrl event type=", p.EventType)
+	rc.logger.Println("MsgUserCtrl event type=", p.EventType)
 
 	switch p.EventType {
 	case pt.SrcPCUCStreamBegin:
@@ -37,7 +36,7 @@ func (rc *RtmpConn) msgUserCtrl(msg *pt.Message) (err error) {
 		err = rc.ctrlPingRequest(&p)
 	case pt.SrcPCUCPingResponse:
 	default:
-		log.Println("msg user ctrl unknown event type.type=", p.EventType)
+		rc.logger.Println("msg user ctrl unknown event type.type=", p.EventType)
 	}
 
 	if err != nil {
@@ -49,7 +48,7 @@ func (rc *RtmpConn) msgUserCtrl(msg *pt.Message) (err error) {
 
 func (rc *RtmpConn) ctrlPingRequest(p *pt.UserControlPacket) (err error) {
 
-	log.Println("ctrl ping request.")
+	rc.logger.Println("ctrl ping request.")
 
 	if pt.SrcPCUCSetBufferLength == p.EventType {
 
@@ -61,7 +60,7 @@ func (rc *RtmpConn) ctrlPingRequest(p *pt.UserControlPacket) (err error) {
 			return
 		}
 
-		log.Println("send ping response success.")
+		rc.logger.Println("send ping response success.")
 
 	}
 
@@ -1,7 +1,6 @@
 package co
 
 import (
-	"log"
 	"seal/rtmp/flv"
 	"seal/rtmp/pt"
 
@@ -11,7 +10,7 @@ import (
 func (rc *RtmpConn) msgVideo(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -20,38 +19,29 @@ func (rc *RtmpConn) msgVideo(msg *pt.Message) (err error) {
 	}
 
 	//if flv.VideoH264IsKeyframe(msg.Payload.Payload) {
-	//	log.Printf("+++++recv i frame msg, type=%d, time=%d, len=%d\n", msg.Header.MessageType, msg.Header.Timestamp, msg.Header.PayloadLength)
+	//	rc.logger.Printf("+++++recv i frame msg, type=%d, time=%d, len=%d\n", msg.Header.MessageType, msg.Header.Timestamp, msg.Header.PayloadLength)
 	//}
 
 	// hls
 	if nil != rc.source.hls {
 		if err = rc.source.hls.OnVideo(msg); err != nil {
-			log.Println("hls process video data failed, err=", err)
+			rc.logger.Println("hls process video data failed, err=", err)
 			return
 		}
 	}
 
-	//copy to all consumers
-	rc.source.copyToAllConsumers(msg)
-
-	//cache the key frame
+	//copy to all consumers, and cache the sequence header, or the key frame to gop cache.
 	// do not cache the sequence header to gop cache, return here
 	if flv.VideoH264IsKeyFrameAndSequenceHeader(msg.Payload.Payload) {
-		rc.source.CacheVideoSequenceHeader = msg
-		log.Println("cache video sequence")
+		rc.source.copyToAllConsumers(msg, &rc.source.CacheVideoSequenceHeader)
+		rc.logger.Println("cache video sequence")
 		return
 	}
 
-	rc.source.GopCache.cache(msg)
+	rc.source.copyToAllConsumers(msg, nil)
 
 	if rc.source.Atc {
-		if nil != rc.source.CacheVideoSequenceHeader {
-			rc.source.CacheVideoSequenceHeader.Header.Timestamp = msg.Header.Timestamp
-		}
-
-		if nil != rc.source.CacheMetaData {
-			rc.source.CacheMetaData.Header.Timestamp = msg.Header.Timestamp
-		}
+		rc.source.setCacheTimestamp(msg.Header.Timestamp)
 	}
 
 	return
@@ -1,7 +1,6 @@
 package co
 
 import (
-	"log"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
@@ -10,7 +9,7 @@ import (
 func (rc *RtmpConn) onRecvMsg(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -48,7 +47,7 @@ func (rc *RtmpConn) onRecvMsg(msg *pt.Message) (err error) {
 	case pt.RtmpMsgAggregateMessage:
 		err = rc.msgAggregate(msg)
 	default:
-		log.Println("on recv msg unknown msg typeid=", msg.Header.MessageType)
+		rc.logger.Println("on recv msg unknown msg typeid=", msg.Header.MessageType)
 	}
 
 	if err != nil {
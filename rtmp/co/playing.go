@@ -2,10 +2,11 @@ package co
 
 import (
 	"fmt"
-	"log"
-	"seal/conf"
+	"net"
 	"seal/rtmp/pt"
 	"time"
+
+	"github.com/calabashdad/utiltools"
 )
 
 func (rc *RtmpConn) playing(p *pt.PlayPacket) (err error) {
@@ -13,47 +14,84 @@ func (rc *RtmpConn) playing(p *pt.PlayPacket) (err error) {
 	userSpecifiedDurationToStop := p.Duration > 0
 	var startTime int64 = -1
 
-	timeLast := time.Now().Unix()
+	// recv the play control msgs from client in another goroutine,
+	// so the playing is waked up only when there are msgs to send or control msgs recved.
+	ctrl := make(chan *pt.Message)
+	done := make(chan struct{})
+	defer close(done)
+
+	// the responses of play are buffered, send them before waiting for msgs.
+	if err = rc.tcpConn.Flush(); err != nil {
+		return
+	}
+
+	go rc.recvControl(ctrl, done)
+
+	// the chunks of msgs sent in one writev, reused to avoid alloc.
+	var b chunkBuffers
 
 	for {
-		//read from client. use short time out.
-		//if recv failed, it's ok, not an error.
-		if true {
-			const timeOutUs = 10 * 1000 //ms
-			rc.tcpConn.SetRecvTimeout(timeOutUs)
+		if rc.consumer.Kicked() {
+			err = fmt.Errorf("player is kicked, stream=%s", rc.getSourceKey())
+			break
+		}
 
-			var msg pt.Message
-			if localErr := rc.recvMsg(&msg.Header, &msg.Payload); localErr != nil {
-				// do nothing, it's ok
+		// handle the control msgs first, e.g. pause.
+		select {
+		case msg, ok := <-ctrl:
+			if !ok {
+				err = fmt.Errorf("player recv control msg failed, stream=%s", rc.getSourceKey())
+				return
 			}
-			if len(msg.Payload.Payload) > 0 {
-				//has recved play control.
-				err = rc.handlePlayData(&msg)
-				if err != nil {
-					log.Println("playing... handle play data faield.err=", err)
-					return
-				}
+
+			if err = rc.handlePlayData(msg); err != nil {
+				rc.logger.Println("playing... handle play data faield.err=", err)
+				return
+			}
+
+			if err = rc.tcpConn.Flush(); err != nil {
+				return
 			}
+
+			continue
+		default:
 		}
 
-		// reset the socket send and recv timeout
-		rc.tcpConn.SetRecvTimeout(conf.GlobalConfInfo.Rtmp.TimeOut * 1000 * 1000)
-		rc.tcpConn.SetSendTimeout(conf.GlobalConfInfo.Rtmp.TimeOut * 1000 * 1000)
+		// get the notify before dump, in case the msg published after dump.
+		notify := rc.source.ring.wait()
 
 		msg := rc.consumer.Dump()
 		if nil == msg {
 			// wait and try again.
-			timeCurrent := time.Now().Unix()
-			if timeCurrent-timeLast > 5 {
-				log.Println("rtmp playing time out > 5 seconds, break. key=", rc.streamName)
-				break
+			select {
+			case <-notify:
+			case <-rc.consumer.kicked:
+			case m, ok := <-ctrl:
+				if !ok {
+					err = fmt.Errorf("player recv control msg failed, stream=%s", rc.getSourceKey())
+					return
+				}
+
+				if err = rc.handlePlayData(m); err != nil {
+					rc.logger.Println("playing... handle play data faield.err=", err)
+					return
+				}
+
+				if err = rc.tcpConn.Flush(); err != nil {
+					return
+				}
+			case <-time.After(time.Duration(5) * time.Second):
+				// the recv goroutine is still running, so quit the connection.
+				err = fmt.Errorf("rtmp playing time out > 5 seconds, key=%s", rc.streamName)
+				return
 			}
 
 			continue
-		} else {
-			timeLast = time.Now().Unix()
+		}
 
-			//send to remote
+		// batch the msgs available, the msg dumped is only valid until next dump,
+		// but the chunks appended refer to the payload shared, so it's ok.
+		for nil != msg {
 			// only when user specifies the duration,
 			// we start to collect the durations for each message.
 			if userSpecifiedDurationToStop {
@@ -65,24 +103,80 @@ func (rc *RtmpConn) playing(p *pt.PlayPacket) (err error) {
 				startTime = int64(msg.Header.Timestamp)
 			}
 
-			if err = rc.sendMsg(msg); err != nil {
-				log.Println("playing... send to remote failed.err=", err)
+			rc.appendMsg(&b, msg)
+			rc.consumer.AddSendBytes(msg.Header.PayloadLength)
+
+			if b.msgs >= playBatchMaxMsgs || b.size >= playBatchMaxBytes {
 				break
 			}
+
+			msg = rc.consumer.Dump()
+		}
+
+		//send to remote, the msgs dumped are released after sent.
+		err = rc.sendChunks(&b)
+		rc.consumer.Release()
+
+		if err != nil {
+			rc.logger.Println("playing... send to remote failed.err=", err)
+			break
 		}
 	}
 
 	return
 }
 
+// recvControl recv the msgs from peer and send to ctrl until done, e.g. the pause of player,
+// or the ack and ping of the remote forwarded to. ctrl is closed when recv failed, e.g. the peer closed the connection.
+func (rc *RtmpConn) recvControl(ctrl chan<- *pt.Message, done <-chan struct{}) {
+	defer func() {
+		if err := recover(); err != nil {
+			rc.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	defer close(ctrl)
+
+	for {
+		msg := &pt.Message{}
+		if err := rc.recvMsg(&msg.Header, &msg.Payload); err != nil {
+			// the peer may not send any msg for a long time, it's ok.
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				continue
+			}
+
+			rc.logger.Println("recv control msg failed, err=", err)
+			return
+		}
+
+		if 0 == len(msg.Payload.Payload) {
+			continue
+		}
+
+		select {
+		case ctrl <- msg:
+		case <-done:
+			return
+		}
+	}
+}
+
 func (rc *RtmpConn) handlePlayData(msg *pt.Message) (err error) {
 
 	if nil == msg {
 		return
 	}
 
+	msg.ConvertAmf3ToAmf0()
+
 	if msg.Header.IsAmf0Data() || msg.Header.IsAmf3Data() {
-		log.Println("play data: recv handled play amf data")
+		rc.logger.Println("play data: recv handled play amf data")
 	} else {
 		//process user control
 		rc.handlePlayUserControl(msg)
@@ -99,7 +193,7 @@ func (rc *RtmpConn) handlePlayUserControl(msg *pt.Message) (err error) {
 
 	if msg.Header.IsAmf0Command() || msg.Header.IsAmf3Command() {
 		//ignore
-		log.Println("ignore amf cmd when handle play user control.")
+		rc.logger.Println("ignore amf cmd when handle play user control.")
 		return
 	}
 
@@ -140,12 +234,12 @@ func (rc *RtmpConn) handlePlayUserControl(msg *pt.Message) (err error) {
 			// it's ok
 		} else {
 			if err = rc.onPlayClientPause(uint32(rc.defaultStreamID), p.IsPause); err != nil {
-				log.Println("play client pause error.err=", err)
+				rc.logger.Println("play client pause error.err=", err)
 				return
 			}
 
 			if err = rc.consumer.onPlayPause(p.IsPause); err != nil {
-				log.Println("consumer on play pause error.err=", err)
+				rc.logger.Println("consumer on play pause error.err=", err)
 				return
 			}
 		}
@@ -161,9 +255,13 @@ func (rc *RtmpConn) onPlayClientPause(streamID uint32, isPause bool) (err error)
 		p := pt.OnStatusCallPacket{}
 		p.CommandName = pt.RtmpAmf0CommandOnStatus
 
-		p.AddObj(pt.NewAmf0Object(pt.StatusLevel, pt.StatusLevelStatus, pt.RtmpAmf0String))
-		p.AddObj(pt.NewAmf0Object(pt.StatusCode, pt.StatusCodeStreamPause, pt.RtmpAmf0String))
-		p.AddObj(pt.NewAmf0Object(pt.StatusDescription, "Paused stream.", pt.RtmpAmf0String))
+		if err = p.Marshal(&pt.StatusInfo{
+			Level:       pt.StatusLevelStatus,
+			Code:        pt.StatusCodeStreamPause,
+			Description: "Paused stream.",
+		}); err != nil {
+			return
+		}
 
 		if err = rc.sendPacket(&p, streamID); err != nil {
 			return
@@ -176,7 +274,7 @@ func (rc *RtmpConn) onPlayClientPause(streamID uint32, isPause bool) (err error)
 			p.EventData = streamID
 
 			if err = rc.sendPacket(&p, streamID); err != nil {
-				log.Println("send PCUC(StreamEOF) message failed.")
+				rc.logger.Println("send PCUC(StreamEOF) message failed.")
 				return
 			}
 		}
@@ -186,9 +284,13 @@ func (rc *RtmpConn) onPlayClientPause(streamID uint32, isPause bool) (err error)
 		p := pt.OnStatusCallPacket{}
 		p.CommandName = pt.RtmpAmf0CommandOnStatus
 
-		p.AddObj(pt.NewAmf0Object(pt.StatusLevel, pt.StatusLevelStatus, pt.RtmpAmf0String))
-		p.AddObj(pt.NewAmf0Object(pt.StatusCode, pt.StatusCodeStreamUnpause, pt.RtmpAmf0String))
-		p.AddObj(pt.NewAmf0Object(pt.StatusDescription, "UnPaused stream.", pt.RtmpAmf0String))
+		if err = p.Marshal(&pt.StatusInfo{
+			Level:       pt.StatusLevelStatus,
+			Code:        pt.StatusCodeStreamUnpause,
+			Description: "UnPaused stream.",
+		}); err != nil {
+			return
+		}
 
 		if err = rc.sendPacket(&p, streamID); err != nil {
 			return
@@ -201,7 +303,7 @@ func (rc *RtmpConn) onPlayClientPause(streamID uint32, isPause bool) (err error)
 			p.EventData = streamID
 
 			if err = rc.sendPacket(&p, streamID); err != nil {
-				log.Println("send PCUC(StreanBegin) message failed.")
+				rc.logger.Println("send PCUC(StreanBegin) message failed.")
 				return
 			}
 		}
@@ -0,0 +1,217 @@
+package co
+
+import (
+	"fmt"
+	"seal/conf"
+	"seal/rtmp/pt"
+	"sync"
+	"time"
+
+	"github.com/calabashdad/utiltools"
+)
+
+// PullClient pull stream from remote rtmp server,
+// and publish it to local sources as if it were a local publisher.
+type PullClient struct {
+	// urls the remote stream urls, e.g. rtmp://127.0.0.1:1935/live/test
+	// try the next one when failed.
+	urls  []string
+	index int
+	// key the local source key, e.g. live/test, or vhost/live/test of the vhost configured.
+	// use the app/stream of url if empty.
+	key string
+	// source the local source to publish to, only for edge.
+	// find source to publish by key if nil.
+	source *SourceStream
+	// sources the hub of server which the pull client belongs to.
+	sources *SourceHub
+
+	mu      sync.Mutex
+	rc      *RtmpConn
+	stopped bool
+}
+
+// NewPullClient create a pull client which publishes to the hub
+func (s *SourceHub) NewPullClient(url string, key string) *PullClient {
+	return &PullClient{
+		urls:    []string{url},
+		key:     key,
+		sources: s,
+	}
+}
+
+// newEdgePullClient create a pull client for edge, pull the stream key from origins to the source.
+func newEdgePullClient(origins []string, key string, source *SourceStream) *PullClient {
+	pc := &PullClient{
+		key:     key,
+		source:  source,
+		sources: source.hub,
+	}
+
+	// pull the same vhost from origin by param, e.g. rtmp://origin/live/test?vhost=xxx
+	vhost, app, stream := source.hub.parseStreamKey(key)
+	param := ""
+	if conf.DefaultVhost != vhost {
+		param = "?vhost=" + vhost
+	}
+
+	for _, v := range origins {
+		pc.urls = append(pc.urls, "rtmp://"+v+"/"+app+"/"+stream+param)
+	}
+
+	return pc
+}
+
+// Start pull stream from remote, retry when failed until stopped.
+func (pc *PullClient) Start() {
+	defer func() {
+		if err := recover(); err != nil {
+			pc.sources.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	for {
+		url := pc.urls[pc.index%len(pc.urls)]
+
+		if err := pc.pull(url); err != nil {
+			pc.sources.logger.Println("pull stream failed, url=", url, ", err=", err)
+			pc.index++
+		}
+
+		if pc.isStopped() {
+			break
+		}
+
+		time.Sleep(time.Duration(pullRetryIntervalSeconds) * time.Second)
+
+		if pc.isStopped() {
+			break
+		}
+	}
+
+	pc.sources.logger.Println("pull client stopped, urls=", pc.urls)
+}
+
+// Stop stop pulling, close the connection to remote.
+func (pc *PullClient) Stop() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.stopped = true
+
+	if nil != pc.rc {
+		pc.rc.tcpConn.Close()
+	}
+}
+
+func (pc *PullClient) isStopped() bool {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	return pc.stopped
+}
+
+func (pc *PullClient) setConn(rc *RtmpConn) bool {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if pc.stopped {
+		return false
+	}
+
+	pc.rc = rc
+
+	return true
+}
+
+func (pc *PullClient) pull(url string) (err error) {
+	defer func() {
+		if err := recover(); err != nil {
+			pc.sources.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	var u *rtmpURL
+	if u, err = parseRtmpURL(url); err != nil {
+		return
+	}
+
+	key := pc.key
+	if 0 == len(key) {
+		key = u.app + "/" + u.stream
+	}
+
+	vhost, app, stream := pc.sources.parseStreamKey(key)
+	if 0 == len(app) || 0 == len(stream) {
+		err = fmt.Errorf("pull stream key must be [vhost/]app/stream, key=%s", key)
+		return
+	}
+
+	var rc *RtmpConn
+	if rc, err = dialRtmp(pc.sources, u.host); err != nil {
+		return
+	}
+	pc.sources.logger.Println("pull client handshake success, remote=", u.host)
+
+	if !pc.setConn(rc) {
+		rc.tcpConn.Close()
+		return
+	}
+
+	pc.sources.conns.add(rc)
+
+	defer func() {
+		rc.clean()
+		pc.setConn(nil)
+		pc.sources.conns.remove(rc)
+	}()
+
+	if err = rc.connectApp(u); err != nil {
+		return
+	}
+
+	if err = rc.createStream(2); err != nil {
+		return
+	}
+
+	// the source key of local, used by clean to delete the source.
+	rc.connInfo.vhost = vhost
+	rc.connInfo.app = app
+	rc.streamName = stream
+
+	if nil != pc.source {
+		// edge, the source is created when played, and deleted when no one plays.
+		rc.source = pc.source
+		rc.role = pt.RtmpRoleEdge
+		rc.storeState()
+		pc.sources.logger.Println("edge pull from origin, stream=", key)
+	} else {
+		source := pc.sources.findSourceToPublish(key, url)
+		if nil == source {
+			err = fmt.Errorf("stream=%s can not pull, find source is nil", key)
+			return
+		}
+		pc.sources.logger.Println("pull client publish to local success, stream=", key)
+
+		rc.source = source
+		rc.role = pt.RtmpRolePuller
+		rc.storeState()
+
+		if nil != rc.source.hls {
+			if err = rc.source.hls.OnPublish(rc.connInfo.app, rc.streamName); err != nil {
+				pc.sources.logger.Println("hls onpublish failed, err=", err)
+				return
+			}
+		}
+	}
+
+	if err = rc.play(u.streamWithParam()); err != nil {
+		return
+	}
+
+	err = rc.recvCycle()
+
+	pc.sources.logger.Println("pull client cycle finished, url=", url, ", err=", err)
+
+	return
+}
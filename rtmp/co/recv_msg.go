@@ -3,7 +3,7 @@ package co
 import (
 	"encoding/binary"
 	"fmt"
-	"log"
+	"seal/kernel"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
@@ -13,7 +13,7 @@ import (
 func (rc *RtmpConn) recvMsg(header *pt.MessageHeader, payload *pt.MessagePayload) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -62,7 +62,7 @@ func (rc *RtmpConn) recvMsg(header *pt.MessageHeader, payload *pt.MessagePayload
 				// 0x04             where: message_type=4(protocol control user-control message)
 				// 0x00 0x06            where: event Ping(0x06)
 				// 0x00 0x00 0x0d 0x0f  where: event data 4bytes ping timestamp.
-				log.Println("rtmp session, accept cid=2, chunkFmt=1 , it's a valid chunk format, for librtmp.")
+				rc.logger.Println("rtmp session, accept cid=2, chunkFmt=1 , it's a valid chunk format, for librtmp.")
 			} else {
 				err = fmt.Errorf("chunk start error, must be RTMP_FMT_TYPE0")
 				break
@@ -179,9 +179,11 @@ func (rc *RtmpConn) recvMsg(header *pt.MessageHeader, payload *pt.MessagePayload
 
 		chunk.MsgCount++
 
-		// make cache of msg
+		// make cache of msg, alloc from pool, released when all the users done.
 		if uint32(len(payload.Payload)) < chunk.MsgHeader.PayloadLength {
-			payload.Payload = make([]uint8, chunk.MsgHeader.PayloadLength)
+			payload.Buf.Release()
+			payload.Buf = kernel.AllocBuffer(chunk.MsgHeader.PayloadLength)
+			payload.Payload = payload.Buf.B
 		}
 
 		// read chunk data
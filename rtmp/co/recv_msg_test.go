@@ -0,0 +1,88 @@
+package co
+
+import (
+	"net"
+	"seal/conf"
+	"seal/rtmp/pt"
+	"testing"
+)
+
+// benchPipe the rtmp connections of both sides of a loopback tcp connection.
+func benchPipe(b *testing.B, chunkSize uint32) (w *RtmpConn, r *RtmpConn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// the connections of a hub with the zero config.
+	sources := NewSourceHub(&conf.Config{})
+	b.Cleanup(sources.Close)
+
+	w = sources.NewRtmpConnection(<-accepted)
+	w.tcpConn.SetSendTimeout(10 * 1000 * 1000)
+	w.outChunkSize = chunkSize
+
+	r = sources.NewRtmpConnection(c)
+	r.tcpConn.SetRecvTimeout(10 * 1000 * 1000)
+	r.inChunkSize = chunkSize
+
+	b.Cleanup(func() {
+		w.tcpConn.Close()
+		r.tcpConn.Close()
+	})
+
+	return
+}
+
+// benchRecv recv b.N msgs of msgType with payload of size, released after recv like the publisher does.
+func benchRecv(b *testing.B, msgType uint8, size int, chunkSize uint32) {
+	w, r := benchPipe(b, chunkSize)
+
+	go func() {
+		m := &pt.Message{}
+		m.Payload.Payload = make([]byte, size)
+		m.Header.PayloadLength = uint32(size)
+		m.Header.MessageType = msgType
+		m.Header.PerferCsid = pt.RtmpCidVideo
+		for i := 0; i < b.N; i++ {
+			m.Header.Timestamp = uint64(i)
+			if nil != w.sendMsg(m) {
+				return
+			}
+		}
+	}()
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		msg := &pt.Message{}
+		if err := r.recvMsg(&msg.Header, &msg.Payload); err != nil {
+			b.Fatal(err)
+		}
+		msg.Release()
+	}
+}
+
+// BenchmarkRecvVideo the ingest of 50KB video frames, the payloads are from the buffer pool.
+func BenchmarkRecvVideo(b *testing.B) {
+	benchRecv(b, pt.RtmpMsgVideoMessage, 50000, 60000)
+}
+
+// BenchmarkRecvAudio the ingest of 1000 bytes audio msgs in 128 bytes chunks, the headers are from the recv buffer.
+func BenchmarkRecvAudio(b *testing.B) {
+	benchRecv(b, pt.RtmpMsgAudioMessage, 1000, 128)
+}
@@ -1,7 +1,6 @@
 package co
 
 import (
-	"log"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
@@ -10,7 +9,7 @@ import (
 func (rc *RtmpConn) responseAcknowlegementMsg() (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -33,7 +32,7 @@ func (rc *RtmpConn) estimateNeedSendAcknowlegement() (err error) {
 		((rc.tcpConn.GetRecvBytesSum() - rc.ack.hasAckedSize) > uint64(rc.ack.ackWindowSize)) {
 		// response a acknowlegement to peer.
 		if err = rc.responseAcknowlegementMsg(); err != nil {
-			log.Println("response acknowlegement msg failed to peer.")
+			rc.logger.Println("response acknowlegement msg failed to peer.")
 			return
 		}
 	}
@@ -0,0 +1,130 @@
+package co
+
+import (
+	"seal/rtmp/pt"
+	"sync"
+	"sync/atomic"
+)
+
+// msgRing the ring buffer of msgs published to source, shared by all consumers of source.
+// each consumer reads from its own cursor, so a slow consumer never blocks the publisher,
+// it just loses the msgs overwritten before read.
+type msgRing struct {
+	lock sync.RWMutex
+	msgs []*pt.Message
+	// next the sequence of the next msg to write, the msg of sequence n is at msgs[n%len(msgs)].
+	next uint64
+
+	// notify closed when the next msg written, only if someone is waiting on it,
+	// to avoid alloc a channel for each msg when all consumers are busy.
+	notify  chan struct{}
+	waiting uint32
+}
+
+func newMsgRing(size int) *msgRing {
+	return &msgRing{
+		msgs:   make([]*pt.Message, size),
+		notify: make(chan struct{}),
+	}
+}
+
+// push write msg to ring, overwrite the oldest msg if full, and wakeup the waiting consumers.
+func (r *msgRing) push(msg *pt.Message) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// the ring keeps the msg until overwritten.
+	msg.Retain()
+
+	index := r.next % uint64(len(r.msgs))
+	if old := r.msgs[index]; nil != old {
+		old.Release()
+	}
+
+	r.msgs[index] = msg
+	r.next++
+
+	if 1 == atomic.LoadUint32(&r.waiting) {
+		atomic.StoreUint32(&r.waiting, 0)
+		close(r.notify)
+		r.notify = make(chan struct{})
+	}
+}
+
+// tail the sequence of the next msg to write, a new consumer starts to read from it.
+func (r *msgRing) tail() uint64 {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.next
+}
+
+// wait return the channel which is closed when the next msg written.
+// get the channel before check whether there are msgs to read, or the wakeup may be lost.
+func (r *msgRing) wait() <-chan struct{} {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	atomic.StoreUint32(&r.waiting, 1)
+
+	return r.notify
+}
+
+// read the msgs from cursor to buf, return the count of msgs read, and the cursor to read next time.
+// the msgs read are retained, the reader must release them.
+// lost is the count of msgs overwritten before read, for the consumer is too slow.
+// duration is the timestamp delta in ms from the first msg read to the last msg written,
+// that is the duration of msgs queued for the consumer.
+func (r *msgRing) read(cursor uint64, buf []*pt.Message) (n int, next uint64, lost uint64, duration uint64) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	size := uint64(len(r.msgs))
+	if r.next-cursor > size {
+		lost = r.next - size - cursor
+		cursor = r.next - size
+	}
+
+	if cursor < r.next {
+		first := r.msgs[cursor%size].Header.Timestamp
+		last := r.msgs[(r.next-1)%size].Header.Timestamp
+		if last > first {
+			duration = last - first
+		}
+	}
+
+	for cursor < r.next && n < len(buf) {
+		buf[n] = r.msgs[cursor%size]
+		buf[n].Retain()
+		n++
+		cursor++
+	}
+
+	next = cursor
+
+	return
+}
+
+// lastTimestamp the timestamp of the last msg written, 0 if empty.
+func (r *msgRing) lastTimestamp() uint64 {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if 0 == r.next {
+		return 0
+	}
+
+	return r.msgs[(r.next-1)%uint64(len(r.msgs))].Header.Timestamp
+}
+
+// pending the count of msgs not read from cursor, include the msgs will be dropped.
+func (r *msgRing) pending(cursor uint64) uint64 {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if cursor >= r.next {
+		return 0
+	}
+
+	return r.next - cursor
+}
@@ -0,0 +1,293 @@
+package co
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"runtime"
+	"seal/conf"
+	"seal/rtmp/pt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestSource create the source live/test of a hub with config c, the logs are discarded.
+func newTestSource(t *testing.T, c *conf.Config) *SourceStream {
+	return newTestHub(t, c).newSourceStream("live/test", "test")
+}
+
+// newTestHub create a hub with config c, the logs are discarded.
+func newTestHub(t *testing.T, c *conf.Config) *SourceHub {
+	hub := NewSourceHub(c)
+	hub.SetLogger(log.New(ioutil.Discard, "", 0))
+	t.Cleanup(hub.Close)
+
+	return hub
+}
+
+// testVideo a h264 video msg of index at timestamp index*40, the index is in the payload.
+func testVideo(index int, key bool) *pt.Message {
+	p := []byte{0x27, 0x01, 0, 0, 0, 0, 0, 0, 0}
+	if key {
+		p[0] = 0x17
+	}
+	binary.BigEndian.PutUint32(p[5:], uint32(index))
+
+	msg := &pt.Message{}
+	msg.Payload.Payload = p
+	msg.Header.InitializeVideo(uint32(len(p)), uint32(index*40), 1)
+
+	return msg
+}
+
+// testIndex the index of msg created by testVideo.
+func testIndex(msg *pt.Message) int {
+	return int(binary.BigEndian.Uint32(msg.Payload.Payload[5:]))
+}
+
+func TestRingOverwrite(t *testing.T) {
+	r := newMsgRing(4)
+
+	for i := 0; i < 6; i++ {
+		r.push(testVideo(i, false))
+	}
+
+	if n := r.pending(0); 6 != n {
+		t.Fatalf("pending=%d, expect 6", n)
+	}
+
+	// the first 2 msgs are overwritten, read from the oldest left.
+	buf := make([]*pt.Message, 8)
+	n, next, lost, duration := r.read(0, buf)
+	if 4 != n || 6 != next || 2 != lost || 120 != duration {
+		t.Fatalf("read n=%d, next=%d, lost=%d, duration=%d, expect 4, 6, 2, 120", n, next, lost, duration)
+	}
+	for i := 0; i < n; i++ {
+		if testIndex(buf[i]) != i+2 {
+			t.Fatalf("msg %d is %d, expect %d", i, testIndex(buf[i]), i+2)
+		}
+	}
+
+	// read in batches, nothing lost.
+	n, next, lost, _ = r.read(4, buf[:1])
+	if 1 != n || 5 != next || 0 != lost || 4 != testIndex(buf[0]) {
+		t.Fatalf("read n=%d, next=%d, lost=%d, expect 1, 5, 0", n, next, lost)
+	}
+
+	if n, next, lost, _ = r.read(6, buf); 0 != n || 6 != next || 0 != lost {
+		t.Fatalf("read at tail n=%d, next=%d, lost=%d, expect 0, 6, 0", n, next, lost)
+	}
+
+	if ts := r.lastTimestamp(); 200 != ts {
+		t.Fatalf("last timestamp=%d, expect 200", ts)
+	}
+}
+
+func TestConsumerWakeup(t *testing.T) {
+	source := newTestSource(t, &conf.Config{})
+
+	c := NewConsumer("wakeup")
+	source.CreateConsumer(c)
+
+	if c.Wait(10 * time.Millisecond) {
+		t.Fatal("wait should timeout when no msg")
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		source.copyToAllConsumers(testVideo(0, true), nil)
+	}()
+
+	start := time.Now()
+	if !c.Wait(5 * time.Second) {
+		t.Fatal("wait should be woken up by the msg")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("wait is not woken up in time")
+	}
+
+	if msg := c.Dump(); nil == msg || 0 != testIndex(msg) {
+		t.Fatal("dump the msg woken up failed")
+	}
+	c.Release()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		c.Kick()
+	}()
+
+	if c.Wait(5 * time.Second) {
+		t.Fatal("wait should return false when kicked")
+	}
+}
+
+func TestConsumerLapped(t *testing.T) {
+	source := newTestSource(t, &conf.Config{})
+
+	c := NewConsumer("lapped")
+	source.CreateConsumer(c)
+
+	// the consumer does not read, the writer laps it by 100 msgs.
+	total := sourceRingSize + 100
+	for i := 0; i < total; i++ {
+		source.ring.push(testVideo(i, 0 == i%50))
+	}
+
+	var got []int
+	for msg := c.Dump(); nil != msg; msg = c.Dump() {
+		got = append(got, testIndex(msg))
+	}
+	c.Release()
+
+	// the msgs overwritten are lost, and drop until the next key frame.
+	if 0 == len(got) || 0 != got[0]%50 || got[0] < 100 {
+		t.Fatalf("the first msg=%v should be a key frame after the msgs lost", got[:1])
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] != got[i-1]+1 {
+			t.Fatalf("msg %d follows %d", got[i], got[i-1])
+		}
+	}
+
+	if dropped := atomic.LoadUint64(&c.dropped); int(dropped) != got[0] || total != int(dropped)+len(got) {
+		t.Fatalf("dropped=%d, dumped=%d, total=%d", dropped, len(got), total)
+	}
+}
+
+// TestDumpCacheOnce the consumers created when publishing get each msg once, from the gop cache or the ring.
+func TestDumpCacheOnce(t *testing.T) {
+	source := newTestSource(t, &conf.Config{})
+
+	const total = 500
+	consumers := make(chan *Consumer, 100)
+
+	go func() {
+		defer close(consumers)
+
+		for i := 0; i < cap(consumers); i++ {
+			c := NewConsumer(fmt.Sprintf("once/%d", i))
+			source.CreateConsumer(c)
+			source.DumpCache(c)
+			consumers <- c
+
+			runtime.Gosched()
+		}
+	}()
+
+	for i := 0; i < total; i++ {
+		source.copyToAllConsumers(testVideo(i, 0 == i%10), nil)
+		if 0 == i%5 {
+			runtime.Gosched()
+		}
+	}
+
+	for c := range consumers {
+		var got []int
+		for msg := c.Dump(); nil != msg; msg = c.Dump() {
+			got = append(got, testIndex(msg))
+		}
+		c.Release()
+
+		if 0 == len(got) {
+			continue
+		}
+
+		for i := 1; i < len(got); i++ {
+			if got[i] != got[i-1]+1 {
+				t.Fatalf("%s: msg %d follows %d", c.stream, got[i], got[i-1])
+			}
+		}
+
+		if last := got[len(got)-1]; total-1 != last {
+			t.Fatalf("%s: the last msg=%d, expect %d", c.stream, last, total-1)
+		}
+	}
+}
+
+// benchRingFanout push b.N msgs to the ring of source, which are dumped by n consumers concurrently.
+// the publisher pushes a batch and waits for all the consumers to dump it, so no msg is dropped.
+func benchRingFanout(b *testing.B, n int) {
+	c := &conf.Config{}
+	c.Rtmp.ConsumerQueueSize = 30
+
+	hub := NewSourceHub(c)
+	hub.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer hub.Close()
+
+	source := hub.newSourceStream("live/fanout", "bench")
+
+	// the msgs dumped by each consumer.
+	dumped := make([]uint64, n)
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		consumer := NewConsumer(fmt.Sprintf("fanout/%d", i))
+		source.CreateConsumer(consumer)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				if !consumer.Wait(10 * time.Millisecond) {
+					continue
+				}
+
+				for nil != consumer.Dump() {
+					atomic.AddUint64(&dumped[i], 1)
+				}
+				consumer.Release()
+			}
+		}(i)
+	}
+
+	// the msg is reused when overwritten in ring, all consumers have dumped it.
+	msgs := make([]pt.Message, sourceRingSize)
+	for i := range msgs {
+		msgs[i].Payload.Payload = []byte{0x27, 0x01, 0, 0, 0, 0, 0, 0}
+		msgs[i].Header.InitializeVideo(uint32(len(msgs[i].Payload.Payload)), 0, 1)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for pushed := 0; pushed < b.N; {
+		for j := 0; j < consumerBatchSize && pushed < b.N; j++ {
+			msg := &msgs[pushed%len(msgs)]
+			msg.Header.Timestamp = uint64(pushed * 40)
+
+			source.ring.push(msg)
+			pushed++
+		}
+
+		for i := range dumped {
+			for atomic.LoadUint64(&dumped[i]) < uint64(pushed) {
+				runtime.Gosched()
+			}
+		}
+	}
+
+	b.StopTimer()
+
+	close(done)
+	wg.Wait()
+}
+
+// BenchmarkRingFanout the msgs published to the ring of source, and dumped by 100 or 500 consumers.
+func BenchmarkRingFanout(b *testing.B) {
+	for _, n := range []int{100, 500} {
+		b.Run(fmt.Sprintf("consumers=%d", n), func(b *testing.B) {
+			benchRingFanout(b, n)
+		})
+	}
+}
@@ -2,16 +2,53 @@ package co
 
 import (
 	"encoding/binary"
-	"log"
+	"net"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
 )
 
+// chunkBuffers the chunks of msgs to send in one writev,
+// the payloads are not copied, only the chunk headers are written to headers.
+type chunkBuffers struct {
+	bufs    net.Buffers
+	headers []byte
+	msgs    int
+	size    int
+}
+
+func (b *chunkBuffers) reset() {
+	// release the payloads, reuse the memory of slices.
+	for i := range b.bufs {
+		b.bufs[i] = nil
+	}
+
+	b.bufs = b.bufs[:0]
+	b.headers = b.headers[:0]
+	b.msgs = 0
+	b.size = 0
+}
+
+// appendHeader append the chunk header to headers, and the buffers.
+func (b *chunkBuffers) appendHeader(h []byte) {
+	offset := len(b.headers)
+	b.headers = append(b.headers, h...)
+
+	// the headers may be reallocated, but the buffers appended before still refer to the old,
+	// which is not changed any more, so it's ok.
+	b.bufs = append(b.bufs, b.headers[offset:len(b.headers):len(b.headers)])
+	b.size += len(h)
+}
+
+func (b *chunkBuffers) appendPayload(p []byte) {
+	b.bufs = append(b.bufs, p)
+	b.size += len(p)
+}
+
 func (rc *RtmpConn) sendMsg(msg *pt.Message) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -19,119 +56,168 @@ func (rc *RtmpConn) sendMsg(msg *pt.Message) (err error) {
 		return
 	}
 
+	// alloc once for the chunks of msg.
+	n := int(msg.Header.PayloadLength/rc.outChunkSize) + 1
+	b := chunkBuffers{
+		bufs:    make(net.Buffers, 0, 2*n),
+		headers: make([]byte, 0, pt.RtmpMaxFmt0HeaderSize+5*n),
+	}
+	rc.appendMsg(&b, msg)
+
+	return rc.sendChunks(&b)
+}
+
+// bufferMsg copy the chunks of msg to the send buffer of socket.
+func (rc *RtmpConn) bufferMsg(msg *pt.Message) (err error) {
+	var b chunkBuffers
+	rc.appendMsg(&b, msg)
+
+	for _, v := range b.bufs {
+		if err = rc.tcpConn.BufferBytes(v); err != nil {
+			rc.logger.Println("buffer msg failed, err=", err)
+			return
+		}
+	}
+
+	return
+}
+
+// sendChunks send the chunks of msgs in one writev, and reset the buffers.
+func (rc *RtmpConn) sendChunks(b *chunkBuffers) (err error) {
+	if 0 == len(b.bufs) {
+		return
+	}
+
+	// the WriteTo consumes the buffers, so send a copy of the slice to reuse it.
+	bufs := b.bufs
+	if err = rc.tcpConn.SendBuffers(&bufs); err != nil {
+		rc.logger.Println("send msgs failed, msgs=", b.msgs, ", err=", err)
+	}
+
+	b.reset()
+
+	return
+}
+
+// appendMsg append the chunks of msg to buffers,
+// use the chunks shared by the msg if they are chunked for the out chunk size and csid.
+func (rc *RtmpConn) appendMsg(b *chunkBuffers, msg *pt.Message) {
 	// ensure the basic header is 1bytes. make simple.
-	if msg.Header.PerferCsid < 2 {
-		msg.Header.PerferCsid = pt.RtmpCidProtocolControl
+	csid := msg.Header.PerferCsid
+	if csid < 2 {
+		csid = pt.RtmpCidProtocolControl
+	}
+
+	b.msgs++
+
+	// the msg without payload is ignored.
+	if 0 == msg.Header.PayloadLength {
+		return
+	}
+
+	var header [pt.RtmpMaxFmt0HeaderSize]uint8
+	var headerOffset uint32
+
+	// write new chunk stream header, fmt is 0
+	header[headerOffset] = 0x00 | uint8(csid&0x3f)
+	headerOffset++
+
+	// chunk message header, 11 bytes
+	// timestamp, 3bytes, big-endian
+	timestamp := msg.Header.Timestamp
+	if timestamp < pt.RtmpExtendTimeStamp {
+		header[headerOffset] = uint8((timestamp & 0x00ff0000) >> 16)
+		headerOffset++
+		header[headerOffset] = uint8((timestamp & 0x0000ff00) >> 8)
+		headerOffset++
+		header[headerOffset] = uint8(timestamp & 0x000000ff)
+		headerOffset++
+	} else {
+		header[headerOffset] = 0xff
+		headerOffset++
+		header[headerOffset] = 0xff
+		headerOffset++
+		header[headerOffset] = 0xff
+		headerOffset++
+	}
+
+	// message_length, 3bytes, big-endian
+	payloadLengh := msg.Header.PayloadLength
+	header[headerOffset] = uint8((payloadLengh & 0x00ff0000) >> 16)
+	headerOffset++
+	header[headerOffset] = uint8((payloadLengh & 0x0000ff00) >> 8)
+	headerOffset++
+	header[headerOffset] = uint8((payloadLengh & 0x000000ff))
+	headerOffset++
+
+	// message_type, 1bytes
+	header[headerOffset] = msg.Header.MessageType
+	headerOffset++
+
+	// stream id, 4 bytes, little-endian
+	binary.LittleEndian.PutUint32(header[headerOffset:headerOffset+4], msg.Header.StreamID)
+	headerOffset += 4
+
+	// chunk extended timestamp header, 0 or 4 bytes, big-endian
+	if timestamp >= pt.RtmpExtendTimeStamp {
+		binary.BigEndian.PutUint32(header[headerOffset:headerOffset+4], uint32(timestamp))
+		headerOffset += 4
+	}
+
+	b.appendHeader(header[:headerOffset])
+
+	// the chunks shared by all players, computed once when published.
+	if c := msg.Chunked; nil != c && c.ChunkSize == rc.outChunkSize && c.Csid == csid && timestamp < pt.RtmpExtendTimeStamp {
+		for _, v := range c.Bufs {
+			b.bufs = append(b.bufs, v)
+			b.size += len(v)
+		}
+
+		return
 	}
 
 	// current position of payload send.
 	var payloadOffset uint32
 
-	// always write the header event payload is Empty.
 	for {
+		//payload
+		payloadSize := msg.Header.PayloadLength - payloadOffset
+		if payloadSize > rc.outChunkSize {
+			payloadSize = rc.outChunkSize
+		}
+
+		b.appendPayload(msg.Payload.Payload[payloadOffset : payloadOffset+payloadSize])
+		payloadOffset += payloadSize
+
 		if payloadOffset >= msg.Header.PayloadLength {
 			break
 		}
 
-		var headerOffset uint32
-		var header [pt.RtmpMaxFmt0HeaderSize]uint8
-
-		if 0 == payloadOffset {
-			// write new chunk stream header, fmt is 0
-			header[headerOffset] = 0x00 | uint8(msg.Header.PerferCsid&0x3f)
-			headerOffset++
-
-			// chunk message header, 11 bytes
-			// timestamp, 3bytes, big-endian
-			timestamp := msg.Header.Timestamp
-			if timestamp < pt.RtmpExtendTimeStamp {
-				header[headerOffset] = uint8((timestamp & 0x00ff0000) >> 16)
-				headerOffset++
-				header[headerOffset] = uint8((timestamp & 0x0000ff00) >> 8)
-				headerOffset++
-				header[headerOffset] = uint8(timestamp & 0x000000ff)
-				headerOffset++
-			} else {
-				header[headerOffset] = 0xff
-				headerOffset++
-				header[headerOffset] = 0xff
-				headerOffset++
-				header[headerOffset] = 0xff
-				headerOffset++
-			}
-
-			// message_length, 3bytes, big-endian
-			payloadLengh := msg.Header.PayloadLength
-			header[headerOffset] = uint8((payloadLengh & 0x00ff0000) >> 16)
-			headerOffset++
-			header[headerOffset] = uint8((payloadLengh & 0x0000ff00) >> 8)
-			headerOffset++
-			header[headerOffset] = uint8((payloadLengh & 0x000000ff))
-			headerOffset++
-
-			// message_type, 1bytes
-			header[headerOffset] = msg.Header.MessageType
-			headerOffset++
-
-			// stream id, 4 bytes, little-endian
-			binary.LittleEndian.PutUint32(header[headerOffset:headerOffset+4], msg.Header.StreamID)
+		// write no message header chunk stream, fmt is 3
+		// @remark, if perfer_cid > 0x3F, that is, use 2B/3B chunk header,
+		// rollback to 1B chunk header.
+		headerOffset = 0
+
+		// fmt is 3
+		header[headerOffset] = 0xc0 | uint8(csid&0x3f)
+		headerOffset++
+
+		// chunk extended timestamp header, 0 or 4 bytes, big-endian
+		// 6.1.3. Extended Timestamp
+		// This field is transmitted only when the normal time stamp in the
+		// chunk message header is set to 0x00ffffff. If normal time stamp is
+		// set to any value less than 0x00ffffff, this field MUST NOT be
+		// present. This field MUST NOT be present if the timestamp field is not
+		// present. Type 3 chunks MUST NOT have this field.
+		// adobe changed for Type3 chunk:
+		//        FMLE always sendout the extended-timestamp,
+		//        must send the extended-timestamp to FMS,
+		//        must send the extended-timestamp to flash-player.
+		if timestamp >= pt.RtmpExtendTimeStamp {
+			binary.BigEndian.PutUint32(header[headerOffset:headerOffset+4], uint32(timestamp))
 			headerOffset += 4
-
-			// chunk extended timestamp header, 0 or 4 bytes, big-endian
-			if timestamp >= pt.RtmpExtendTimeStamp {
-				binary.BigEndian.PutUint32(header[headerOffset:headerOffset+4], uint32(timestamp))
-				headerOffset += 4
-			}
-
-		} else {
-			// write no message header chunk stream, fmt is 3
-			// @remark, if perfer_cid > 0x3F, that is, use 2B/3B chunk header,
-			// rollback to 1B chunk header.
-
-			// fmt is 3
-			header[headerOffset] = 0xc0 | uint8(msg.Header.PerferCsid&0x3f)
-			headerOffset++
-
-			// chunk extended timestamp header, 0 or 4 bytes, big-endian
-			// 6.1.3. Extended Timestamp
-			// This field is transmitted only when the normal time stamp in the
-			// chunk message header is set to 0x00ffffff. If normal time stamp is
-			// set to any value less than 0x00ffffff, this field MUST NOT be
-			// present. This field MUST NOT be present if the timestamp field is not
-			// present. Type 3 chunks MUST NOT have this field.
-			// adobe changed for Type3 chunk:
-			//        FMLE always sendout the extended-timestamp,
-			//        must send the extended-timestamp to FMS,
-			//        must send the extended-timestamp to flash-player.
-			timestamp := msg.Header.Timestamp
-			if timestamp >= pt.RtmpExtendTimeStamp {
-				binary.BigEndian.PutUint32(header[headerOffset:headerOffset+4], uint32(timestamp))
-				headerOffset += 4
-			}
 		}
 
-		// not use writev method, we use net.Buffers to mock writev in c/c++, socket disconnected qickly
-		if true {
-			// send header
-			if err = rc.tcpConn.SendBytes(header[:headerOffset]); err != nil {
-				log.Println("send msg header failed.")
-				return
-			}
-
-			//payload
-			payloadSize := msg.Header.PayloadLength - payloadOffset
-			if payloadSize > rc.outChunkSize {
-				payloadSize = rc.outChunkSize
-			}
-
-			if err = rc.tcpConn.SendBytes(msg.Payload.Payload[payloadOffset : payloadOffset+payloadSize]); err != nil {
-				log.Println("send msg payload failed.")
-				return
-			}
-
-			payloadOffset += payloadSize
-		}
+		b.appendHeader(header[:headerOffset])
 	}
-
-	return
 }
@@ -1,17 +1,17 @@
 package co
 
 import (
-	"log"
 	"seal/rtmp/pt"
 
 	"github.com/calabashdad/utiltools"
 )
 
-// sendPacket the function has call the message
+// sendPacket the function has call the message,
+// the msg is buffered, sent with the next msgs, or flushed before waiting for peer.
 func (rc *RtmpConn) sendPacket(pkt pt.Packet, streamID uint32) (err error) {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Println(utiltools.PanicTrace())
+			rc.logger.Println(utiltools.PanicTrace())
 		}
 	}()
 
@@ -30,7 +30,7 @@ func (rc *RtmpConn) sendPacket(pkt pt.Packet, streamID uint32) (err error) {
 	msg.Header.PerferCsid = pkt.GetPreferCsID()
 	msg.Header.StreamID = streamID
 
-	if err = rc.sendMsg(&msg); err != nil {
+	if err = rc.bufferMsg(&msg); err != nil {
 		return
 	}
 
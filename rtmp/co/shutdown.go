@@ -0,0 +1,76 @@
+package co
+
+import (
+	"seal/rtmp/pt"
+	"sync/atomic"
+)
+
+// Stop ask the players and publishers to quit gracefully, e.g. when server shutdown,
+// the publishers delete their sources and close the hls segments when quit,
+// call Close after they quit, or timeout.
+func (s *SourceHub) Stop() {
+	s.lock.RLock()
+	for _, v := range s.hub {
+		v.kickPlayers()
+	}
+	s.lock.RUnlock()
+
+	s.conns.Stop()
+}
+
+// Stop ask the rtmp clients to quit gracefully, the clients are notified by status before closed.
+// the connections to remote, e.g. pull and forward, are stopped when their sources deleted.
+func (h *ConnHub) Stop() {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for _, rc := range h.hub {
+		switch rc.loadState().role {
+		case pt.RtmpRoleUnknown, pt.RtmpRolePlayer, pt.RtmpRoleFMLEPublisher, pt.RtmpRoleFlashPublisher:
+			rc.stop()
+		}
+	}
+}
+
+// stop interrupt the recv and the playing of connection, it will quit and clean.
+func (rc *RtmpConn) stop() {
+	atomic.StoreInt32(&rc.stopping, 1)
+
+	if c := rc.loadState().consumer; nil != c {
+		c.Kick()
+	}
+
+	rc.tcpConn.Interrupt()
+}
+
+// notifyStop send the status to the player or publisher, tell it the stream is stopped by server.
+func (rc *RtmpConn) notifyStop() (err error) {
+	var code string
+
+	switch rc.role {
+	case pt.RtmpRolePlayer:
+		code = pt.StatusCodeStreamStop
+	case pt.RtmpRoleFMLEPublisher, pt.RtmpRoleFlashPublisher:
+		code = pt.StatusCodeUnpublishSuccess
+	default:
+		return
+	}
+
+	var pkt pt.OnStatusCallPacket
+	pkt.CommandName = pt.RtmpAmf0CommandOnStatus
+	if err = pkt.Marshal(&pt.StatusInfo{
+		Level:       pt.StatusLevelStatus,
+		Code:        code,
+		Description: "Stream is stopped by server.",
+	}); err != nil {
+		return
+	}
+
+	if err = rc.sendPacket(&pkt, uint32(rc.defaultStreamID)); err != nil {
+		return
+	}
+
+	rc.logger.Println("notify client stream stopped, code=", code, ", stream=", rc.getSourceKey())
+
+	return
+}
@@ -2,25 +2,134 @@ package co
 
 import (
 	"log"
+	"seal/auth"
 	"seal/conf"
 	"seal/hls"
+	"seal/hooks"
+	"seal/metrics"
 	"seal/rtmp/pt"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// stream data source hub
-type sourceHub struct {
+// SourceHub stream data source hub, the sources and connections of a server.
+type SourceHub struct {
 	//key: app/streamName, e.g. rtmp://127.0.0.1/live/test, the key is [live/app]
+	//or vhost/app/streamName of the vhost configured, see StreamKey.
 	hub  map[string]*SourceStream
 	lock sync.RWMutex
+
+	// config the *conf.Config of server, read by the sources and connections of hub,
+	// replaced when reload.
+	config atomic.Value
+	// conns the rtmp connections of server.
+	conns *ConnHub
+
+	// the auth, hooks and metrics of server, created with the hub.
+	auth    *auth.Auth
+	hooks   *hooks.Hooks
+	metrics *metrics.Server
+	// logger the logger of the sources and connections of hub.
+	logger *log.Logger
+}
+
+// NewSourceHub create the source hub of a server with config c,
+// with the auth, hooks and metrics of its own, log to the std log.
+func NewSourceHub(c *conf.Config) *SourceHub {
+	s := &SourceHub{
+		hub: make(map[string]*SourceStream),
+		conns: &ConnHub{
+			hub:  make(map[uint64]*RtmpConn),
+			flvs: make(map[uint64]*FlvSession),
+		},
+		auth:    auth.New(c),
+		hooks:   hooks.New(c),
+		metrics: metrics.NewServer(),
+		logger:  log.Default(),
+	}
+
+	s.config.Store(c)
+	s.registerMetrics()
+
+	return s
+}
+
+// SetLogger set the logger of the hub, and its auth and hooks,
+// must be called before the sources and connections created.
+func (s *SourceHub) SetLogger(l *log.Logger) {
+	s.logger = l
+	s.auth.SetLogger(l)
+	s.hooks.SetLogger(l)
+}
+
+// Config the config of hub.
+func (s *SourceHub) Config() *conf.Config {
+	return s.config.Load().(*conf.Config)
+}
+
+// SetConfig replace the config of hub, and reload the auth and hooks, e.g. when reload,
+// which is applied to the sources and connections created after.
+// nothing is changed if the auth of c is invalid.
+func (s *SourceHub) SetConfig(c *conf.Config) (err error) {
+	if err = s.auth.Reload(c); err != nil {
+		return
+	}
+
+	s.hooks.Reload(c)
+	s.config.Store(c)
+
+	return
+}
+
+// Conns the rtmp connections of hub.
+func (s *SourceHub) Conns() *ConnHub {
+	return s.conns
+}
+
+// Auth the auth of hub, which authenticates the clients.
+func (s *SourceHub) Auth() *auth.Auth {
+	return s.auth
+}
+
+// Hooks the hooks of hub, which post the events to the callbacks.
+func (s *SourceHub) Hooks() *hooks.Hooks {
+	return s.hooks
 }
 
-var GlobalSources = &sourceHub{
-	hub: make(map[string]*SourceStream),
+// Metrics the metrics of hub.
+func (s *SourceHub) Metrics() *metrics.Server {
+	return s.metrics
+}
+
+// Logger the logger of hub.
+func (s *SourceHub) Logger() *log.Logger {
+	return s.logger
+}
+
+// Close delete all the sources and kick their players, disconnect all the rtmp connections,
+// and stop the hooks after the events queued are notified.
+func (s *SourceHub) Close() {
+	s.lock.Lock()
+	for k, v := range s.hub {
+		s.remove(k)
+		v.kickPlayers()
+	}
+	s.lock.Unlock()
+
+	s.conns.KickAll()
+
+	s.hooks.Close()
 }
 
 // SourceStream rtmp stream data source
 type SourceStream struct {
+	// the bytes of msgs published to source, and sent to consumers.
+	// first fields for 64 bits atomic align.
+	recvBytes uint64
+	sendBytes uint64
+
 	// the sample rate of audio in metadata
 	SampleRate float64
 	// the video frame rate in metadata
@@ -31,12 +140,23 @@ type SourceStream struct {
 	Atc bool
 	// time jitter algrithem
 	TimeJitter uint32
-	// cached meta data
+	// the max duration of msgs queued of the consumers, of the vhost and app of source.
+	queueSizeMills uint32
+	// cached meta data, the cached msgs are protected by cacheLock,
+	// replaced by the publisher and dumped to the consumers.
 	CacheMetaData *pt.Message
 	// cached video sequence header
 	CacheVideoSequenceHeader *pt.Message
 	// cached aideo sequence header
 	CacheAudioSequenceHeader *pt.Message
+	// lock for the cached msgs, and the msgs written to ring and gop cache,
+	// so the consumer takes its cursor and dumps the caches at the same point of stream.
+	cacheLock sync.Mutex
+	// closed when the first sequence header cached, the players of edge wait for it.
+	sequenceHeaderReady chan struct{}
+
+	// the msgs published, shared by all consumers.
+	ring *msgRing
 
 	// consumers
 	consumers map[*Consumer]interface{}
@@ -48,11 +168,82 @@ type SourceStream struct {
 
 	// hls stream
 	hls *hls.SourceStream
+
+	// forwarders, forward the stream to remote rtmp servers.
+	forwarders []*forwarder
+
+	// edge pull client, pull the stream from origin on demand, nil if not edge.
+	edge *PullClient
+
+	// hub the hub which the source belongs to.
+	hub *SourceHub
+	// vhost the vhost of source.
+	vhost string
+
+	// the address of publisher, or the url pulled from, for stats.
+	publisher string
+	// the time source created.
+	startTime time.Time
+	// the bitrate of recv and send.
+	kbpsIn  kbps
+	kbpsOut kbps
+}
+
+// parseStreamKey the vhost, app and stream of the source key, see StreamKey.
+func (s *SourceHub) parseStreamKey(key string) (vhost string, app string, stream string) {
+	vhost = conf.DefaultVhost
+
+	i := strings.LastIndex(key, "/")
+	if i < 0 {
+		return vhost, key, ""
+	}
+	app, stream = key[:i], key[i+1:]
+
+	if j := strings.Index(app, "/"); j > 0 {
+		if v := s.Config().Vhost(app[:j]); conf.DefaultVhost != v {
+			vhost, app = v, app[j+1:]
+		}
+	}
+
+	return
+}
+
+func (s *SourceHub) newSourceStream(key string, publisher string) *SourceStream {
+	vhost, app, _ := s.parseStreamKey(key)
+
+	c := s.Config().StreamConfig(vhost, app)
+
+	source := &SourceStream{
+		TimeJitter:     c.Rtmp.TimeJitter,
+		queueSizeMills: c.Rtmp.ConsumerQueueSize * 1000,
+		GopCache:       newGopCache(c, app, s.metrics, s.logger),
+		ring:           newMsgRing(sourceRingSize),
+		consumers:      make(map[*Consumer]interface{}),
+		hub:            s,
+		vhost:          vhost,
+		publisher:      publisher,
+		startTime:      time.Now(),
+
+		sequenceHeaderReady: make(chan struct{}),
+	}
+
+	if "true" == c.Hls.Enable {
+		source.hls = hls.NewSourceStream(c, vhost, &hls.Env{
+			Logger:  s.logger,
+			Metrics: s.metrics,
+			Hooks:   s.hooks,
+		})
+	} else {
+		// make sure is nil when hls is closed
+		source.hls = nil
+	}
+
+	return source
 }
 
 func (s *SourceStream) CreateConsumer(c *Consumer) {
 	if nil == c {
-		log.Println("when registe consumer, nil == consumer")
+		s.hub.logger.Println("when registe consumer, nil == consumer")
 		return
 	}
 
@@ -60,13 +251,17 @@ func (s *SourceStream) CreateConsumer(c *Consumer) {
 	defer s.consumerLock.Unlock()
 
 	s.consumers[c] = struct{}{}
-	log.Println("a consumer created.consumer=", c)
+	c.source = s
+	c.queueSizeMills = s.queueSizeMills
+	c.ring = s.ring
+	atomic.StoreUint64(&c.cursor, s.ring.tail())
+	s.hub.logger.Println("a consumer created, key=", c.stream)
 
 }
 
 func (s *SourceStream) DestroyConsumer(c *Consumer) {
 	if nil == c {
-		log.Println("when destroy consumer, nil == consummer")
+		s.hub.logger.Println("when destroy consumer, nil == consummer")
 		return
 	}
 
@@ -74,28 +269,157 @@ func (s *SourceStream) DestroyConsumer(c *Consumer) {
 	defer s.consumerLock.Unlock()
 
 	delete(s.consumers, c)
-	log.Println("a consumer destroyed.consumer=", c)
+	s.hub.logger.Println("a consumer destroyed, key=", c.stream, ", dropped=", atomic.LoadUint64(&c.dropped))
+
+	if nil != s.edge && 0 == len(s.consumers) {
+		s.stopEdgeWhenIdle()
+	}
+}
+
+// stopEdgeWhenIdle stop pulling from origin and delete the edge source,
+// if still no one plays after the idle timeout.
+func (s *SourceStream) stopEdgeWhenIdle() {
+	timeout := s.hub.Config().Edge.IdleTimeout
+	if 0 == timeout {
+		timeout = edgeIdleTimeoutSeconds
+	}
+
+	time.AfterFunc(time.Duration(timeout)*time.Second, func() {
+		s.hub.deleteIdleEdgeSource(s)
+	})
 }
 
-func (s *SourceStream) copyToAllConsumers(msg *pt.Message) {
+// waitSequenceHeader wait for the sequence header pulled from origin, return false when timeout.
+func (s *SourceStream) waitSequenceHeader(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-s.sequenceHeaderReady:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// copyToAllConsumers write msg to the ring read by the consumers, and cache it, e.g. the sequence header,
+// or to the gop cache if cache is nil.
+// the ring and caches are written under cacheLock, and a new consumer takes its cursor and dumps the caches
+// under it too, so gets each msg once, from the caches or from the ring.
+func (s *SourceStream) copyToAllConsumers(msg *pt.Message, cache **pt.Message) {
 
 	if nil == msg {
 		return
 	}
 
-	s.consumerLock.Lock()
-	defer s.consumerLock.Unlock()
+	atomic.AddUint64(&s.recvBytes, uint64(msg.Header.PayloadLength))
+
+	// chunk the payload once, shared by all players with the out chunk size of config.
+	if chunkSize := s.hub.Config().Rtmp.ChunkSize; chunkSize > 0 && nil == msg.Chunked {
+		csid := msg.Header.PerferCsid
+		if csid < 2 {
+			csid = pt.RtmpCidProtocolControl
+		}
+
+		msg.Chunked = msg.Chunk(chunkSize, csid)
+	}
+
+	// retain the new cached msg and release the old after replaced,
+	// for the consumers retain the cached msgs under lock when dump.
+	var old *pt.Message
+	if nil != cache {
+		msg.Retain()
+	}
+
+	s.cacheLock.Lock()
+
+	// the consumers read from the ring by themselves, never block the publisher.
+	s.ring.push(msg)
+
+	if nil != cache {
+		old = *cache
+		*cache = msg
+
+		// wakeup the players waiting for the sequence header, only once.
+		select {
+		case <-s.sequenceHeaderReady:
+		default:
+			if cache == &s.CacheVideoSequenceHeader || cache == &s.CacheAudioSequenceHeader {
+				close(s.sequenceHeaderReady)
+			}
+		}
+	} else {
+		s.GopCache.cache(msg)
+	}
+
+	s.cacheLock.Unlock()
+
+	if nil != old {
+		old.Release()
+	}
+}
+
+// setCacheTimestamp set the timestamp of the cached meta data and video sequence header, for atc.
+func (s *SourceStream) setCacheTimestamp(timestamp uint64) {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	if nil != s.CacheVideoSequenceHeader {
+		s.CacheVideoSequenceHeader.Header.Timestamp = timestamp
+	}
+
+	if nil != s.CacheMetaData {
+		s.CacheMetaData.Header.Timestamp = timestamp
+	}
+}
 
-	for k, v := range s.consumers {
-		_ = v
-		k.Enquene(msg, s.Atc, s.SampleRate, s.FrameRate, s.TimeJitter)
+// DumpCache dump the cached meta data, sequence headers and gop to the consumer,
+// make the consumer start fast, and read the ring from the msgs after them.
+// notice that the msgs written after the consumer created and not cached are skipped, e.g. gop cache disabled.
+func (s *SourceStream) DumpCache(c *Consumer) {
+	// the consumer retains the cached msgs under lock, which may be replaced and released by publisher.
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	// the msgs before the cursor are in the caches.
+	atomic.StoreUint64(&c.cursor, s.ring.tail())
+
+	if s.Atc && !s.GopCache.Empty() {
+		if nil != s.CacheMetaData {
+			s.CacheMetaData.Header.Timestamp = s.GopCache.StartTime()
+		}
+		if nil != s.CacheVideoSequenceHeader {
+			s.CacheVideoSequenceHeader.Header.Timestamp = s.GopCache.StartTime()
+		}
+		if nil != s.CacheAudioSequenceHeader {
+			s.CacheAudioSequenceHeader.Header.Timestamp = s.GopCache.StartTime()
+		}
 	}
+
+	//cache meta data
+	if nil != s.CacheMetaData {
+		c.Enquene(s.CacheMetaData)
+	}
+
+	//cache video data
+	if nil != s.CacheVideoSequenceHeader {
+		c.Enquene(s.CacheVideoSequenceHeader)
+	}
+
+	//cache audio data
+	if nil != s.CacheAudioSequenceHeader {
+		c.Enquene(s.CacheAudioSequenceHeader)
+	}
+
+	//Dump gop cache to client.
+	s.GopCache.Dump(c)
 }
 
-func (s *sourceHub) findSourceToPublish(k string) *SourceStream {
+// findSourceToPublish create the source to publish, publisher is the address of the publisher.
+func (s *SourceHub) findSourceToPublish(k string, publisher string) *SourceStream {
 
 	if 0 == len(k) {
-		log.Println("find source to publish, nil == k")
+		s.logger.Println("find source to publish, nil == k")
 		return nil
 	}
 
@@ -103,48 +427,141 @@ func (s *sourceHub) findSourceToPublish(k string) *SourceStream {
 	defer s.lock.Unlock()
 
 	if res := s.hub[k]; nil != res {
-		log.Println("stream ", k, " can not publish, because has already publishing....")
+		s.logger.Println("stream ", k, " can not publish, because has already publishing....")
 		return nil
 	}
 
 	//can publish. new a source
-	s.hub[k] = &SourceStream{
-		TimeJitter: conf.GlobalConfInfo.Rtmp.TimeJitter,
-		GopCache:   &GopCache{},
-		consumers:  make(map[*Consumer]interface{}),
+	s.hub[k] = s.newSourceStream(k, publisher)
+
+	if vhost, app, stream := s.parseStreamKey(k); 0 != len(app) && 0 != len(stream) {
+		for _, v := range s.forwardURLs(s.Config().StreamConfig(vhost, app), app, stream) {
+			f := newForwarder(v, s.hub[k])
+			s.hub[k].forwarders = append(s.hub[k].forwarders, f)
+			f.start()
+		}
 	}
 
-	if "true" == conf.GlobalConfInfo.Hls.Enable {
-		s.hub[k].hls = hls.NewSourceStream()
-	} else {
-		// make sure is nil when hls is closed
-		s.hub[k].hls = nil
+	return s.hub[k]
+}
+
+func (s *SourceHub) FindSourceToPlay(k string) *SourceStream {
+	c := s.Config()
+
+	// the edge source is added to hub under lock, so the other players wait for it,
+	// and started after unlock, for the hls publish accesses the disk.
+	var created bool
+
+	s.lock.Lock()
+	res := s.hub[k]
+	if nil == res && "true" == c.Edge.Enable && len(c.Edge.Origins) > 0 {
+		res = s.createEdgeSource(k)
+		created = nil != res
 	}
+	s.lock.Unlock()
 
-	return s.hub[k]
+	if nil == res {
+		s.logger.Println("stream ", k, " can not play, because has not published.")
+		return nil
+	}
+
+	if created {
+		s.startEdgeSource(res)
+	}
+
+	if nil != res.edge && !res.waitSequenceHeader(time.Duration(c.Rtmp.TimeOut)*time.Second) {
+		s.logger.Println("stream ", k, " can not play, because pull from origin timeout.")
+		res.stopEdgeWhenIdle()
+		return nil
+	}
+
+	return res
+}
+
+// createEdgeSource create a source to pull the stream from origins, and add it to hub,
+// it's started by startEdgeSource. must be called with lock held.
+func (s *SourceHub) createEdgeSource(k string) *SourceStream {
+	_, app, stream := s.parseStreamKey(k)
+	if 0 == len(app) || 0 == len(stream) {
+		s.logger.Println("stream ", k, " can not pull from origin, key is invalid.")
+		return nil
+	}
+
+	origins := s.Config().Edge.Origins
+	source := s.newSourceStream(k, "edge:"+strings.Join(origins, ","))
+	source.edge = newEdgePullClient(origins, k, source)
+
+	s.hub[k] = source
+
+	return source
+}
+
+// startEdgeSource publish the hls of edge source, and start pulling from origins.
+func (s *SourceHub) startEdgeSource(source *SourceStream) {
+	k := source.edge.key
+	_, app, stream := s.parseStreamKey(k)
+
+	if nil != source.hls {
+		if err := source.hls.OnPublish(app, stream); err != nil {
+			s.logger.Println("edge hls onpublish failed, err=", err)
+		}
+	}
+
+	go source.edge.Start()
+	s.logger.Println("edge start pull from origin, stream=", k)
 }
 
-func (s *sourceHub) FindSourceToPlay(k string) *SourceStream {
+// deleteIdleEdgeSource delete the edge source if no one plays it.
+func (s *SourceHub) deleteIdleEdgeSource(source *SourceStream) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	if res := s.hub[k]; nil != res {
-		return res
+	key := source.edge.key
+	if source != s.hub[key] {
+		return
 	}
 
-	log.Println("stream ", k, " can not play, because has not published.")
+	source.consumerLock.RLock()
+	n := len(source.consumers)
+	source.consumerLock.RUnlock()
+
+	if n > 0 {
+		return
+	}
 
-	return nil
+	s.remove(key)
+	s.logger.Println("edge stop pull from origin because of idle, stream=", key)
 }
 
-func (s *sourceHub) deleteSource(key string) {
+// deleteSource delete the source of key, only if it's still the source,
+// for the source may be dropped and published again.
+func (s *SourceHub) deleteSource(key string, source *SourceStream) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	if source != s.hub[key] {
+		return
+	}
+
+	s.remove(key)
+}
+
+// remove stop the source and remove it from hub, must be called with lock held.
+func (s *SourceHub) remove(key string) {
 	stream := s.hub[key]
 	if nil != stream && nil != stream.hls {
 		stream.hls.OnUnPublish()
 	}
 
+	if nil != stream {
+		for _, f := range stream.forwarders {
+			f.stop()
+		}
+
+		if nil != stream.edge {
+			stream.edge.Stop()
+		}
+	}
+
 	delete(s.hub, key)
 }
@@ -0,0 +1,207 @@
+package co
+
+import (
+	"seal/hls"
+	"seal/rtmp/pt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StreamStats the stats of stream, for http api.
+type StreamStats struct {
+	Key         string          `json:"key"`
+	Vhost       string          `json:"vhost"`
+	Publisher   string          `json:"publisher"`
+	Uptime      int64           `json:"uptime"`
+	Codec       hls.CodecInfo   `json:"codec"`
+	Hls         string          `json:"hls,omitempty"` //the url of m3u8, if hls enabled.
+	RecvBytes   uint64          `json:"recvBytes"`
+	SendBytes   uint64          `json:"sendBytes"`
+	KbpsIn      float64         `json:"kbpsIn"`
+	KbpsOut     float64         `json:"kbpsOut"`
+	GopMsgs     int             `json:"gopMsgs"`
+	GopBytes    uint64          `json:"gopBytes"`
+	Gops        int             `json:"gops"`
+	GopDuration uint64          `json:"gopDuration"` //the duration in ms of gop cache.
+	Consumers   []ConsumerStats `json:"consumers"`
+}
+
+// ConsumerStats the stats of consumer, for http api.
+type ConsumerStats struct {
+	Name       string `json:"name"`
+	QueueDepth int    `json:"queueDepth"`
+	SendBytes  uint64 `json:"sendBytes"`
+	Dropped    uint64 `json:"dropped"` //the audio and video msgs dropped, for the consumer is too slow.
+}
+
+// ConnStats the stats of rtmp connection or http-flv player, for http api.
+type ConnStats struct {
+	ID         uint64 `json:"id"`
+	Role       string `json:"role"`
+	RemoteAddr string `json:"remoteAddr"`
+	Vhost      string `json:"vhost"`
+	App        string `json:"app"`
+	Stream     string `json:"stream"`
+	Uptime     int64  `json:"uptime"`
+	RecvBytes  uint64 `json:"recvBytes"`
+	SendBytes  uint64 `json:"sendBytes"`
+}
+
+// kbps calc the bitrate by the bytes sampled, in kilo bits per second.
+// the bitrate is the average between two samples, at least 1 second.
+type kbps struct {
+	mu        sync.Mutex
+	lastTime  time.Time
+	lastBytes uint64
+	value     float64
+}
+
+func (k *kbps) sample(bytes uint64, startTime time.Time) float64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.lastTime.IsZero() {
+		k.lastTime = startTime
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(k.lastTime).Seconds()
+	if elapsed < 1 {
+		return k.value
+	}
+
+	k.value = float64(bytes-k.lastBytes) * 8 / 1000 / elapsed
+	k.lastTime = now
+	k.lastBytes = bytes
+
+	return k.value
+}
+
+func roleName(role uint8) string {
+	switch role {
+	case pt.RtmpRoleFMLEPublisher:
+		return "fmle-publisher"
+	case pt.RtmpRoleFlashPublisher:
+		return "flash-publisher"
+	case pt.RtmpRolePlayer:
+		return "player"
+	case pt.RtmpRolePuller:
+		return "puller"
+	case pt.RtmpRoleEdge:
+		return "edge"
+	case pt.RtmpRoleForwarder:
+		return "forwarder"
+	}
+
+	return "unknown"
+}
+
+func (s *SourceStream) stats(key string) (st StreamStats) {
+	st.Key = key
+	st.Vhost = s.vhost
+	st.Publisher = s.publisher
+	st.Uptime = int64(time.Since(s.startTime).Seconds())
+
+	if nil != s.hls {
+		_, app, stream := s.hub.parseStreamKey(key)
+		st.Hls = hls.URL(s.vhost, app, stream+".m3u8")
+	}
+
+	s.cacheLock.Lock()
+	st.Codec = hls.ParseCodecInfo(s.CacheMetaData, s.CacheVideoSequenceHeader, s.CacheAudioSequenceHeader, s.hub.logger)
+	s.cacheLock.Unlock()
+
+	st.RecvBytes = atomic.LoadUint64(&s.recvBytes)
+	st.SendBytes = atomic.LoadUint64(&s.sendBytes)
+	st.KbpsIn = s.kbpsIn.sample(st.RecvBytes, s.startTime)
+	st.KbpsOut = s.kbpsOut.sample(st.SendBytes, s.startTime)
+	st.GopMsgs, st.GopBytes, st.Gops, st.GopDuration = s.GopCache.size()
+
+	s.consumerLock.RLock()
+	defer s.consumerLock.RUnlock()
+
+	st.Consumers = []ConsumerStats{}
+	for c := range s.consumers {
+		st.Consumers = append(st.Consumers, ConsumerStats{
+			Name:       c.stream,
+			QueueDepth: c.queueDepth(),
+			SendBytes:  atomic.LoadUint64(&c.sendBytes),
+			Dropped:    atomic.LoadUint64(&c.dropped),
+		})
+	}
+
+	return
+}
+
+// Stats the stats of all streams, sorted by key.
+func (s *SourceHub) Stats() (streams []StreamStats) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	streams = []StreamStats{}
+	for k, v := range s.hub {
+		streams = append(streams, v.stats(k))
+	}
+
+	sort.Slice(streams, func(i, j int) bool {
+		return streams[i].Key < streams[j].Key
+	})
+
+	return
+}
+
+// StreamStats the stats of the stream, false if not found.
+func (s *SourceHub) StreamStats(key string) (st StreamStats, ok bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	source := s.hub[key]
+	if nil == source {
+		return
+	}
+
+	return source.stats(key), true
+}
+
+// Stats the stats of all rtmp connections and http-flv players, sorted by id.
+func (h *ConnHub) Stats() (conns []ConnStats) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	conns = []ConnStats{}
+	for _, rc := range h.hub {
+		st := rc.loadState()
+		conns = append(conns, ConnStats{
+			ID:         rc.id,
+			Role:       roleName(st.role),
+			RemoteAddr: rc.tcpConn.RemoteAddr().String(),
+			Vhost:      st.vhost,
+			App:        st.app,
+			Stream:     st.stream,
+			Uptime:     int64(time.Since(rc.startTime).Seconds()),
+			RecvBytes:  rc.tcpConn.GetRecvBytesSum(),
+			SendBytes:  rc.tcpConn.GetSendBytesSum(),
+		})
+	}
+
+	for _, fs := range h.flvs {
+		conns = append(conns, ConnStats{
+			ID:         fs.id,
+			Role:       "http-flv",
+			RemoteAddr: fs.remoteAddr,
+			Vhost:      fs.vhost,
+			App:        fs.app,
+			Stream:     fs.stream,
+			Uptime:     int64(time.Since(fs.startTime).Seconds()),
+			SendBytes:  atomic.LoadUint64(&fs.consumer.sendBytes),
+		})
+	}
+
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].ID < conns[j].ID
+	})
+
+	return
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"strconv"
+	"time"
 )
 
 // Amf0Object amf0object
@@ -31,7 +33,52 @@ type amf0EcmaArray struct {
 
 type amf0StrictArray struct {
 	count     uint32
-	anyObject []interface{}
+	anyObject []Amf0Object
+}
+
+// Amf0TypedObject the amf0 object with the class name.
+type Amf0TypedObject struct {
+	ClassName  string
+	Properties []Amf0Object
+}
+
+// Amf0XMLDocument the amf0 xml document, in string.
+type Amf0XMLDocument string
+
+// Amf0Reference the amf0 reference, the index of the complex object(object, typed object,
+// ecma array and strict array) which is already sent in the same amf0 data.
+// it is resolved to the object referred when decoded, and written as is when encoded.
+type Amf0Reference uint16
+
+// amf0References the complex objects decoded in the same amf0 data, which the reference is the index of.
+type amf0References struct {
+	objects []Amf0Object
+}
+
+// reserve the index of complex object when its decode starts, the members may refer to it.
+func (refs *amf0References) reserve() (index int) {
+	index = len(refs.objects)
+	refs.objects = append(refs.objects, Amf0Object{valueType: RtmpAMF0Null})
+	return
+}
+
+// set the complex object decoded at index.
+func (refs *amf0References) set(index int, value interface{}, marker uint8) {
+	refs.objects[index].value = value
+	refs.objects[index].valueType = marker
+}
+
+// resolve the complex object which the reference is the index of.
+func (refs *amf0References) resolve(ref Amf0Reference) (value interface{}, marker uint8, err error) {
+	if int(ref) >= len(refs.objects) {
+		err = fmt.Errorf("Amf0ReadReference: reference out of range, reference=%d, objects=%d", ref, len(refs.objects))
+		return
+	}
+
+	value = refs.objects[ref].value
+	marker = refs.objects[ref].valueType
+
+	return
 }
 
 func (array *amf0EcmaArray) addObject(obj Amf0Object) {
@@ -41,7 +88,7 @@ func (array *amf0EcmaArray) addObject(obj Amf0Object) {
 
 // this function do not affect the offset parsed in data.
 func amf0ObjectEOF(data []uint8, offset *uint32) (res bool) {
-	if len(data) < 3 {
+	if uint32(len(data)) < *offset+3 {
 		res = false
 		return
 	}
@@ -97,7 +144,7 @@ func amf0WriteUtf8(value string) (data []uint8) {
 	return
 }
 
-func amf0ReadAny(data []uint8, marker *uint8, offset *uint32) (value interface{}, err error) {
+func amf0ReadAny(data []uint8, marker *uint8, offset *uint32, refs *amf0References) (value interface{}, err error) {
 
 	if amf0ObjectEOF(data, offset) {
 		return
@@ -122,15 +169,31 @@ func amf0ReadAny(data []uint8, marker *uint8, offset *uint32) (value interface{}
 	case RtmpAmf0Undefined:
 		err = amf0ReadUndefined(data, offset)
 	case RtmpAmf0Object:
-		value, err = amf0ReadObject(data, offset)
+		value, err = amf0ReadObject(data, offset, refs)
 	case RtmpAmf0LongString:
 		value, err = amf0ReadLongString(data, offset)
 	case RtmpAmf0EcmaArray:
-		value, err = amf0ReadEcmaArray(data, offset)
+		value, err = amf0ReadEcmaArray(data, offset, refs)
 	case RtmpAmf0StrictArray:
-		value, err = amf0ReadStrictArray(data, offset)
+		value, err = amf0ReadStrictArray(data, offset, refs)
+	case RtmpAmf0Date:
+		value, err = amf0ReadDate(data, offset)
+	case RtmpAmf0TypedObject:
+		value, err = amf0ReadTypedObject(data, offset, refs)
+	case RtmpAmf0XmlDocument:
+		value, err = amf0ReadXMLDocument(data, offset)
+	case RtmpAmf0Reference:
+		var ref Amf0Reference
+		if ref, err = amf0ReadReference(data, offset); err != nil {
+			break
+		}
+		value, *marker, err = refs.resolve(ref)
+	case RtmpAmf0UnSupported:
+		err = amf0ReadUnsupported(data, offset)
+	case RtmpAmf0AVMplusObject:
+		value, *marker, err = amf0ReadAvmplus(data, offset)
 	default:
-		err = fmt.Errorf("Amf0ReadAny: unknown marker Value, marker=%d", marker)
+		err = fmt.Errorf("Amf0ReadAny: unknown marker Value, marker=%d", *marker)
 	}
 
 	if err != nil {
@@ -159,7 +222,19 @@ func amf0WriteAny(any Amf0Object) (data []uint8) {
 	case RtmpAmf0EcmaArray:
 		data = amf0WriteEcmaArray(any.value.(amf0EcmaArray))
 	case RtmpAmf0StrictArray:
-		data = amf0WriteStrictArray(any.value.([]Amf0Object))
+		data = amf0WriteStrictArray(any.value.(amf0StrictArray))
+	case RtmpAmf0Date:
+		data = amf0WriteDate(any.value.(time.Time))
+	case RtmpAmf0TypedObject:
+		data = amf0WriteTypedObject(any.value.(Amf0TypedObject))
+	case RtmpAmf0XmlDocument:
+		data = amf0WriteXMLDocument(any.value.(Amf0XMLDocument))
+	case RtmpAmf0Reference:
+		data = amf0WriteReference(any.value.(Amf0Reference))
+	case RtmpAmf0UnSupported:
+		data = amf0WriteUnsupported()
+	case RtmpAmf0AVMplusObject:
+		data = amf0WriteAvmplus(any.value)
 	default:
 		log.Println("Amf0WriteAny: unknown type.", any.valueType)
 	}
@@ -174,6 +249,24 @@ func Amf0ReadString(data []uint8, offset *uint32) (value string, err error) {
 		return
 	}
 
+	// amf3 value in amf0, e.g. sent by the client which objectEncoding is amf3.
+	if RtmpAmf0AVMplusObject == data[*offset] {
+		var v interface{}
+		var m uint8
+		if v, m, err = amf0ReadAvmplus(data, offset); err != nil {
+			return
+		}
+
+		if RtmpAmf0String != m && RtmpAmf0LongString != m {
+			err = fmt.Errorf("Amf0ReadString: avmplus value is not expected, marker=%d", m)
+			return
+		}
+
+		value = v.(string)
+
+		return
+	}
+
 	marker := data[*offset]
 	*offset++
 
@@ -203,6 +296,24 @@ func Amf0ReadNumber(data []uint8, offset *uint32) (value float64, err error) {
 		return
 	}
 
+	// amf3 value in amf0, e.g. sent by the client which objectEncoding is amf3.
+	if RtmpAmf0AVMplusObject == data[*offset] {
+		var v interface{}
+		var m uint8
+		if v, m, err = amf0ReadAvmplus(data, offset); err != nil {
+			return
+		}
+
+		if RtmpAmf0Number != m {
+			err = fmt.Errorf("Amf0ReadNumber: avmplus value is not expected, marker=%d", m)
+			return
+		}
+
+		value = v.(float64)
+
+		return
+	}
+
 	marker := data[*offset]
 	*offset++
 
@@ -239,13 +350,31 @@ func amf0WriteNumber(value float64) (data []uint8) {
 	return
 }
 
-func amf0ReadObject(data []uint8, offset *uint32) (amf0objects []Amf0Object, err error) {
+func amf0ReadObject(data []uint8, offset *uint32, refs *amf0References) (amf0objects []Amf0Object, err error) {
 
 	if (uint32(len(data)) - *offset) < 1 {
 		err = fmt.Errorf("Amf0ReadObject: 0, data len is not enough")
 		return
 	}
 
+	// amf3 value in amf0, e.g. sent by the client which objectEncoding is amf3.
+	if RtmpAmf0AVMplusObject == data[*offset] {
+		var v interface{}
+		var m uint8
+		if v, m, err = amf0ReadAvmplus(data, offset); err != nil {
+			return
+		}
+
+		if RtmpAmf0Object != m {
+			err = fmt.Errorf("Amf0ReadObject: avmplus value is not expected, marker=%d", m)
+			return
+		}
+
+		amf0objects = v.([]Amf0Object)
+
+		return
+	}
+
 	marker := data[*offset]
 	*offset++
 
@@ -254,6 +383,11 @@ func amf0ReadObject(data []uint8, offset *uint32) (amf0objects []Amf0Object, err
 		return
 	}
 
+	index := refs.reserve()
+	defer func() {
+		refs.set(index, amf0objects, RtmpAmf0Object)
+	}()
+
 	for {
 		if *offset >= uint32(len(data)) {
 			break
@@ -270,7 +404,7 @@ func amf0ReadObject(data []uint8, offset *uint32) (amf0objects []Amf0Object, err
 			break
 		}
 
-		amf0object.value, err = amf0ReadAny(data, &amf0object.valueType, offset)
+		amf0object.value, err = amf0ReadAny(data, &amf0object.valueType, offset, refs)
 		if err != nil {
 			break
 		}
@@ -301,6 +435,24 @@ func amf0ReadBool(data []uint8, offset *uint32) (value bool, err error) {
 		return
 	}
 
+	// amf3 value in amf0, e.g. sent by the client which objectEncoding is amf3.
+	if RtmpAmf0AVMplusObject == data[*offset] {
+		var v interface{}
+		var m uint8
+		if v, m, err = amf0ReadAvmplus(data, offset); err != nil {
+			return
+		}
+
+		if RtmpAmf0Boolean != m {
+			err = fmt.Errorf("Amf0ReadBool: avmplus value is not expected, marker=%d", m)
+			return
+		}
+
+		value = v.(bool)
+
+		return
+	}
+
 	marker := data[*offset]
 	*offset++
 
@@ -345,6 +497,21 @@ func amf0ReadNull(data []uint8, offset *uint32) (err error) {
 		return
 	}
 
+	// amf3 value in amf0, e.g. sent by the client which objectEncoding is amf3.
+	if RtmpAmf0AVMplusObject == data[*offset] {
+		var m uint8
+		if _, m, err = amf0ReadAvmplus(data, offset); err != nil {
+			return
+		}
+
+		if RtmpAMF0Null != m && RtmpAmf0Undefined != m {
+			err = fmt.Errorf("Amf0ReadNull: avmplus value is not expected, marker=%d", m)
+			return
+		}
+
+		return
+	}
+
 	marker := data[*offset]
 	*offset++
 
@@ -446,7 +613,7 @@ func amf0WriteLongString(value string) (data []uint8) {
 	return
 }
 
-func amf0ReadEcmaArray(data []uint8, offset *uint32) (value amf0EcmaArray, err error) {
+func amf0ReadEcmaArray(data []uint8, offset *uint32, refs *amf0References) (value amf0EcmaArray, err error) {
 
 	if (uint32(len(data)) - *offset) < 1 {
 		err = fmt.Errorf("Amf0ReadEcmaArray: 0, data len is not enough")
@@ -461,6 +628,11 @@ func amf0ReadEcmaArray(data []uint8, offset *uint32) (value amf0EcmaArray, err e
 		return
 	}
 
+	index := refs.reserve()
+	defer func() {
+		refs.set(index, value, RtmpAmf0EcmaArray)
+	}()
+
 	if (uint32(len(data)) - *offset) < 4 {
 		err = fmt.Errorf("Amf0ReadEcmaArray: 1, data len is not enough")
 		return
@@ -484,7 +656,7 @@ func amf0ReadEcmaArray(data []uint8, offset *uint32) (value amf0EcmaArray, err e
 			break
 		}
 
-		amf.value, err = amf0ReadAny(data, &amf.valueType, offset)
+		amf.value, err = amf0ReadAny(data, &amf.valueType, offset, refs)
 		if err != nil {
 			break
 		}
@@ -517,7 +689,7 @@ func amf0WriteEcmaArray(arr amf0EcmaArray) (data []uint8) {
 	return
 }
 
-func amf0ReadStrictArray(data []uint8, offset *uint32) (value amf0StrictArray, err error) {
+func amf0ReadStrictArray(data []uint8, offset *uint32, refs *amf0References) (value amf0StrictArray, err error) {
 	if (uint32(len(data)) - *offset) < 1 {
 		err = fmt.Errorf("Amf0ReadStrictArray: 0, data len is not enough")
 		return
@@ -531,6 +703,11 @@ func amf0ReadStrictArray(data []uint8, offset *uint32) (value amf0StrictArray, e
 		return
 	}
 
+	index := refs.reserve()
+	defer func() {
+		refs.set(index, value, RtmpAmf0StrictArray)
+	}()
+
 	if (uint32(len(data)) - *offset) < 4 {
 		err = fmt.Errorf("Amf0ReadStrictArray: 1, data len is not enough")
 		return
@@ -544,10 +721,8 @@ func amf0ReadStrictArray(data []uint8, offset *uint32) (value amf0StrictArray, e
 			break
 		}
 
-		var obj interface{}
-
-		var markerLocal uint8
-		obj, err = amf0ReadAny(data, &markerLocal, offset)
+		var obj Amf0Object
+		obj.value, err = amf0ReadAny(data, &obj.valueType, offset, refs)
 		if err != nil {
 			break
 		}
@@ -558,7 +733,7 @@ func amf0ReadStrictArray(data []uint8, offset *uint32) (value amf0StrictArray, e
 	return
 }
 
-func amf0WriteStrictArray(objs []Amf0Object) (data []uint8) {
+func amf0WriteStrictArray(arr amf0StrictArray) (data []uint8) {
 	data = make([]uint8, 1+4)
 
 	var offset uint32
@@ -566,21 +741,246 @@ func amf0WriteStrictArray(objs []Amf0Object) (data []uint8) {
 	data[offset] = RtmpAmf0StrictArray
 	offset++
 
-	count := len(objs)
-	binary.BigEndian.PutUint32(data[offset:offset+4], uint32(count))
+	binary.BigEndian.PutUint32(data[offset:offset+4], uint32(len(arr.anyObject)))
 	offset += 4
 
-	for _, v := range objs {
+	// the strict array has no object end marker.
+	for _, v := range arr.anyObject {
+		data = append(data, amf0WriteAny(v)...)
+	}
+
+	return
+}
+
+func amf0ReadDate(data []uint8, offset *uint32) (value time.Time, err error) {
+	if (uint32(len(data)) - *offset) < 1 {
+		err = fmt.Errorf("Amf0ReadDate: 0, data len is not enough")
+		return
+	}
+
+	marker := data[*offset]
+	*offset++
+
+	if RtmpAmf0Date != marker {
+		err = fmt.Errorf("Amf0ReadDate: RTMP_AMF0_Date != marker")
+		return
+	}
+
+	if (uint32(len(data)) - *offset) < 8+2 {
+		err = fmt.Errorf("Amf0ReadDate: 1, data len is not enough")
+		return
+	}
+
+	// milliseconds since the epoch, in utc.
+	ms := int64(math.Float64frombits(binary.BigEndian.Uint64(data[*offset : *offset+8])))
+	*offset += 8
+
+	// the time zone is reserved, should be 0 and ignored.
+	*offset += 2
+
+	value = time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)).UTC()
+
+	return
+}
+
+func amf0WriteDate(value time.Time) (data []uint8) {
+	data = make([]uint8, 1+8+2)
+
+	var offset uint32
+
+	data[offset] = RtmpAmf0Date
+	offset++
+
+	ms := value.Unix()*1000 + int64(value.Nanosecond())/int64(time.Millisecond)
+	binary.BigEndian.PutUint64(data[offset:offset+8], math.Float64bits(float64(ms)))
+	offset += 8
+
+	// time zone, always 0.
+	binary.BigEndian.PutUint16(data[offset:offset+2], 0)
+	offset += 2
+
+	return
+}
+
+func amf0ReadTypedObject(data []uint8, offset *uint32, refs *amf0References) (value Amf0TypedObject, err error) {
+	if (uint32(len(data)) - *offset) < 1 {
+		err = fmt.Errorf("Amf0ReadTypedObject: 0, data len is not enough")
+		return
+	}
+
+	marker := data[*offset]
+	*offset++
+
+	if RtmpAmf0TypedObject != marker {
+		err = fmt.Errorf("Amf0ReadTypedObject: RTMP_AMF0_TypedObject != marker")
+		return
+	}
+
+	index := refs.reserve()
+	defer func() {
+		refs.set(index, value, RtmpAmf0TypedObject)
+	}()
+
+	if value.ClassName, err = amf0ReadUtf8(data, offset); err != nil {
+		return
+	}
+
+	for {
+		if *offset >= uint32(len(data)) {
+			break
+		}
+
+		if amf0ObjectEOF(data, offset) {
+			break
+		}
+
+		var amf0object Amf0Object
+
+		amf0object.propertyName, err = amf0ReadUtf8(data, offset)
+		if err != nil {
+			break
+		}
+
+		amf0object.value, err = amf0ReadAny(data, &amf0object.valueType, offset, refs)
+		if err != nil {
+			break
+		}
+
+		value.Properties = append(value.Properties, amf0object)
+	}
+
+	return
+}
+
+func amf0WriteTypedObject(value Amf0TypedObject) (data []uint8) {
+
+	data = append(data, RtmpAmf0TypedObject)
+	data = append(data, amf0WriteUtf8(value.ClassName)...)
+
+	for _, v := range value.Properties {
+		data = append(data, amf0WriteUtf8(v.propertyName)...)
 		data = append(data, amf0WriteAny(v)...)
 	}
 
-	//eof
 	data = append(data, 0x00, 0x00, 0x09)
 
 	return
 }
 
-func amf0Discovery(data []uint8, offset *uint32) (value interface{}, marker uint8, err error) {
+func amf0ReadXMLDocument(data []uint8, offset *uint32) (value Amf0XMLDocument, err error) {
+
+	if (uint32(len(data)) - *offset) < 1 {
+		err = fmt.Errorf("Amf0ReadXMLDocument: 0, data len is not enough")
+		return
+	}
+
+	marker := data[*offset]
+	*offset++
+
+	if RtmpAmf0XmlDocument != marker {
+		err = fmt.Errorf("Amf0ReadXMLDocument: RTMP_AMF0_XmlDocument != marker")
+		return
+	}
+
+	if (uint32(len(data)) - *offset) < 4 {
+		err = fmt.Errorf("Amf0ReadXMLDocument: 1, data len is not enough")
+		return
+	}
+
+	// the xml document is encoded as the long string.
+	dataLen := binary.BigEndian.Uint32(data[*offset : *offset+4])
+	*offset += 4
+
+	if (uint32(len(data)) - *offset) < dataLen {
+		err = fmt.Errorf("Amf0ReadXMLDocument: 2, data len is not enough")
+		return
+	}
+
+	value = Amf0XMLDocument(data[*offset : *offset+dataLen])
+	*offset += dataLen
+
+	return
+}
+
+func amf0WriteXMLDocument(value Amf0XMLDocument) (data []uint8) {
+
+	data = make([]uint8, 1+4+len(value))
+
+	var offset uint32
+
+	data[offset] = RtmpAmf0XmlDocument
+	offset++
+
+	binary.BigEndian.PutUint32(data[offset:offset+4], uint32(len(value)))
+	offset += 4
+
+	copy(data[offset:], value)
+
+	return
+}
+
+func amf0ReadReference(data []uint8, offset *uint32) (value Amf0Reference, err error) {
+
+	if (uint32(len(data)) - *offset) < 1 {
+		err = fmt.Errorf("Amf0ReadReference: 0, data len is not enough")
+		return
+	}
+
+	marker := data[*offset]
+	*offset++
+
+	if RtmpAmf0Reference != marker {
+		err = fmt.Errorf("Amf0ReadReference: RTMP_AMF0_Reference != marker")
+		return
+	}
+
+	if (uint32(len(data)) - *offset) < 2 {
+		err = fmt.Errorf("Amf0ReadReference: 1, data len is not enough")
+		return
+	}
+
+	value = Amf0Reference(binary.BigEndian.Uint16(data[*offset : *offset+2]))
+	*offset += 2
+
+	return
+}
+
+func amf0WriteReference(value Amf0Reference) (data []uint8) {
+
+	data = make([]uint8, 1+2)
+
+	data[0] = RtmpAmf0Reference
+	binary.BigEndian.PutUint16(data[1:3], uint16(value))
+
+	return
+}
+
+func amf0ReadUnsupported(data []uint8, offset *uint32) (err error) {
+
+	if (uint32(len(data)) - *offset) < 1 {
+		err = fmt.Errorf("Amf0ReadUnsupported: 0, data len is not enough")
+		return
+	}
+
+	marker := data[*offset]
+	*offset++
+
+	if RtmpAmf0UnSupported != marker {
+		err = fmt.Errorf("Amf0ReadUnsupported: RTMP_AMF0_UnSupported != marker")
+		return
+	}
+
+	return
+}
+
+func amf0WriteUnsupported() (data []uint8) {
+
+	data = append(data, RtmpAmf0UnSupported)
+
+	return
+}
+
+func amf0Discovery(data []uint8, offset *uint32, refs *amf0References) (value interface{}, marker uint8, err error) {
 
 	if amf0ObjectEOF(data, offset) {
 		return
@@ -605,13 +1005,29 @@ func amf0Discovery(data []uint8, offset *uint32) (value interface{}, marker uint
 	case RtmpAmf0Undefined:
 		err = amf0ReadUndefined(data, offset)
 	case RtmpAmf0Object:
-		value, err = amf0ReadObject(data, offset)
+		value, err = amf0ReadObject(data, offset, refs)
 	case RtmpAmf0LongString:
 		value, err = amf0ReadLongString(data, offset)
 	case RtmpAmf0EcmaArray:
-		value, err = amf0ReadEcmaArray(data, offset)
+		value, err = amf0ReadEcmaArray(data, offset, refs)
 	case RtmpAmf0StrictArray:
-		value, err = amf0ReadStrictArray(data, offset)
+		value, err = amf0ReadStrictArray(data, offset, refs)
+	case RtmpAmf0Date:
+		value, err = amf0ReadDate(data, offset)
+	case RtmpAmf0TypedObject:
+		value, err = amf0ReadTypedObject(data, offset, refs)
+	case RtmpAmf0XmlDocument:
+		value, err = amf0ReadXMLDocument(data, offset)
+	case RtmpAmf0Reference:
+		var ref Amf0Reference
+		if ref, err = amf0ReadReference(data, offset); err != nil {
+			break
+		}
+		value, marker, err = refs.resolve(ref)
+	case RtmpAmf0UnSupported:
+		err = amf0ReadUnsupported(data, offset)
+	case RtmpAmf0AVMplusObject:
+		value, marker, err = amf0ReadAvmplus(data, offset)
 	default:
 		err = fmt.Errorf("Amf0Discovery: unknown marker type, marker=%d", marker)
 	}
@@ -622,3 +1038,114 @@ func amf0Discovery(data []uint8, offset *uint32) (value interface{}, marker uint
 
 	return
 }
+
+// amf0ReadAvmplus read the amf3 value after the avmplus marker, and
+// convert it to amf0 value if it can be represented in amf0, e.g. amf3 object and array to amf0 object and array,
+// so the packets can decode it as amf0. otherwise keep the amf3 value and the avmplus marker.
+func amf0ReadAvmplus(data []uint8, offset *uint32) (value interface{}, marker uint8, err error) {
+	if (uint32(len(data)) - *offset) < 1 {
+		err = fmt.Errorf("Amf0ReadAvmplus: 0, data len is not enough")
+		return
+	}
+
+	if RtmpAmf0AVMplusObject != data[*offset] {
+		err = fmt.Errorf("Amf0ReadAvmplus: RTMP_AMF0_AVMplusObject != marker")
+		return
+	}
+	*offset++
+
+	var v interface{}
+	if v, err = Amf3ReadAny(data, offset); err != nil {
+		return
+	}
+
+	value, marker = amf3ToAmf0(v, make(map[interface{}]bool))
+
+	return
+}
+
+// amf3ToAmf0 convert amf3 value to amf0 value, visiting is used to break the reference cycle.
+func amf3ToAmf0(v interface{}, visiting map[interface{}]bool) (value interface{}, marker uint8) {
+	switch t := v.(type) {
+	case nil:
+		return nil, RtmpAMF0Null
+	case Amf3Undefined:
+		return nil, RtmpAmf0Undefined
+	case bool:
+		return t, RtmpAmf0Boolean
+	case int32:
+		return float64(t), RtmpAmf0Number
+	case float64:
+		return t, RtmpAmf0Number
+	case string:
+		if len(t) > 0xFFFF {
+			return t, RtmpAmf0LongString
+		}
+		return t, RtmpAmf0String
+	case time.Time:
+		return t, RtmpAmf0Date
+	case Amf3XMLDocument:
+		return Amf0XMLDocument(t), RtmpAmf0XmlDocument
+	case *Amf3Object:
+		if visiting[t] {
+			break
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		var objs []Amf0Object
+		for _, members := range [][]Amf3Property{t.Sealed, t.DynamicMembers} {
+			for _, p := range members {
+				obj := Amf0Object{propertyName: p.Name}
+				obj.value, obj.valueType = amf3ToAmf0(p.Value, visiting)
+				objs = append(objs, obj)
+			}
+		}
+
+		return objs, RtmpAmf0Object
+	case *Amf3Array:
+		if visiting[t] {
+			break
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		var objs []Amf0Object
+		for _, v := range t.Dense {
+			obj := Amf0Object{}
+			obj.value, obj.valueType = amf3ToAmf0(v, visiting)
+			objs = append(objs, obj)
+		}
+
+		// the dense only array is strict array, otherwise ecma array which the dense are keyed by index.
+		if 0 == len(t.Assoc) {
+			return amf0StrictArray{count: uint32(len(objs)), anyObject: objs}, RtmpAmf0StrictArray
+		}
+
+		for i := range objs {
+			objs[i].propertyName = strconv.Itoa(i)
+		}
+		for _, p := range t.Assoc {
+			obj := Amf0Object{propertyName: p.Name}
+			obj.value, obj.valueType = amf3ToAmf0(p.Value, visiting)
+			objs = append(objs, obj)
+		}
+
+		return amf0EcmaArray{count: uint32(len(objs)), anyObject: objs}, RtmpAmf0EcmaArray
+	}
+
+	return v, RtmpAmf0AVMplusObject
+}
+
+func amf0WriteAvmplus(value interface{}) (data []uint8) {
+	b, err := Amf3WriteAny(value)
+	if err != nil {
+		log.Println("Amf0WriteAvmplus: write amf3 failed, err=", err)
+		return
+	}
+
+	data = append(data, RtmpAmf0AVMplusObject)
+	data = append(data, b...)
+
+	return
+}
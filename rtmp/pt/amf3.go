@@ -0,0 +1,960 @@
+package pt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// the range of amf3 integer, out of range is encoded as double.
+const (
+	amf3IntegerMax = 0x0FFFFFFF
+	amf3IntegerMin = -0x10000000
+)
+
+// Amf3Undefined the amf3 undefined value, the amf3 null value is nil.
+type Amf3Undefined struct{}
+
+// Amf3XMLDocument the amf3 legacy xml document.
+type Amf3XMLDocument string
+
+// Amf3XML the amf3 E4X xml.
+type Amf3XML string
+
+// Amf3Property the name-value pair of amf3 object and array.
+type Amf3Property struct {
+	Name  string
+	Value interface{}
+}
+
+// Amf3Array amf3 array, with associative part and dense part.
+type Amf3Array struct {
+	Assoc []Amf3Property
+	Dense []interface{}
+}
+
+// Amf3Object amf3 object
+type Amf3Object struct {
+	// ClassName empty for anonymous object.
+	ClassName string
+	// Sealed the sealed members, in the order of traits.
+	Sealed []Amf3Property
+	// Dynamic whether the object is dynamic, only dynamic object has dynamic members.
+	Dynamic        bool
+	DynamicMembers []Amf3Property
+}
+
+// GetProperty get the property of object, sealed members first.
+func (o *Amf3Object) GetProperty(name string) interface{} {
+	for _, v := range o.Sealed {
+		if name == v.Name {
+			return v.Value
+		}
+	}
+
+	for _, v := range o.DynamicMembers {
+		if name == v.Name {
+			return v.Value
+		}
+	}
+
+	return nil
+}
+
+// Amf3VectorInt Vector.<int>
+type Amf3VectorInt struct {
+	Fixed bool
+	Items []int32
+}
+
+// Amf3VectorUint Vector.<uint>
+type Amf3VectorUint struct {
+	Fixed bool
+	Items []uint32
+}
+
+// Amf3VectorDouble Vector.<Number>
+type Amf3VectorDouble struct {
+	Fixed bool
+	Items []float64
+}
+
+// Amf3VectorObject Vector.<Object>, TypeName is the class name of items, e.g. "*" for any.
+type Amf3VectorObject struct {
+	Fixed    bool
+	TypeName string
+	Items    []interface{}
+}
+
+// Amf3DictionaryEntry .
+type Amf3DictionaryEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Amf3Dictionary flash.utils.Dictionary
+type Amf3Dictionary struct {
+	WeakKeys bool
+	Entries  []Amf3DictionaryEntry
+}
+
+// amf3Traits the traits of class, shared by the objects of same class.
+type amf3Traits struct {
+	className      string
+	externalizable bool
+	dynamic        bool
+	members        []string
+}
+
+// amf3Decoder amf3 decoder, the reference tables are valid in one amf3 value,
+// e.g. an avmplus object in amf0, or a value in amf3 msg.
+type amf3Decoder struct {
+	data    []uint8
+	offset  *uint32
+	strings []string
+	objects []interface{}
+	traits  []*amf3Traits
+}
+
+// Amf3ReadAny read amf3 value, the types are:
+// nil, Amf3Undefined, bool, int32, float64, string, Amf3XMLDocument, time.Time,
+// *Amf3Array, *Amf3Object, Amf3XML, []byte, *Amf3VectorInt, *Amf3VectorUint,
+// *Amf3VectorDouble, *Amf3VectorObject, *Amf3Dictionary
+func Amf3ReadAny(data []uint8, offset *uint32) (value interface{}, err error) {
+	d := &amf3Decoder{
+		data:   data,
+		offset: offset,
+	}
+
+	return d.readAny()
+}
+
+func (d *amf3Decoder) left() uint32 {
+	return uint32(len(d.data)) - *d.offset
+}
+
+func (d *amf3Decoder) readByte() (v uint8, err error) {
+	if d.left() < 1 {
+		err = fmt.Errorf("Amf3ReadByte: data len is not enough")
+		return
+	}
+
+	v = d.data[*d.offset]
+	*d.offset++
+
+	return
+}
+
+func (d *amf3Decoder) readBytes(n uint32) (v []uint8, err error) {
+	if d.left() < n {
+		err = fmt.Errorf("Amf3ReadBytes: data len is not enough, need %d", n)
+		return
+	}
+
+	v = d.data[*d.offset : *d.offset+n]
+	*d.offset += n
+
+	return
+}
+
+func (d *amf3Decoder) readU29() (v uint32, err error) {
+	var b uint8
+
+	for i := 0; i < 4; i++ {
+		if b, err = d.readByte(); err != nil {
+			return
+		}
+
+		if 3 == i {
+			v = v<<8 | uint32(b)
+			break
+		}
+
+		v = v<<7 | uint32(b&0x7F)
+
+		if 0 == b&0x80 {
+			break
+		}
+	}
+
+	return
+}
+
+func (d *amf3Decoder) readDouble() (v float64, err error) {
+	var b []uint8
+	if b, err = d.readBytes(8); err != nil {
+		return
+	}
+
+	v = math.Float64frombits(binary.BigEndian.Uint64(b))
+
+	return
+}
+
+// readUtf8 read the string without marker, which may be a reference.
+func (d *amf3Decoder) readUtf8() (v string, err error) {
+	var header uint32
+	if header, err = d.readU29(); err != nil {
+		return
+	}
+
+	if 0 == header&0x01 {
+		index := header >> 1
+		if index >= uint32(len(d.strings)) {
+			err = fmt.Errorf("Amf3ReadString: invalid string reference %d", index)
+			return
+		}
+
+		v = d.strings[index]
+		return
+	}
+
+	var b []uint8
+	if b, err = d.readBytes(header >> 1); err != nil {
+		return
+	}
+
+	v = string(b)
+
+	// empty string is never sent by reference.
+	if len(v) > 0 {
+		d.strings = append(d.strings, v)
+	}
+
+	return
+}
+
+// readObjectHeader read the U29 header of the object types,
+// return the referenced object if it's a reference, otherwise the header value.
+func (d *amf3Decoder) readObjectHeader() (ref interface{}, isRef bool, header uint32, err error) {
+	if header, err = d.readU29(); err != nil {
+		return
+	}
+
+	if 0 == header&0x01 {
+		index := header >> 1
+		if index >= uint32(len(d.objects)) {
+			err = fmt.Errorf("Amf3ReadObject: invalid object reference %d", index)
+			return
+		}
+
+		ref = d.objects[index]
+		isRef = true
+		return
+	}
+
+	header >>= 1
+
+	return
+}
+
+func (d *amf3Decoder) readAny() (value interface{}, err error) {
+	var marker uint8
+	if marker, err = d.readByte(); err != nil {
+		return
+	}
+
+	switch marker {
+	case RtmpAmf3Undefined:
+		value = Amf3Undefined{}
+	case RtmpAmf3Null:
+		value = nil
+	case RtmpAmf3False:
+		value = false
+	case RtmpAmf3True:
+		value = true
+	case RtmpAmf3Integer:
+		var v uint32
+		if v, err = d.readU29(); err != nil {
+			return
+		}
+		// sign extend the 29 bits integer.
+		if 0 != v&0x10000000 {
+			value = int32(v) - 0x20000000
+		} else {
+			value = int32(v)
+		}
+	case RtmpAmf3Double:
+		value, err = d.readDouble()
+	case RtmpAmf3String:
+		value, err = d.readUtf8()
+	case RtmpAmf3XMLDocument, RtmpAmf3XML:
+		value, err = d.readXML(marker)
+	case RtmpAmf3Date:
+		value, err = d.readDate()
+	case RtmpAmf3Array:
+		value, err = d.readArray()
+	case RtmpAmf3Object:
+		value, err = d.readObject()
+	case RtmpAmf3ByteArray:
+		value, err = d.readByteArray()
+	case RtmpAmf3VectorInt, RtmpAmf3VectorUint, RtmpAmf3VectorDouble, RtmpAmf3VectorObject:
+		value, err = d.readVector(marker)
+	case RtmpAmf3Dictionary:
+		value, err = d.readDictionary()
+	default:
+		err = fmt.Errorf("Amf3ReadAny: unknown marker, marker=%d", marker)
+	}
+
+	return
+}
+
+func (d *amf3Decoder) readXML(marker uint8) (value interface{}, err error) {
+	var ref interface{}
+	var isRef bool
+	var header uint32
+	if ref, isRef, header, err = d.readObjectHeader(); err != nil || isRef {
+		value = ref
+		return
+	}
+
+	var b []uint8
+	if b, err = d.readBytes(header); err != nil {
+		return
+	}
+
+	if RtmpAmf3XML == marker {
+		value = Amf3XML(b)
+	} else {
+		value = Amf3XMLDocument(b)
+	}
+
+	d.objects = append(d.objects, value)
+
+	return
+}
+
+func (d *amf3Decoder) readDate() (value interface{}, err error) {
+	var ref interface{}
+	var isRef bool
+	if ref, isRef, _, err = d.readObjectHeader(); err != nil || isRef {
+		value = ref
+		return
+	}
+
+	var ms float64
+	if ms, err = d.readDouble(); err != nil {
+		return
+	}
+
+	value = time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC()
+	d.objects = append(d.objects, value)
+
+	return
+}
+
+func (d *amf3Decoder) readByteArray() (value interface{}, err error) {
+	var ref interface{}
+	var isRef bool
+	var header uint32
+	if ref, isRef, header, err = d.readObjectHeader(); err != nil || isRef {
+		value = ref
+		return
+	}
+
+	var b []uint8
+	if b, err = d.readBytes(header); err != nil {
+		return
+	}
+
+	v := make([]byte, len(b))
+	copy(v, b)
+
+	value = v
+	d.objects = append(d.objects, value)
+
+	return
+}
+
+func (d *amf3Decoder) readArray() (value interface{}, err error) {
+	var ref interface{}
+	var isRef bool
+	var count uint32
+	if ref, isRef, count, err = d.readObjectHeader(); err != nil || isRef {
+		value = ref
+		return
+	}
+
+	arr := &Amf3Array{}
+	d.objects = append(d.objects, arr)
+
+	for {
+		var name string
+		if name, err = d.readUtf8(); err != nil {
+			return
+		}
+
+		if 0 == len(name) {
+			break
+		}
+
+		var v interface{}
+		if v, err = d.readAny(); err != nil {
+			return
+		}
+
+		arr.Assoc = append(arr.Assoc, Amf3Property{Name: name, Value: v})
+	}
+
+	if count > d.left() {
+		err = fmt.Errorf("Amf3ReadArray: invalid dense count %d", count)
+		return
+	}
+
+	for i := uint32(0); i < count; i++ {
+		var v interface{}
+		if v, err = d.readAny(); err != nil {
+			return
+		}
+
+		arr.Dense = append(arr.Dense, v)
+	}
+
+	value = arr
+
+	return
+}
+
+func (d *amf3Decoder) readTraits(header uint32) (traits *amf3Traits, err error) {
+	// traits reference
+	if 0 == header&0x01 {
+		index := header >> 1
+		if index >= uint32(len(d.traits)) {
+			err = fmt.Errorf("Amf3ReadObject: invalid traits reference %d", index)
+			return
+		}
+
+		traits = d.traits[index]
+		return
+	}
+
+	traits = &amf3Traits{
+		externalizable: 0 != header&0x02,
+		dynamic:        0 != header&0x04,
+	}
+
+	if traits.className, err = d.readUtf8(); err != nil {
+		return
+	}
+
+	count := header >> 3
+	if count > d.left() {
+		err = fmt.Errorf("Amf3ReadObject: invalid sealed count %d", count)
+		return
+	}
+
+	for i := uint32(0); i < count; i++ {
+		var name string
+		if name, err = d.readUtf8(); err != nil {
+			return
+		}
+
+		traits.members = append(traits.members, name)
+	}
+
+	d.traits = append(d.traits, traits)
+
+	return
+}
+
+func (d *amf3Decoder) readObject() (value interface{}, err error) {
+	var ref interface{}
+	var isRef bool
+	var header uint32
+	if ref, isRef, header, err = d.readObjectHeader(); err != nil || isRef {
+		value = ref
+		return
+	}
+
+	var traits *amf3Traits
+	if traits, err = d.readTraits(header); err != nil {
+		return
+	}
+
+	if traits.externalizable {
+		err = fmt.Errorf("Amf3ReadObject: externalizable class %s is not supported", traits.className)
+		return
+	}
+
+	obj := &Amf3Object{
+		ClassName: traits.className,
+		Dynamic:   traits.dynamic,
+	}
+	d.objects = append(d.objects, obj)
+
+	for _, name := range traits.members {
+		var v interface{}
+		if v, err = d.readAny(); err != nil {
+			return
+		}
+
+		obj.Sealed = append(obj.Sealed, Amf3Property{Name: name, Value: v})
+	}
+
+	if traits.dynamic {
+		for {
+			var name string
+			if name, err = d.readUtf8(); err != nil {
+				return
+			}
+
+			if 0 == len(name) {
+				break
+			}
+
+			var v interface{}
+			if v, err = d.readAny(); err != nil {
+				return
+			}
+
+			obj.DynamicMembers = append(obj.DynamicMembers, Amf3Property{Name: name, Value: v})
+		}
+	}
+
+	value = obj
+
+	return
+}
+
+func (d *amf3Decoder) readVector(marker uint8) (value interface{}, err error) {
+	var ref interface{}
+	var isRef bool
+	var count uint32
+	if ref, isRef, count, err = d.readObjectHeader(); err != nil || isRef {
+		value = ref
+		return
+	}
+
+	var fixed uint8
+	if fixed, err = d.readByte(); err != nil {
+		return
+	}
+
+	// each item takes 1 byte at least.
+	if count > d.left() {
+		err = fmt.Errorf("Amf3ReadVector: invalid count %d", count)
+		return
+	}
+
+	switch marker {
+	case RtmpAmf3VectorInt, RtmpAmf3VectorUint, RtmpAmf3VectorDouble:
+		size := uint32(4)
+		if RtmpAmf3VectorDouble == marker {
+			size = 8
+		}
+
+		var b []uint8
+		if b, err = d.readBytes(count * size); err != nil {
+			return
+		}
+
+		switch marker {
+		case RtmpAmf3VectorInt:
+			v := &Amf3VectorInt{Fixed: 0 != fixed}
+			for i := uint32(0); i < count; i++ {
+				v.Items = append(v.Items, int32(binary.BigEndian.Uint32(b[i*4:])))
+			}
+			value = v
+		case RtmpAmf3VectorUint:
+			v := &Amf3VectorUint{Fixed: 0 != fixed}
+			for i := uint32(0); i < count; i++ {
+				v.Items = append(v.Items, binary.BigEndian.Uint32(b[i*4:]))
+			}
+			value = v
+		default:
+			v := &Amf3VectorDouble{Fixed: 0 != fixed}
+			for i := uint32(0); i < count; i++ {
+				v.Items = append(v.Items, math.Float64frombits(binary.BigEndian.Uint64(b[i*8:])))
+			}
+			value = v
+		}
+
+		d.objects = append(d.objects, value)
+	default:
+		v := &Amf3VectorObject{Fixed: 0 != fixed}
+		d.objects = append(d.objects, v)
+
+		if v.TypeName, err = d.readUtf8(); err != nil {
+			return
+		}
+
+		for i := uint32(0); i < count; i++ {
+			var item interface{}
+			if item, err = d.readAny(); err != nil {
+				return
+			}
+
+			v.Items = append(v.Items, item)
+		}
+
+		value = v
+	}
+
+	return
+}
+
+func (d *amf3Decoder) readDictionary() (value interface{}, err error) {
+	var ref interface{}
+	var isRef bool
+	var count uint32
+	if ref, isRef, count, err = d.readObjectHeader(); err != nil || isRef {
+		value = ref
+		return
+	}
+
+	var weak uint8
+	if weak, err = d.readByte(); err != nil {
+		return
+	}
+
+	if count > d.left() {
+		err = fmt.Errorf("Amf3ReadDictionary: invalid count %d", count)
+		return
+	}
+
+	dict := &Amf3Dictionary{WeakKeys: 0 != weak}
+	d.objects = append(d.objects, dict)
+
+	for i := uint32(0); i < count; i++ {
+		var e Amf3DictionaryEntry
+
+		if e.Key, err = d.readAny(); err != nil {
+			return
+		}
+
+		if e.Value, err = d.readAny(); err != nil {
+			return
+		}
+
+		dict.Entries = append(dict.Entries, e)
+	}
+
+	value = dict
+
+	return
+}
+
+// amf3Encoder amf3 encoder, the reference tables are valid in one amf3 value.
+type amf3Encoder struct {
+	data    []uint8
+	strings map[string]int
+	// objects key is the pointer of object.
+	objects map[interface{}]int
+	// traits key is the signature of traits.
+	traits map[string]int
+}
+
+// Amf3WriteAny write the amf3 value, supports the types returned by Amf3ReadAny, and:
+// int, int64, uint32, float32 as number, []interface{} as dense array,
+// []Amf0Object and map[string]interface{} as anonymous dynamic object.
+func Amf3WriteAny(value interface{}) (data []uint8, err error) {
+	e := &amf3Encoder{
+		strings: make(map[string]int),
+		objects: make(map[interface{}]int),
+		traits:  make(map[string]int),
+	}
+
+	if err = e.writeAny(value); err != nil {
+		return
+	}
+
+	data = e.data
+
+	return
+}
+
+func (e *amf3Encoder) writeU29(v uint32) {
+	v &= 0x1FFFFFFF
+
+	switch {
+	case v < 0x80:
+		e.data = append(e.data, uint8(v))
+	case v < 0x4000:
+		e.data = append(e.data, uint8(v>>7|0x80), uint8(v&0x7F))
+	case v < 0x200000:
+		e.data = append(e.data, uint8(v>>14|0x80), uint8(v>>7&0x7F|0x80), uint8(v&0x7F))
+	default:
+		e.data = append(e.data, uint8(v>>22|0x80), uint8(v>>15&0x7F|0x80), uint8(v>>8&0x7F|0x80), uint8(v))
+	}
+}
+
+func (e *amf3Encoder) writeDouble(v float64) {
+	var b [8]uint8
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+	e.data = append(e.data, b[:]...)
+}
+
+func (e *amf3Encoder) writeNumber(v int64) {
+	if v >= amf3IntegerMin && v <= amf3IntegerMax {
+		e.data = append(e.data, RtmpAmf3Integer)
+		e.writeU29(uint32(v))
+		return
+	}
+
+	e.data = append(e.data, RtmpAmf3Double)
+	e.writeDouble(float64(v))
+}
+
+// writeUtf8 write the string without marker, use reference if written before.
+func (e *amf3Encoder) writeUtf8(v string) {
+	if 0 == len(v) {
+		e.writeU29(0x01)
+		return
+	}
+
+	if index, ok := e.strings[v]; ok {
+		e.writeU29(uint32(index) << 1)
+		return
+	}
+
+	e.strings[v] = len(e.strings)
+
+	e.writeU29(uint32(len(v))<<1 | 0x01)
+	e.data = append(e.data, v...)
+}
+
+// writeReference write the reference if the object is written before,
+// otherwise add it to the reference table.
+func (e *amf3Encoder) writeReference(key interface{}) bool {
+	if index, ok := e.objects[key]; ok {
+		e.writeU29(uint32(index) << 1)
+		return true
+	}
+
+	e.objects[key] = len(e.objects)
+
+	return false
+}
+
+func (e *amf3Encoder) writeAny(value interface{}) (err error) {
+	switch v := value.(type) {
+	case nil:
+		e.data = append(e.data, RtmpAmf3Null)
+	case Amf3Undefined:
+		e.data = append(e.data, RtmpAmf3Undefined)
+	case bool:
+		if v {
+			e.data = append(e.data, RtmpAmf3True)
+		} else {
+			e.data = append(e.data, RtmpAmf3False)
+		}
+	case int:
+		e.writeNumber(int64(v))
+	case int32:
+		e.writeNumber(int64(v))
+	case int64:
+		e.writeNumber(v)
+	case uint32:
+		e.writeNumber(int64(v))
+	case float32:
+		e.data = append(e.data, RtmpAmf3Double)
+		e.writeDouble(float64(v))
+	case float64:
+		e.data = append(e.data, RtmpAmf3Double)
+		e.writeDouble(v)
+	case string:
+		e.data = append(e.data, RtmpAmf3String)
+		e.writeUtf8(v)
+	case Amf3XMLDocument:
+		e.data = append(e.data, RtmpAmf3XMLDocument)
+		e.writeU29(uint32(len(v))<<1 | 0x01)
+		e.data = append(e.data, v...)
+		e.objects[new(int)] = len(e.objects)
+	case Amf3XML:
+		e.data = append(e.data, RtmpAmf3XML)
+		e.writeU29(uint32(len(v))<<1 | 0x01)
+		e.data = append(e.data, v...)
+		e.objects[new(int)] = len(e.objects)
+	case time.Time:
+		e.data = append(e.data, RtmpAmf3Date)
+		e.writeU29(0x01)
+		e.writeDouble(float64(v.UnixNano() / int64(time.Millisecond)))
+		e.objects[new(int)] = len(e.objects)
+	case []byte:
+		e.data = append(e.data, RtmpAmf3ByteArray)
+		e.writeU29(uint32(len(v))<<1 | 0x01)
+		e.data = append(e.data, v...)
+		e.objects[new(int)] = len(e.objects)
+	case []interface{}:
+		err = e.writeArray(&Amf3Array{Dense: v}, nil)
+	case *Amf3Array:
+		err = e.writeArray(v, v)
+	case Amf3Array:
+		err = e.writeArray(&v, nil)
+	case *Amf3Object:
+		err = e.writeObject(v, v)
+	case Amf3Object:
+		err = e.writeObject(&v, nil)
+	case []Amf0Object:
+		obj := &Amf3Object{Dynamic: true}
+		for _, p := range v {
+			obj.DynamicMembers = append(obj.DynamicMembers, Amf3Property{Name: p.propertyName, Value: p.value})
+		}
+		err = e.writeObject(obj, nil)
+	case map[string]interface{}:
+		obj := &Amf3Object{Dynamic: true}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			obj.DynamicMembers = append(obj.DynamicMembers, Amf3Property{Name: k, Value: v[k]})
+		}
+		err = e.writeObject(obj, nil)
+	case *Amf3VectorInt:
+		e.data = append(e.data, RtmpAmf3VectorInt)
+		if !e.writeReference(v) {
+			e.writeVectorHeader(len(v.Items), v.Fixed)
+			for _, item := range v.Items {
+				e.data = append(e.data, uint8(item>>24), uint8(item>>16), uint8(item>>8), uint8(item))
+			}
+		}
+	case *Amf3VectorUint:
+		e.data = append(e.data, RtmpAmf3VectorUint)
+		if !e.writeReference(v) {
+			e.writeVectorHeader(len(v.Items), v.Fixed)
+			for _, item := range v.Items {
+				e.data = append(e.data, uint8(item>>24), uint8(item>>16), uint8(item>>8), uint8(item))
+			}
+		}
+	case *Amf3VectorDouble:
+		e.data = append(e.data, RtmpAmf3VectorDouble)
+		if !e.writeReference(v) {
+			e.writeVectorHeader(len(v.Items), v.Fixed)
+			for _, item := range v.Items {
+				e.writeDouble(item)
+			}
+		}
+	case *Amf3VectorObject:
+		e.data = append(e.data, RtmpAmf3VectorObject)
+		if !e.writeReference(v) {
+			e.writeVectorHeader(len(v.Items), v.Fixed)
+			e.writeUtf8(v.TypeName)
+			for _, item := range v.Items {
+				if err = e.writeAny(item); err != nil {
+					return
+				}
+			}
+		}
+	case *Amf3Dictionary:
+		e.data = append(e.data, RtmpAmf3Dictionary)
+		if !e.writeReference(v) {
+			e.writeU29(uint32(len(v.Entries))<<1 | 0x01)
+			if v.WeakKeys {
+				e.data = append(e.data, 1)
+			} else {
+				e.data = append(e.data, 0)
+			}
+			for _, entry := range v.Entries {
+				if err = e.writeAny(entry.Key); err != nil {
+					return
+				}
+				if err = e.writeAny(entry.Value); err != nil {
+					return
+				}
+			}
+		}
+	default:
+		err = fmt.Errorf("Amf3WriteAny: unsupported type %T", value)
+	}
+
+	return
+}
+
+func (e *amf3Encoder) writeVectorHeader(count int, fixed bool) {
+	e.writeU29(uint32(count)<<1 | 0x01)
+
+	if fixed {
+		e.data = append(e.data, 1)
+	} else {
+		e.data = append(e.data, 0)
+	}
+}
+
+// writeArray write array, key is used for reference, nil if can not be referenced.
+func (e *amf3Encoder) writeArray(v *Amf3Array, key interface{}) (err error) {
+	e.data = append(e.data, RtmpAmf3Array)
+
+	if nil == key {
+		key = v
+	}
+
+	if e.writeReference(key) {
+		return
+	}
+
+	e.writeU29(uint32(len(v.Dense))<<1 | 0x01)
+
+	for _, p := range v.Assoc {
+		e.writeUtf8(p.Name)
+		if err = e.writeAny(p.Value); err != nil {
+			return
+		}
+	}
+	e.writeUtf8("")
+
+	for _, item := range v.Dense {
+		if err = e.writeAny(item); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// writeObject write object, key is used for reference, nil if can not be referenced.
+func (e *amf3Encoder) writeObject(v *Amf3Object, key interface{}) (err error) {
+	e.data = append(e.data, RtmpAmf3Object)
+
+	if nil == key {
+		key = v
+	}
+
+	if e.writeReference(key) {
+		return
+	}
+
+	// traits signature, e.g. "true|className|a|b"
+	signature := fmt.Sprint(v.Dynamic, "|", v.ClassName)
+	for _, p := range v.Sealed {
+		signature += "|" + p.Name
+	}
+
+	if index, ok := e.traits[signature]; ok {
+		e.writeU29(uint32(index)<<2 | 0x01)
+	} else {
+		e.traits[signature] = len(e.traits)
+
+		header := uint32(len(v.Sealed))<<4 | 0x03
+		if v.Dynamic {
+			header |= 0x08
+		}
+		e.writeU29(header)
+
+		e.writeUtf8(v.ClassName)
+		for _, p := range v.Sealed {
+			e.writeUtf8(p.Name)
+		}
+	}
+
+	for _, p := range v.Sealed {
+		if err = e.writeAny(p.Value); err != nil {
+			return
+		}
+	}
+
+	if v.Dynamic {
+		for _, p := range v.DynamicMembers {
+			e.writeUtf8(p.Name)
+			if err = e.writeAny(p.Value); err != nil {
+				return
+			}
+		}
+		e.writeUtf8("")
+	}
+
+	return
+}
@@ -0,0 +1,178 @@
+package pt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// amf3Decode decode the amf3 value, which must consume all data.
+func amf3Decode(t *testing.T, data []uint8) interface{} {
+	var offset uint32
+	v, err := Amf3ReadAny(data, &offset)
+	if err != nil {
+		t.Fatalf("decode %x, err=%v", data, err)
+	}
+
+	if int(offset) != len(data) {
+		t.Fatalf("decode %x not completed, offset=%d", data, offset)
+	}
+
+	return v
+}
+
+func TestAmf3Integer(t *testing.T) {
+	for _, v := range []struct {
+		value  interface{}
+		data   []uint8
+		decode interface{}
+	}{
+		// the boundaries of 1, 2, 3 and 4 bytes U29.
+		{int32(0), []uint8{0x04, 0x00}, int32(0)},
+		{int32(0x7F), []uint8{0x04, 0x7F}, int32(0x7F)},
+		{int32(0x80), []uint8{0x04, 0x81, 0x00}, int32(0x80)},
+		{int32(0x3FFF), []uint8{0x04, 0xFF, 0x7F}, int32(0x3FFF)},
+		{int32(0x4000), []uint8{0x04, 0x81, 0x80, 0x00}, int32(0x4000)},
+		{int32(0x1FFFFF), []uint8{0x04, 0xFF, 0xFF, 0x7F}, int32(0x1FFFFF)},
+		{int32(0x200000), []uint8{0x04, 0x80, 0xC0, 0x80, 0x00}, int32(0x200000)},
+		{int32(0x0FFFFFFF), []uint8{0x04, 0xBF, 0xFF, 0xFF, 0xFF}, int32(0x0FFFFFFF)},
+		// the negative are 29 bits two's complement.
+		{int32(-1), []uint8{0x04, 0xFF, 0xFF, 0xFF, 0xFF}, int32(-1)},
+		{int32(-0x10000000), []uint8{0x04, 0xC0, 0x80, 0x80, 0x00}, int32(-0x10000000)},
+		// out of range is promoted to double.
+		{int32(0x10000000), []uint8{0x05, 0x41, 0xB0, 0, 0, 0, 0, 0, 0}, float64(0x10000000)},
+		{int32(-0x10000001), []uint8{0x05, 0xC1, 0xB0, 0, 0, 0x01, 0, 0, 0}, float64(-0x10000001)},
+		{uint32(0xFFFFFFFF), []uint8{0x05, 0x41, 0xEF, 0xFF, 0xFF, 0xFF, 0xE0, 0, 0}, float64(0xFFFFFFFF)},
+		{int64(-1) << 40, []uint8{0x05, 0xC2, 0x70, 0, 0, 0, 0, 0, 0}, float64(int64(-1) << 40)},
+	} {
+		data, err := Amf3WriteAny(v.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(v.data, data) {
+			t.Errorf("encode %v to %x, expect %x", v.value, data, v.data)
+			continue
+		}
+
+		if d := amf3Decode(t, data); !reflect.DeepEqual(v.decode, d) {
+			t.Errorf("decode %x to %#v, expect %#v", data, d, v.decode)
+		}
+	}
+}
+
+func TestAmf3References(t *testing.T) {
+	obj := &Amf3Object{Dynamic: true}
+
+	for _, v := range []struct {
+		name  string
+		value interface{}
+		data  []uint8
+	}{
+		// the second string is the reference of index 0, the empty string is never referenced.
+		{"string", &Amf3Array{Dense: []interface{}{"ab", "ab", "", ""}},
+			[]uint8{0x09, 0x09, 0x01, 0x06, 0x05, 'a', 'b', 0x06, 0x00, 0x06, 0x01, 0x06, 0x01}},
+		// the array is object 0, the object is object 1.
+		{"object", &Amf3Array{Dense: []interface{}{obj, obj}},
+			[]uint8{0x09, 0x05, 0x01, 0x0A, 0x0B, 0x01, 0x01, 0x0A, 0x02}},
+		// the objects of same class share the traits, the class name and member are referenced strings.
+		{"traits", &Amf3Array{Dense: []interface{}{
+			&Amf3Object{ClassName: "A", Sealed: []Amf3Property{{Name: "x", Value: int32(1)}}},
+			&Amf3Object{ClassName: "A", Sealed: []Amf3Property{{Name: "x", Value: int32(2)}}},
+		}}, []uint8{0x09, 0x05, 0x01, 0x0A, 0x13, 0x03, 'A', 0x03, 'x', 0x04, 0x01, 0x0A, 0x01, 0x04, 0x02}},
+		// the dynamic traits are shared too, the members of each object follow.
+		{"dynamic traits", &Amf3Array{Dense: []interface{}{
+			&Amf3Object{ClassName: "B", Dynamic: true, DynamicMembers: []Amf3Property{{Name: "x", Value: "y"}}},
+			&Amf3Object{ClassName: "B", Dynamic: true, DynamicMembers: []Amf3Property{{Name: "y", Value: true}}},
+		}}, []uint8{0x09, 0x05, 0x01,
+			0x0A, 0x0B, 0x03, 'B', 0x03, 'x', 0x06, 0x03, 'y', 0x01,
+			0x0A, 0x01, 0x04, 0x03, 0x01}},
+	} {
+		data, err := Amf3WriteAny(v.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(v.data, data) {
+			t.Errorf("%s: encode to %x, expect %x", v.name, data, v.data)
+			continue
+		}
+
+		if d := amf3Decode(t, data); !reflect.DeepEqual(v.value, d) {
+			t.Errorf("%s: decode to %#v, expect %#v", v.name, d, v.value)
+		}
+	}
+
+	// the referenced object is the same object.
+	arr := amf3Decode(t, []uint8{0x09, 0x05, 0x01, 0x0A, 0x0B, 0x01, 0x01, 0x0A, 0x02}).(*Amf3Array)
+	if arr.Dense[0] != arr.Dense[1] {
+		t.Error("the referenced object is not the same object")
+	}
+}
+
+func TestAmf3Invalid(t *testing.T) {
+	for _, v := range []struct {
+		name string
+		data []uint8
+	}{
+		{"string reference", []uint8{0x06, 0x02}},
+		{"object reference", []uint8{0x0A, 0x02}},
+		{"traits reference", []uint8{0x0A, 0x05}},
+		// the externalizable class has its own encoding, can not be decoded.
+		{"externalizable", []uint8{0x0A, 0x07, 0x07, 'F', 'o', 'o'}},
+		{"dense count", []uint8{0x09, 0xFF, 0xFF, 0x7F, 0x01}},
+		{"truncated U29", []uint8{0x04, 0x81}},
+	} {
+		var offset uint32
+		if _, err := Amf3ReadAny(v.data, &offset); err == nil {
+			t.Errorf("%s: decode %x should fail", v.name, v.data)
+		}
+	}
+}
+
+// TestAmf3Command the client with objectEncoding=3 sends amf3 command, whose amf3 values are marked by avmplus.
+func TestAmf3Command(t *testing.T) {
+	obj, err := Amf3WriteAny(&Amf3Object{Dynamic: true, DynamicMembers: []Amf3Property{
+		{Name: "app", Value: "live"},
+		{Name: "tcUrl", Value: "rtmp://127.0.0.1/live"},
+		{Name: "objectEncoding", Value: int32(3)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []uint8{0x00}
+	payload = append(payload, amf0WriteString(RtmpAmf0CommandConnect)...)
+	payload = append(payload, amf0WriteNumber(1)...)
+	payload = append(payload, RtmpAmf0AVMplusObject)
+	payload = append(payload, obj...)
+
+	msg := &Message{}
+	msg.Payload.Payload = payload
+	msg.Header.MessageType = RtmpMsgAmf3CommandMessage
+	msg.Header.PayloadLength = uint32(len(payload))
+
+	msg.ConvertAmf3ToAmf0()
+
+	if !msg.Header.IsAmf0Command() || len(payload)-1 != len(msg.Payload.Payload) || uint32(len(payload)-1) != msg.Header.PayloadLength {
+		t.Fatalf("convert to type=%d, payload=%d", msg.Header.MessageType, len(msg.Payload.Payload))
+	}
+
+	var pkt ConnectPacket
+	if err = pkt.Decode(msg.Payload.Payload); err != nil {
+		t.Fatal(err)
+	}
+
+	var info struct {
+		App            string  `amf:"app"`
+		TcURL          string  `amf:"tcUrl"`
+		ObjectEncoding float64 `amf:"objectEncoding"`
+	}
+	if err = UnmarshalObject(pkt.CommandObject, &info); err != nil {
+		t.Fatal(err)
+	}
+
+	if "live" != info.App || "rtmp://127.0.0.1/live" != info.TcURL || 3 != info.ObjectEncoding {
+		t.Fatalf("connect %+v", info)
+	}
+}
@@ -0,0 +1,494 @@
+package pt
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UnmarshalTypeError the amf0 value can not be stored in the go value.
+type UnmarshalTypeError struct {
+	// Field the path of the field, e.g. "info.width"
+	Field string
+	// Value the description of amf0 value, e.g. "string"
+	Value string
+	// Type the go type which can not store the value.
+	Type reflect.Type
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("amf: can not unmarshal %s into field %s of type %s", e.Value, e.Field, e.Type)
+}
+
+// InvalidUnmarshalError the argument of unmarshal is not a non-nil pointer.
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if nil == e.Type {
+		return "amf: unmarshal(nil)"
+	}
+
+	return fmt.Sprintf("amf: unmarshal(non-pointer or nil %s)", e.Type)
+}
+
+// UnsupportedTypeError the go type can not be marshaled to amf0.
+type UnsupportedTypeError struct {
+	Field string
+	Type  reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	if 0 == len(e.Field) {
+		return fmt.Sprintf("amf: unsupported type %s", e.Type)
+	}
+
+	return fmt.Sprintf("amf: unsupported type %s of field %s", e.Type, e.Field)
+}
+
+// Marshal encode the go value to amf0, the struct is encoded as amf0 object,
+// the fields are named by tag, e.g. `amf:"tcUrl"`, `amf:"tcUrl,omitempty"`,
+// or `amf:"-"` to skip the field, the field name is used if no tag.
+func Marshal(v interface{}) (data []uint8, err error) {
+	var obj Amf0Object
+	if obj.value, obj.valueType, err = marshalValue(reflect.ValueOf(v), ""); err != nil {
+		return
+	}
+
+	data = amf0WriteAny(obj)
+
+	return
+}
+
+// MarshalEcmaArray encode the struct or map to amf0 ecma array, e.g. metadata.
+func MarshalEcmaArray(v interface{}) (data []uint8, err error) {
+	var objs []Amf0Object
+	if objs, err = MarshalObject(v); err != nil {
+		return
+	}
+
+	data = amf0WriteEcmaArray(amf0EcmaArray{
+		count:     uint32(len(objs)),
+		anyObject: objs,
+	})
+
+	return
+}
+
+// MarshalObject encode the struct or map to the properties of amf0 object,
+// e.g. the command object of connect packet.
+func MarshalObject(v interface{}) (objs []Amf0Object, err error) {
+	var value interface{}
+	var marker uint8
+	if value, marker, err = marshalValue(reflect.ValueOf(v), ""); err != nil {
+		return
+	}
+
+	if RtmpAmf0Object != marker {
+		err = &UnsupportedTypeError{Type: reflect.TypeOf(v)}
+		return
+	}
+
+	objs = value.([]Amf0Object)
+
+	return
+}
+
+// Unmarshal decode the amf0 value in data to v, which must be a non-nil pointer.
+// the amf0 object and ecma array can be decoded to struct or map.
+func Unmarshal(data []uint8, v interface{}) (err error) {
+	rv := reflect.ValueOf(v)
+	if reflect.Ptr != rv.Kind() || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+
+	var offset uint32
+	var value interface{}
+	var marker uint8
+	if value, err = amf0ReadAny(data, &marker, &offset, &amf0References{}); err != nil {
+		return
+	}
+
+	return unmarshalData(value, v)
+}
+
+// UnmarshalObject decode the properties of amf0 object to v,
+// which must be a non-nil pointer to struct or map.
+func UnmarshalObject(objs []Amf0Object, v interface{}) (err error) {
+	return unmarshalData(objs, v)
+}
+
+// unmarshalData decode the amf0 value decoded by packet to v.
+func unmarshalData(value interface{}, v interface{}) (err error) {
+	rv := reflect.ValueOf(v)
+	if reflect.Ptr != rv.Kind() || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+
+	return unmarshalValue(value, rv.Elem(), "")
+}
+
+// amfField the field of struct which is mapped to amf0 property.
+type amfField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// amfFields get the fields of struct, the embedded struct fields are flattened.
+func amfFields(t reflect.Type) (fields []amfField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("amf")
+		if "-" == tag {
+			continue
+		}
+
+		name := tag
+		var omitEmpty bool
+		if i := strings.Index(tag, ","); i >= 0 {
+			name = tag[:i]
+			omitEmpty = strings.Contains(tag[i+1:], "omitempty")
+		}
+
+		if f.Anonymous && 0 == len(name) && reflect.Struct == f.Type.Kind() {
+			for _, v := range amfFields(f.Type) {
+				v.index = append([]int{i}, v.index...)
+				fields = append(fields, v)
+			}
+			continue
+		}
+
+		// unexported
+		if 0 != len(f.PkgPath) {
+			continue
+		}
+
+		if 0 == len(name) {
+			name = f.Name
+		}
+
+		fields = append(fields, amfField{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: omitEmpty,
+		})
+	}
+
+	return
+}
+
+func fieldPath(parent string, name string) string {
+	if 0 == len(parent) {
+		return name
+	}
+
+	return parent + "." + name
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return 0 == v.Len()
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return 0 == v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return 0 == v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return 0 == v.Float()
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// marshalValue convert the go value to the amf0 value and marker.
+func marshalValue(v reflect.Value, path string) (value interface{}, marker uint8, err error) {
+	if !v.IsValid() {
+		return nil, RtmpAMF0Null, nil
+	}
+
+	// keep the amf0 values as is.
+	if v.CanInterface() {
+		switch t := v.Interface().(type) {
+		case Amf0Object:
+			return t.value, t.valueType, nil
+		case []Amf0Object:
+			return t, RtmpAmf0Object, nil
+		case Amf0TypedObject:
+			return t, RtmpAmf0TypedObject, nil
+		case Amf0XMLDocument:
+			return t, RtmpAmf0XmlDocument, nil
+		case Amf0Reference:
+			return t, RtmpAmf0Reference, nil
+		case time.Time:
+			return t, RtmpAmf0Date, nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, RtmpAMF0Null, nil
+		}
+		return marshalValue(v.Elem(), path)
+	case reflect.Bool:
+		return v.Bool(), RtmpAmf0Boolean, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), RtmpAmf0Number, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), RtmpAmf0Number, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), RtmpAmf0Number, nil
+	case reflect.String:
+		if v.Len() > 0xFFFF {
+			return v.String(), RtmpAmf0LongString, nil
+		}
+		return v.String(), RtmpAmf0String, nil
+	case reflect.Struct:
+		var objs []Amf0Object
+		for _, f := range amfFields(v.Type()) {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+
+			obj := Amf0Object{propertyName: f.name}
+			if obj.value, obj.valueType, err = marshalValue(fv, fieldPath(path, f.name)); err != nil {
+				return
+			}
+			objs = append(objs, obj)
+		}
+		return objs, RtmpAmf0Object, nil
+	case reflect.Map:
+		if reflect.String != v.Type().Key().Kind() {
+			return nil, 0, &UnsupportedTypeError{Field: path, Type: v.Type()}
+		}
+
+		// sort the keys to make the output stable.
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		var objs []Amf0Object
+		for _, k := range keys {
+			obj := Amf0Object{propertyName: k.String()}
+			if obj.value, obj.valueType, err = marshalValue(v.MapIndex(k), fieldPath(path, k.String())); err != nil {
+				return
+			}
+			objs = append(objs, obj)
+		}
+		return objs, RtmpAmf0Object, nil
+	case reflect.Slice, reflect.Array:
+		var arr amf0StrictArray
+		for i := 0; i < v.Len(); i++ {
+			var obj Amf0Object
+			if obj.value, obj.valueType, err = marshalValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return
+			}
+			arr.anyObject = append(arr.anyObject, obj)
+		}
+		arr.count = uint32(len(arr.anyObject))
+		return arr, RtmpAmf0StrictArray, nil
+	}
+
+	return nil, 0, &UnsupportedTypeError{Field: path, Type: v.Type()}
+}
+
+// amfValueName the description of the decoded amf0 value, used by error.
+func amfValueName(value interface{}) string {
+	switch value.(type) {
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []Amf0Object:
+		return "object"
+	case amf0EcmaArray:
+		return "ecma array"
+	case amf0StrictArray:
+		return "strict array"
+	case Amf0TypedObject:
+		return "typed object"
+	case Amf0XMLDocument:
+		return "xml document"
+	case Amf0Reference:
+		return "reference"
+	case time.Time:
+		return "date"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+// unmarshalValue store the decoded amf0 value to the go value.
+func unmarshalValue(value interface{}, v reflect.Value, path string) (err error) {
+	// null and undefined, keep the go value as is.
+	if nil == value {
+		return
+	}
+
+	typeError := func() error {
+		return &UnmarshalTypeError{Field: path, Value: amfValueName(value), Type: v.Type()}
+	}
+
+	if reflect.Ptr == v.Kind() {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshalValue(value, v.Elem(), path)
+	}
+
+	if reflect.Interface == v.Kind() && 0 == v.NumMethod() {
+		v.Set(reflect.ValueOf(amfNaturalValue(value)))
+		return
+	}
+
+	switch t := value.(type) {
+	case float64:
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(t)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if t != math.Trunc(t) || v.OverflowInt(int64(t)) {
+				return typeError()
+			}
+			v.SetInt(int64(t))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if t < 0 || t != math.Trunc(t) || v.OverflowUint(uint64(t)) {
+				return typeError()
+			}
+			v.SetUint(uint64(t))
+		default:
+			return typeError()
+		}
+	case bool:
+		if reflect.Bool != v.Kind() {
+			return typeError()
+		}
+		v.SetBool(t)
+	case string:
+		if reflect.String != v.Kind() {
+			return typeError()
+		}
+		v.SetString(t)
+	case Amf0XMLDocument:
+		if reflect.String != v.Kind() {
+			return typeError()
+		}
+		v.SetString(string(t))
+	case []Amf0Object:
+		return unmarshalProperties(t, v, path, typeError)
+	case amf0EcmaArray:
+		return unmarshalProperties(t.anyObject, v, path, typeError)
+	case Amf0TypedObject:
+		return unmarshalProperties(t.Properties, v, path, typeError)
+	case amf0StrictArray:
+		switch v.Kind() {
+		case reflect.Slice:
+			s := reflect.MakeSlice(v.Type(), len(t.anyObject), len(t.anyObject))
+			for i, item := range t.anyObject {
+				if err = unmarshalValue(item.value, s.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return
+				}
+			}
+			v.Set(s)
+		case reflect.Array:
+			for i, item := range t.anyObject {
+				if i >= v.Len() {
+					break
+				}
+				if err = unmarshalValue(item.value, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return
+				}
+			}
+		default:
+			return typeError()
+		}
+	default:
+		rv := reflect.ValueOf(value)
+		if !rv.Type().AssignableTo(v.Type()) {
+			return typeError()
+		}
+		v.Set(rv)
+	}
+
+	return
+}
+
+func unmarshalProperties(objs []Amf0Object, v reflect.Value, path string, typeError func() error) (err error) {
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := amfFields(v.Type())
+		for _, o := range objs {
+			for _, f := range fields {
+				if o.propertyName != f.name {
+					continue
+				}
+
+				if err = unmarshalValue(o.value, v.FieldByIndex(f.index), fieldPath(path, f.name)); err != nil {
+					return
+				}
+				break
+			}
+		}
+	case reflect.Map:
+		if reflect.String != v.Type().Key().Kind() {
+			return typeError()
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+
+		for _, o := range objs {
+			ev := reflect.New(v.Type().Elem()).Elem()
+			if err = unmarshalValue(o.value, ev, fieldPath(path, o.propertyName)); err != nil {
+				return
+			}
+			v.SetMapIndex(reflect.ValueOf(o.propertyName).Convert(v.Type().Key()), ev)
+		}
+	default:
+		return typeError()
+	}
+
+	return
+}
+
+// amfNaturalValue convert the decoded amf0 value to the natural go value,
+// object, typed object and ecma array to map[string]interface{}, strict array to []interface{}.
+func amfNaturalValue(value interface{}) interface{} {
+	switch t := value.(type) {
+	case []Amf0Object:
+		m := make(map[string]interface{})
+		for _, o := range t {
+			m[o.propertyName] = amfNaturalValue(o.value)
+		}
+		return m
+	case amf0EcmaArray:
+		return amfNaturalValue(t.anyObject)
+	case Amf0TypedObject:
+		return amfNaturalValue(t.Properties)
+	case Amf0XMLDocument:
+		return string(t)
+	case amf0StrictArray:
+		var s []interface{}
+		for _, item := range t.anyObject {
+			s = append(s, amfNaturalValue(item.value))
+		}
+		return s
+	}
+
+	return value
+}
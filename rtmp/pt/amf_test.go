@@ -0,0 +1,286 @@
+package pt
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// roundTrip encode the amf0 value, then decode and encode it again, which must be the same.
+func roundTrip(t *testing.T, value interface{}, marker uint8) (v interface{}, m uint8) {
+	data := amf0WriteAny(Amf0Object{value: value, valueType: marker})
+
+	var offset uint32
+	v, err := amf0ReadAny(data, &m, &offset, &amf0References{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if int(offset) != len(data) {
+		t.Fatalf("decode not completed, offset=%d, len=%d", offset, len(data))
+	}
+
+	if m != marker {
+		t.Fatalf("marker mismatch, got=%d, expect=%d", m, marker)
+	}
+
+	if again := amf0WriteAny(Amf0Object{value: v, valueType: m}); !bytes.Equal(data, again) {
+		t.Fatalf("encode mismatch\n%x\n%x", data, again)
+	}
+
+	return
+}
+
+func TestAmf0Date(t *testing.T) {
+	now := time.Unix(1500000000, 123*int64(time.Millisecond)).UTC()
+
+	v, _ := roundTrip(t, now, RtmpAmf0Date)
+	if !v.(time.Time).Equal(now) {
+		t.Fatal("date mismatch, got=", v)
+	}
+
+	var out time.Time
+	if err := Unmarshal(amf0WriteDate(now), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(now) {
+		t.Fatal("unmarshal date mismatch, got=", out)
+	}
+}
+
+func TestAmf0TypedObject(t *testing.T) {
+	obj := Amf0TypedObject{
+		ClassName: "Stream",
+		Properties: []Amf0Object{
+			*NewAmf0Object("name", "live", RtmpAmf0String),
+			*NewAmf0Object("id", 1.0, RtmpAmf0Number),
+		},
+	}
+
+	v, _ := roundTrip(t, obj, RtmpAmf0TypedObject)
+	if !reflect.DeepEqual(v, obj) {
+		t.Fatal("typed object mismatch, got=", v)
+	}
+
+	var out struct {
+		Name string `amf:"name"`
+		ID   int    `amf:"id"`
+	}
+	if err := Unmarshal(amf0WriteTypedObject(obj), &out); err != nil {
+		t.Fatal(err)
+	}
+	if "live" != out.Name || 1 != out.ID {
+		t.Fatal("unmarshal typed object mismatch, got=", out)
+	}
+}
+
+func TestAmf0XMLDocument(t *testing.T) {
+	doc := Amf0XMLDocument("<stream><name>live</name></stream>")
+
+	v, _ := roundTrip(t, doc, RtmpAmf0XmlDocument)
+	if v.(Amf0XMLDocument) != doc {
+		t.Fatal("xml document mismatch, got=", v)
+	}
+
+	var out string
+	if err := Unmarshal(amf0WriteXMLDocument(doc), &out); err != nil {
+		t.Fatal(err)
+	}
+	if string(doc) != out {
+		t.Fatal("unmarshal xml document mismatch, got=", out)
+	}
+}
+
+func TestAmf0Reference(t *testing.T) {
+	inner := []Amf0Object{
+		*NewAmf0Object("w", 2.0, RtmpAmf0Number),
+	}
+
+	// the outer object is the 0th complex object, the inner is the 1st.
+	outer := []Amf0Object{
+		*NewAmf0Object("a", inner, RtmpAmf0Object),
+		*NewAmf0Object("b", Amf0Reference(1), RtmpAmf0Reference),
+	}
+	data := amf0WriteObject(outer)
+
+	var m uint8
+	var offset uint32
+	v, err := amf0ReadAny(data, &m, &offset, &amf0References{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := v.([]Amf0Object)[1]
+	if RtmpAmf0Object != b.valueType || !reflect.DeepEqual(b.value, inner) {
+		t.Fatal("reference not resolved, got=", b)
+	}
+
+	var out struct {
+		A struct {
+			W int `amf:"w"`
+		} `amf:"a"`
+		B struct {
+			W int `amf:"w"`
+		} `amf:"b"`
+	}
+	if err = Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if 2 != out.A.W || 2 != out.B.W {
+		t.Fatal("unmarshal reference mismatch, got=", out)
+	}
+
+	// the reference is written as is.
+	if again := amf0WriteAny(*NewAmf0Object("", Amf0Reference(1), RtmpAmf0Reference)); !bytes.Equal(again, []byte{RtmpAmf0Reference, 0, 1}) {
+		t.Fatalf("encode reference mismatch, got=%x", again)
+	}
+
+	// no complex object is referred.
+	outer[1] = *NewAmf0Object("b", Amf0Reference(2), RtmpAmf0Reference)
+	if err = Unmarshal(amf0WriteObject(outer), &out); nil == err {
+		t.Fatal("reference out of range should fail")
+	}
+}
+
+func TestAmf0Unsupported(t *testing.T) {
+	obj := []Amf0Object{
+		*NewAmf0Object("u", nil, RtmpAmf0UnSupported),
+		*NewAmf0Object("n", 1.0, RtmpAmf0Number),
+	}
+
+	v, _ := roundTrip(t, obj, RtmpAmf0Object)
+
+	u := v.([]Amf0Object)[0]
+	if RtmpAmf0UnSupported != u.valueType || nil != u.value {
+		t.Fatal("unsupported mismatch, got=", u)
+	}
+}
+
+func TestAmf3ArrayToAmf0(t *testing.T) {
+	avmplus := func(v interface{}) (value interface{}, marker uint8) {
+		data, err := Amf3WriteAny(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var offset uint32
+		if value, marker, err = amf0ReadAvmplus(append([]byte{RtmpAmf0AVMplusObject}, data...), &offset); err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	// the dense only array is strict array.
+	v, m := avmplus(&Amf3Array{Dense: []interface{}{"a", 1.5}})
+	if RtmpAmf0StrictArray != m {
+		t.Fatal("dense array should be strict array, marker=", m)
+	}
+
+	var dense []interface{}
+	if err := unmarshalData(v, &dense); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dense, []interface{}{"a", 1.5}) {
+		t.Fatal("strict array mismatch, got=", dense)
+	}
+
+	// the associative array is ecma array, the dense are keyed by index.
+	v, m = avmplus(&Amf3Array{
+		Assoc: []Amf3Property{{Name: "name", Value: "live"}},
+		Dense: []interface{}{"a"},
+	})
+	if RtmpAmf0EcmaArray != m {
+		t.Fatal("associative array should be ecma array, marker=", m)
+	}
+
+	var assoc map[string]interface{}
+	if err := unmarshalData(v, &assoc); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(assoc, map[string]interface{}{"0": "a", "name": "live"}) {
+		t.Fatal("ecma array mismatch, got=", assoc)
+	}
+}
+
+func TestOnStatusMarshal(t *testing.T) {
+	var pkt OnStatusCallPacket
+	pkt.CommandName = RtmpAmf0CommandOnStatus
+	if err := pkt.Marshal(&StatusInfo{
+		Level:       StatusLevelStatus,
+		Code:        StatusCodeStreamStart,
+		Description: "Started playing stream.",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var p OnStatusCallPacket
+	if err := p.Decode(pkt.Encode()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the empty details and clientid are omitted.
+	if 3 != len(p.Data) {
+		t.Fatalf("properties=%d, expect 3", len(p.Data))
+	}
+
+	var info StatusInfo
+	if err := p.Unmarshal(&info); err != nil {
+		t.Fatal(err)
+	}
+
+	if StatusLevelStatus != info.Level || StatusCodeStreamStart != info.Code || "Started playing stream." != info.Description {
+		t.Fatalf("unmarshal %+v", info)
+	}
+
+	// the code of wrong type is an error, not panic.
+	p.Data = append(p.Data, *NewAmf0Object(StatusCode, 1.0, RtmpAmf0Number))
+	if err, ok := p.Unmarshal(&info).(*UnmarshalTypeError); !ok || StatusCode != err.Field {
+		t.Fatalf("unmarshal code of number, err=%v", err)
+	}
+}
+
+func TestOnMetaDataProperties(t *testing.T) {
+	type meta struct {
+		Width     float64 `amf:"width"`
+		FrameRate float64 `amf:"framerate"`
+		Server    string  `amf:"server"`
+	}
+
+	for _, marker := range []uint8{RtmpAmf0Object, RtmpAmf0EcmaArray} {
+		var data []uint8
+		var err error
+		if RtmpAmf0Object == marker {
+			data, err = Marshal(map[string]float64{"width": 640, "framerate": 25})
+		} else {
+			data, err = MarshalEcmaArray(map[string]float64{"width": 640, "framerate": 25})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var pkt OnMetaDataPacket
+		if err = pkt.Decode(append(amf0WriteString(RtmpAmf0DataOnMetaData), data...)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = pkt.AddProperties(map[string]string{"server": "seal"}); err != nil {
+			t.Fatal(err)
+		}
+
+		var p OnMetaDataPacket
+		if err = p.Decode(pkt.Encode()); err != nil {
+			t.Fatal(err)
+		}
+
+		var m meta
+		if err = p.Unmarshal(&m); err != nil {
+			t.Fatal(err)
+		}
+
+		if marker != p.Marker || 640 != m.Width || 25 != m.FrameRate || "seal" != m.Server {
+			t.Fatalf("marker=%d, metadata %+v", p.Marker, m)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package pt
 
+import "seal/kernel"
+
 // MessageHeader message header.
 type MessageHeader struct {
 	// 3bytes.
@@ -144,10 +146,87 @@ type MessagePayload struct {
 	// not all message Payload can be decoded to packet. for example,
 	// video/audio packet use raw bytes, no video/audio packet.
 	Payload []uint8
+
+	// Buf the buffer from pool which Payload is allocated from, nil if not from pool.
+	Buf *kernel.Buffer
 }
 
 // Message message is raw data RTMP message, bytes oriented
 type Message struct {
 	Header  MessageHeader
 	Payload MessagePayload
+
+	// Chunked the payload split to chunks when published, shared by all players, nil if not chunked.
+	Chunked *ChunkedPayload
+}
+
+// Retain add a reference to the payload buffer from pool,
+// who keeps the msg after handled must retain it, e.g. the gop cache.
+func (msg *Message) Retain() {
+	msg.Payload.Buf.Retain()
+}
+
+// Release release a reference to the payload buffer from pool,
+// the payload and chunks must not be used any more after the last reference released.
+func (msg *Message) Release() {
+	msg.Payload.Buf.Release()
+}
+
+// ChunkedPayload the payload of msg split to chunks for the chunk size and csid,
+// the payload of first chunk, then the fmt3 header and payload of the other chunks.
+// the payload is not copied, and the fmt3 header has no extended timestamp,
+// so it's only for the msg sent with timestamp less than RtmpExtendTimeStamp.
+type ChunkedPayload struct {
+	ChunkSize uint32
+	Csid      uint32
+	Bufs      [][]byte
+}
+
+// Chunk split the payload of msg to chunks for the chunk size and csid.
+func (msg *Message) Chunk(chunkSize uint32, csid uint32) *ChunkedPayload {
+	c := &ChunkedPayload{
+		ChunkSize: chunkSize,
+		Csid:      csid,
+	}
+
+	payload := msg.Payload.Payload[:msg.Header.PayloadLength]
+	n := (len(payload) + int(chunkSize) - 1) / int(chunkSize)
+	c.Bufs = make([][]byte, 0, 2*n)
+
+	// the fmt3 header, if perfer_cid > 0x3F, rollback to 1B chunk header.
+	fmt3 := []byte{0xc0 | uint8(csid&0x3f)}
+
+	for offset := 0; offset < len(payload); offset += int(chunkSize) {
+		end := offset + int(chunkSize)
+		if end > len(payload) {
+			end = len(payload)
+		}
+
+		if offset > 0 {
+			c.Bufs = append(c.Bufs, fmt3)
+		}
+		c.Bufs = append(c.Bufs, payload[offset:end])
+	}
+
+	return c
+}
+
+// ConvertAmf3ToAmf0 convert the amf3 command/data msg to amf0 msg,
+// the payload of amf3 msg is a 0x00 byte followed by amf0 values,
+// and the amf3 values in it are marked by avmplus marker, which can be decoded by amf0.
+func (msg *Message) ConvertAmf3ToAmf0() {
+	if !msg.Header.IsAmf3Command() && !msg.Header.IsAmf3Data() {
+		return
+	}
+
+	if len(msg.Payload.Payload) > 0 && 0x00 == msg.Payload.Payload[0] {
+		msg.Payload.Payload = msg.Payload.Payload[1:]
+		msg.Header.PayloadLength = uint32(len(msg.Payload.Payload))
+	}
+
+	if msg.Header.IsAmf3Command() {
+		msg.Header.MessageType = RtmpMsgAmf0CommandMessage
+	} else {
+		msg.Header.MessageType = RtmpMsgAmf0DataMessage
+	}
 }
@@ -22,6 +22,7 @@ type BandWidthPacket struct {
 // Decode .
 func (pkt *BandWidthPacket) Decode(data []uint8) (err error) {
 	var offset uint32
+	var refs amf0References
 
 	if pkt.CommandName, err = Amf0ReadString(data, &offset); err != nil {
 		return
@@ -36,7 +37,7 @@ func (pkt *BandWidthPacket) Decode(data []uint8) (err error) {
 	}
 
 	if pkt.isStopPlay() || pkt.isStopPublish() || pkt.isFinish() {
-		pkt.Data, err = amf0ReadObject(data, &offset)
+		pkt.Data, err = amf0ReadObject(data, &offset, &refs)
 		if err != nil {
 			return
 		}
@@ -28,6 +28,7 @@ type CallPacket struct {
 // Decode .
 func (pkt *CallPacket) Decode(data []uint8) (err error) {
 	var offset uint32
+	var refs amf0References
 
 	if pkt.CommandName, err = Amf0ReadString(data, &offset); err != nil {
 		return
@@ -37,12 +38,12 @@ func (pkt *CallPacket) Decode(data []uint8) (err error) {
 		return
 	}
 
-	if pkt.CommandObject, err = amf0ReadAny(data, &pkt.CmdObjectType, &offset); err != nil {
+	if pkt.CommandObject, err = amf0ReadAny(data, &pkt.CmdObjectType, &offset, &refs); err != nil {
 		return
 	}
 
 	if uint32(len(data))-offset > 0 {
-		pkt.Arguments, err = amf0ReadAny(data, &pkt.ArgumentsType, &offset)
+		pkt.Arguments, err = amf0ReadAny(data, &pkt.ArgumentsType, &offset, &refs)
 		if err != nil {
 			return
 		}
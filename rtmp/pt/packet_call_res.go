@@ -24,6 +24,7 @@ type CallResPacket struct {
 // Decode .
 func (pkt *CallResPacket) Decode(data []uint8) (err error) {
 	var offset uint32
+	var refs amf0References
 
 	if pkt.CommandName, err = Amf0ReadString(data, &offset); err != nil {
 		return
@@ -33,13 +34,13 @@ func (pkt *CallResPacket) Decode(data []uint8) (err error) {
 		return
 	}
 
-	if pkt.CommandObject, err = amf0ReadAny(data, &pkt.CommandObjectMarker, &offset); err != nil {
+	if pkt.CommandObject, err = amf0ReadAny(data, &pkt.CommandObjectMarker, &offset, &refs); err != nil {
 		return
 	}
 
 	maxOffset := uint32(len(data)) - 1
 	if maxOffset-offset > 0 {
-		pkt.Response, err = amf0ReadAny(data, &pkt.ResponseMarker, &offset)
+		pkt.Response, err = amf0ReadAny(data, &pkt.ResponseMarker, &offset, &refs)
 		if err != nil {
 			return
 		}
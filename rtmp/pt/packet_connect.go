@@ -31,9 +31,15 @@ func (pkt *ConnectPacket) GetObjectProperty(name string) (value interface{}) {
 	return
 }
 
+// AddObj add object to command object
+func (pkt *ConnectPacket) AddObj(obj *Amf0Object) {
+	pkt.CommandObject = append(pkt.CommandObject, *obj)
+}
+
 // Decode .
 func (pkt *ConnectPacket) Decode(data []uint8) (err error) {
 	var offset uint32
+	var refs amf0References
 
 	if pkt.CommandName, err = Amf0ReadString(data, &offset); err != nil {
 		return
@@ -53,14 +59,14 @@ func (pkt *ConnectPacket) Decode(data []uint8) (err error) {
 		return
 	}
 
-	if pkt.CommandObject, err = amf0ReadObject(data, &offset); err != nil {
+	if pkt.CommandObject, err = amf0ReadObject(data, &offset, &refs); err != nil {
 		return
 	}
 
 	if uint32(len(data))-offset > 0 {
 		var marker uint8
 		var v interface{}
-		v, err = amf0ReadAny(data, &marker, &offset)
+		v, err = amf0ReadAny(data, &marker, &offset, &refs)
 		if err != nil {
 			return
 		}
@@ -24,6 +24,7 @@ type ConnectResPacket struct {
 // Decode .
 func (pkt *ConnectResPacket) Decode(data []uint8) (err error) {
 	var offset uint32
+	var refs amf0References
 
 	if pkt.CommandName, err = Amf0ReadString(data, &offset); err != nil {
 		return
@@ -44,11 +45,11 @@ func (pkt *ConnectResPacket) Decode(data []uint8) (err error) {
 		return
 	}
 
-	if pkt.Props, err = amf0ReadObject(data, &offset); err != nil {
+	if pkt.Props, err = amf0ReadObject(data, &offset, &refs); err != nil {
 		return
 	}
 
-	if pkt.Info, err = amf0ReadObject(data, &offset); err != nil {
+	if pkt.Info, err = amf0ReadObject(data, &offset, &refs); err != nil {
 		return
 	}
 
@@ -76,7 +77,19 @@ func (pkt *ConnectResPacket) GetPreferCsID() uint32 {
 	return RtmpCidOverConnection
 }
 
-// AddProsObj add object to pros
-func (pkt *ConnectResPacket) AddProsObj(obj *Amf0Object) {
-	pkt.Props = append(pkt.Props, *obj)
+// MarshalProps encode v to props, which is a struct or map.
+func (pkt *ConnectResPacket) MarshalProps(v interface{}) (err error) {
+	pkt.Props, err = MarshalObject(v)
+	return
+}
+
+// MarshalInfo encode v to info, which is a struct or map, e.g. StatusInfo.
+func (pkt *ConnectResPacket) MarshalInfo(v interface{}) (err error) {
+	pkt.Info, err = MarshalObject(v)
+	return
+}
+
+// UnmarshalInfo decode info to v, which is a pointer to struct or map, e.g. StatusInfo.
+func (pkt *ConnectResPacket) UnmarshalInfo(v interface{}) (err error) {
+	return UnmarshalObject(pkt.Info, v)
 }
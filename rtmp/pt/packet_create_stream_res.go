@@ -53,7 +53,7 @@ func (pkt *CreateStreamResPacket) Decode(data []uint8) (err error) {
 		return
 	}
 
-	if pkt.TransactionID, err = Amf0ReadNumber(data, &offset); err != nil {
+	if pkt.StreamID, err = Amf0ReadNumber(data, &offset); err != nil {
 		return
 	}
 
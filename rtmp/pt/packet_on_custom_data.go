@@ -16,18 +16,25 @@ type OnCustomDataPakcet struct {
 // Decode .
 func (pkt *OnCustomDataPakcet) Decode(data []uint8) (err error) {
 	var offset uint32
+	var refs amf0References
 
 	if pkt.Name, err = Amf0ReadString(data, &offset); err != nil {
 		return
 	}
 
-	if pkt.Customdata, err = amf0ReadAny(data, &pkt.Marker, &offset); err != nil {
+	if pkt.Customdata, err = amf0ReadAny(data, &pkt.Marker, &offset, &refs); err != nil {
 		return
 	}
 
 	return
 }
 
+// Unmarshal decode the custom data to v, which is a pointer to struct or map,
+// the struct fields are named by tag, e.g. `amf:"name"`.
+func (pkt *OnCustomDataPakcet) Unmarshal(v interface{}) (err error) {
+	return unmarshalData(pkt.Customdata, v)
+}
+
 // Encode .
 func (pkt *OnCustomDataPakcet) Encode() (data []uint8) {
 	data = append(data, amf0WriteString(pkt.Name)...)
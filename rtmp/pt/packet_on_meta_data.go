@@ -17,6 +17,7 @@ type OnMetaDataPacket struct {
 // Decode .
 func (pkt *OnMetaDataPacket) Decode(data []uint8) (err error) {
 	var offset uint32
+	var refs amf0References
 
 	if pkt.Name, err = Amf0ReadString(data, &offset); err != nil {
 		return
@@ -28,7 +29,7 @@ func (pkt *OnMetaDataPacket) Decode(data []uint8) (err error) {
 		}
 	}
 
-	if pkt.Metadata, err = amf0ReadAny(data, &pkt.Marker, &offset); err != nil {
+	if pkt.Metadata, err = amf0ReadAny(data, &pkt.Marker, &offset, &refs); err != nil {
 		return
 	}
 
@@ -57,34 +58,28 @@ func (pkt *OnMetaDataPacket) GetPreferCsID() uint32 {
 	return RtmpCidOverConnection2
 }
 
-// AddObject add object to objs
-func (pkt *OnMetaDataPacket) AddObject(obj Amf0Object) {
-	if RtmpAmf0Object == pkt.Marker {
-		pkt.Metadata = append(pkt.Metadata.([]Amf0Object), obj)
-	} else if RtmpAmf0EcmaArray == pkt.Marker {
-		v := pkt.Metadata.(amf0EcmaArray)
-		v.addObject(obj)
-
-		pkt.Metadata = v
+// AddProperties encode v and add to the properties of metadata, v is a struct or map.
+func (pkt *OnMetaDataPacket) AddProperties(v interface{}) (err error) {
+	var objs []Amf0Object
+	if objs, err = MarshalObject(v); err != nil {
+		return
 	}
-}
-
-// GetProperty get object property name
-func (pkt *OnMetaDataPacket) GetProperty(name string) interface{} {
 
-	if RtmpAmf0Object == pkt.Marker {
-		for _, v := range pkt.Metadata.([]Amf0Object) {
-			if name == v.propertyName {
-				return v.value
-			}
-		}
-	} else if RtmpAmf0EcmaArray == pkt.Marker {
-		for _, v := range (pkt.Metadata.(amf0EcmaArray)).anyObject {
-			if name == v.propertyName {
-				return v.value
-			}
+	switch m := pkt.Metadata.(type) {
+	case []Amf0Object:
+		pkt.Metadata = append(m, objs...)
+	case amf0EcmaArray:
+		for _, o := range objs {
+			m.addObject(o)
 		}
+		pkt.Metadata = m
 	}
 
-	return nil
+	return
+}
+
+// Unmarshal decode the metadata to v, which is a pointer to struct or map,
+// the struct fields are named by tag, e.g. `amf:"framerate"`.
+func (pkt *OnMetaDataPacket) Unmarshal(v interface{}) (err error) {
+	return unmarshalData(pkt.Metadata, v)
 }
@@ -1,5 +1,16 @@
 package pt
 
+import "fmt"
+
+// StatusInfo the info of onStatus and connect response, the name-value pairs that describe the response.
+type StatusInfo struct {
+	Level       string `amf:"level"`
+	Code        string `amf:"code"`
+	Description string `amf:"description,omitempty"`
+	Details     string `amf:"details,omitempty"`
+	ClientID    string `amf:"clientid,omitempty"`
+}
+
 // OnStatusCallPacket onStatus command, AMF0 Call
 // user must set the stream id
 type OnStatusCallPacket struct {
@@ -20,7 +31,30 @@ type OnStatusCallPacket struct {
 
 // Decode .
 func (pkt *OnStatusCallPacket) Decode(data []uint8) (err error) {
-	//nothing
+	var offset uint32
+	var refs amf0References
+
+	if pkt.CommandName, err = Amf0ReadString(data, &offset); err != nil {
+		return
+	}
+
+	if RtmpAmf0CommandOnStatus != pkt.CommandName {
+		err = fmt.Errorf("decode onStatus call packet, command name is not onStatus.actully=%s", pkt.CommandName)
+		return
+	}
+
+	if pkt.TransactionID, err = Amf0ReadNumber(data, &offset); err != nil {
+		return
+	}
+
+	if err = amf0ReadNull(data, &offset); err != nil {
+		return
+	}
+
+	if pkt.Data, err = amf0ReadObject(data, &offset, &refs); err != nil {
+		return
+	}
+
 	return
 }
 
@@ -44,7 +78,13 @@ func (pkt *OnStatusCallPacket) GetPreferCsID() uint32 {
 	return RtmpCidOverStream
 }
 
-// AddObj add object to data
-func (pkt *OnStatusCallPacket) AddObj(obj *Amf0Object) {
-	pkt.Data = append(pkt.Data, *obj)
+// Marshal encode v to data, which is a struct or map, e.g. StatusInfo.
+func (pkt *OnStatusCallPacket) Marshal(v interface{}) (err error) {
+	pkt.Data, err = MarshalObject(v)
+	return
+}
+
+// Unmarshal decode data to v, which is a pointer to struct or map, e.g. StatusInfo.
+func (pkt *OnStatusCallPacket) Unmarshal(v interface{}) (err error) {
+	return UnmarshalObject(pkt.Data, v)
 }
@@ -1,5 +1,7 @@
 package pt
 
+import "fmt"
+
 // OnStatusDataPacket onStatus data, AMF0 Data
 // user must set the stream id
 type OnStatusDataPacket struct {
@@ -14,7 +16,22 @@ type OnStatusDataPacket struct {
 
 // Decode .
 func (pkt *OnStatusDataPacket) Decode(data []uint8) (err error) {
-	//nothing
+	var offset uint32
+	var refs amf0References
+
+	if pkt.CommandName, err = Amf0ReadString(data, &offset); err != nil {
+		return
+	}
+
+	if RtmpAmf0CommandOnStatus != pkt.CommandName {
+		err = fmt.Errorf("decode onStatus data packet, command name is not onStatus.actully=%s", pkt.CommandName)
+		return
+	}
+
+	if pkt.Data, err = amf0ReadObject(data, &offset, &refs); err != nil {
+		return
+	}
+
 	return
 }
 
@@ -36,7 +53,13 @@ func (pkt *OnStatusDataPacket) GetPreferCsID() uint32 {
 	return RtmpCidOverStream
 }
 
-// AddObj add object to Data
-func (pkt *OnStatusDataPacket) AddObj(obj *Amf0Object) {
-	pkt.Data = append(pkt.Data, *obj)
+// Marshal encode v to data, which is a struct or map, e.g. StatusInfo.
+func (pkt *OnStatusDataPacket) Marshal(v interface{}) (err error) {
+	pkt.Data, err = MarshalObject(v)
+	return
+}
+
+// Unmarshal decode data to v, which is a pointer to struct or map, e.g. StatusInfo.
+func (pkt *OnStatusDataPacket) Unmarshal(v interface{}) (err error) {
+	return UnmarshalObject(pkt.Data, v)
 }
@@ -63,10 +63,8 @@ type PlayPacket struct {
 
 // Decode .
 func (pkt *PlayPacket) Decode(data []uint8) (err error) {
-	var maxOffset uint32
-	maxOffset = uint32(len(data)) - 1
-
 	var offset uint32
+	var refs amf0References
 
 	if pkt.CommandName, err = Amf0ReadString(data, &offset); err != nil {
 		return
@@ -98,27 +96,24 @@ func (pkt *PlayPacket) Decode(data []uint8) (err error) {
 		pkt.TokenStr = streamNameLocal[i+len(TokenStr):]
 	}
 
-	if maxOffset-offset > (1 + 8) { // number need at least 1(marker) + 8(number)
+	// the optional start, duration and reset,
+	// the number may be amf3 integer which is shorter than amf0 number.
+	if offset < uint32(len(data)) {
 		if pkt.Start, err = Amf0ReadNumber(data, &offset); err != nil {
 			return
 		}
 	}
 
-	if maxOffset-offset > (1 + 8) {
+	if offset < uint32(len(data)) {
 		if pkt.Duration, err = Amf0ReadNumber(data, &offset); err != nil {
 			return
 		}
 	}
 
-	if offset >= uint32(len(data)) {
-		return
-	}
-
-	if maxOffset-offset >= 2 { //because the bool type need 2 bytes at least
-
+	if offset < uint32(len(data)) {
 		var v interface{}
 		var marker uint8
-		if v, err = amf0ReadAny(data, &marker, &offset); err != nil {
+		if v, err = amf0ReadAny(data, &marker, &offset, &refs); err != nil {
 			return
 		}
 
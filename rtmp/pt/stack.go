@@ -231,6 +231,46 @@ const (
 	RtmpAmf0Invalid = 0x3F
 )
 
+// AMF3 marker
+const (
+	// RtmpAmf3Undefined .
+	RtmpAmf3Undefined = 0x00
+	// RtmpAmf3Null .
+	RtmpAmf3Null = 0x01
+	// RtmpAmf3False .
+	RtmpAmf3False = 0x02
+	// RtmpAmf3True .
+	RtmpAmf3True = 0x03
+	// RtmpAmf3Integer U29 integer, in range [-2^28, 2^28-1]
+	RtmpAmf3Integer = 0x04
+	// RtmpAmf3Double .
+	RtmpAmf3Double = 0x05
+	// RtmpAmf3String .
+	RtmpAmf3String = 0x06
+	// RtmpAmf3XMLDocument legacy flash.xml.XMLDocument
+	RtmpAmf3XMLDocument = 0x07
+	// RtmpAmf3Date .
+	RtmpAmf3Date = 0x08
+	// RtmpAmf3Array .
+	RtmpAmf3Array = 0x09
+	// RtmpAmf3Object .
+	RtmpAmf3Object = 0x0A
+	// RtmpAmf3XML E4X XML
+	RtmpAmf3XML = 0x0B
+	// RtmpAmf3ByteArray .
+	RtmpAmf3ByteArray = 0x0C
+	// RtmpAmf3VectorInt .
+	RtmpAmf3VectorInt = 0x0D
+	// RtmpAmf3VectorUint .
+	RtmpAmf3VectorUint = 0x0E
+	// RtmpAmf3VectorDouble .
+	RtmpAmf3VectorDouble = 0x0F
+	// RtmpAmf3VectorObject .
+	RtmpAmf3VectorObject = 0x10
+	// RtmpAmf3Dictionary .
+	RtmpAmf3Dictionary = 0x11
+)
+
 const (
 	// RtmpAmf0CommandConnect .
 	RtmpAmf0CommandConnect = "connect"
@@ -306,10 +346,16 @@ const (
 	StatusCodeStreamUnpause = "NetStream.Unpause.Notify"
 	// StatusCodePublishStart .
 	StatusCodePublishStart = "NetStream.Publish.Start"
+	// StatusCodePublishBadName .
+	StatusCodePublishBadName = "NetStream.Publish.BadName"
+	// StatusCodePlayFailed .
+	StatusCodePlayFailed = "NetStream.Play.Failed"
 	// StatusCodeDataStart .
 	StatusCodeDataStart = "NetStream.Data.Start"
 	// StatusCodeUnpublishSuccess .
 	StatusCodeUnpublishSuccess = "NetStream.Unpublish.Success"
+	// StatusCodeStreamStop .
+	StatusCodeStreamStop = "NetStream.Play.Stop"
 
 	// FMLE
 
@@ -647,6 +693,12 @@ const (
 	RtmpRoleFlashPublisher = 2
 	// RtmpRolePlayer role player
 	RtmpRolePlayer = 3
+	// RtmpRolePuller role puller, pull stream from remote server and publish to local
+	RtmpRolePuller = 4
+	// RtmpRoleEdge role edge, pull stream from origin on demand when played
+	RtmpRoleEdge = 5
+	// RtmpRoleForwarder role forwarder, forward the stream published to local to remote server
+	RtmpRoleForwarder = 6
 )
 
 const (
@@ -22,10 +22,17 @@ func (tj *TimeJitter) Correct(msg *Message, tba float64, tbv float64, timeJitter
 		return
 	}
 
+	msg.Header.Timestamp = tj.CorrectTimestamp(msg, tba, tbv, timeJitter)
+}
+
+// CorrectTimestamp detect the time jitter and return the corrected timestamp of msg,
+// the msg is not changed, so the msg shared by consumers can be corrected by each consumer.
+func (tj *TimeJitter) CorrectTimestamp(msg *Message, tba float64, tbv float64, timeJitter uint32) uint64 {
+
 	if RtmpTimeJitterFull != timeJitter {
 		// all jitter correct features is disabled, ignore.
 		if RtmpTimeJitterOff == timeJitter {
-			return
+			return msg.Header.Timestamp
 		}
 
 		// start at zero, but donot ensure monotonically increasing.
@@ -37,17 +44,14 @@ func (tj *TimeJitter) Correct(msg *Message, tba float64, tbv float64, timeJitter
 				tj.lastPktCorrectTime = int64(msg.Header.Timestamp)
 			}
 
-			msg.Header.Timestamp -= uint64(tj.lastPktCorrectTime)
-
-			return
+			return msg.Header.Timestamp - uint64(tj.lastPktCorrectTime)
 		}
 	}
 
 	// full jitter algorithm, do jitter correct.
 	// set to 0 for metadata.
 	if !msg.Header.IsAudio() && !msg.Header.IsVideo() {
-		msg.Header.Timestamp = 0
-		return
+		return 0
 	}
 
 	sampleRate := tba
@@ -89,7 +93,7 @@ func (tj *TimeJitter) Correct(msg *Message, tba float64, tbv float64, timeJitter
 		tj.lastPktCorrectTime = 0
 	}
 
-	msg.Header.Timestamp = uint64(tj.lastPktCorrectTime)
 	tj.lastPktTime = int64(timeLocal)
 
+	return uint64(tj.lastPktCorrectTime)
 }
@@ -0,0 +1,103 @@
+package rtmpt
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/calabashdad/utiltools"
+)
+
+// clientPollInterval the unit of interval to poll the server when no data.
+const clientPollInterval = 10 * time.Millisecond
+
+// Dial open a rtmpt session to the server, e.g. http://127.0.0.1:7001,
+// return a virtual connection which can be used as rtmp connection.
+func Dial(server string) (c net.Conn, err error) {
+	server = strings.TrimRight(server, "/")
+
+	var data []byte
+	if data, err = post(server+"/open/1", nil); err != nil {
+		return
+	}
+
+	id := strings.TrimSpace(string(data))
+	if 0 == len(id) {
+		err = fmt.Errorf("rtmpt open session failed, no session id")
+		return
+	}
+
+	tc := newConn(id, "rtmpt-client", server)
+	go clientCycle(server, tc)
+
+	c = tc
+
+	return
+}
+
+// clientCycle send the buffered data to server, and poll the data from server.
+func clientCycle(server string, c *conn) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Println(utiltools.PanicTrace())
+		}
+
+		c.Close()
+	}()
+
+	var seq int
+	for {
+		seq++
+
+		if c.isClosed() {
+			post(fmt.Sprintf("%s/close/%s/%d", server, c.id, seq), nil)
+			return
+		}
+
+		cmd := "idle"
+		out := c.drain()
+		if len(out) > 0 {
+			cmd = "send"
+		}
+
+		data, err := post(fmt.Sprintf("%s/%s/%s/%d", server, cmd, c.id, seq), out)
+		if err != nil {
+			log.Println("rtmpt client poll failed, err=", err)
+			return
+		}
+
+		if len(data) < 1 {
+			log.Println("rtmpt client poll failed, no interval in response")
+			return
+		}
+
+		if len(data) > 1 {
+			c.feed(data[1:])
+			continue
+		}
+
+		if 0 == len(out) {
+			time.Sleep(time.Duration(data[0]) * clientPollInterval)
+		}
+	}
+}
+
+func post(url string, body []byte) (data []byte, err error) {
+	var resp *http.Response
+	if resp, err = http.Post(url, contentType, bytes.NewReader(body)); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if http.StatusOK != resp.StatusCode {
+		err = fmt.Errorf("rtmpt request failed, url=%s, status=%d", url, resp.StatusCode)
+		return
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
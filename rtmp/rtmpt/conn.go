@@ -0,0 +1,301 @@
+package rtmpt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// maxBufferedBytes the max bytes buffered to send to peer, block the writer when exceed,
+	// until the peer polls them out. and the max bytes come from peer not read yet.
+	maxBufferedBytes = 4 * 1024 * 1024
+	// seqWindow the count of sequences before the highest which are remembered,
+	// the older are rejected as replayed.
+	seqWindow = 64
+)
+
+var (
+	errConnClosed = errors.New("rtmpt connection closed")
+	errBufferFull = errors.New("rtmpt buffer full")
+)
+
+// timeoutError the error returned when deadline exceeded.
+type timeoutError struct{}
+
+func (e timeoutError) Error() string   { return "rtmpt i/o timeout" }
+func (e timeoutError) Timeout() bool   { return true }
+func (e timeoutError) Temporary() bool { return true }
+
+// addr the address of rtmpt connection.
+type addr string
+
+func (a addr) Network() string { return "rtmpt" }
+func (a addr) String() string  { return string(a) }
+
+// conn a virtual net.Conn bridging the polled http requests,
+// the data in request bodies are read by Read,
+// and the data written by Write are carried by the responses.
+type conn struct {
+	id     string
+	local  net.Addr
+	remote net.Addr
+
+	mu            sync.Mutex
+	in            bytes.Buffer
+	out           bytes.Buffer
+	readDeadline  time.Time
+	writeDeadline time.Time
+	lastActive    time.Time
+	// interval the polling interval hint told to client, larger is slower.
+	interval uint8
+	// seq the highest sequence of the requests of session, valid if seqStarted.
+	// seqSeen the sequences seen before the highest, bit n is seq-n.
+	seq        uint64
+	seqSeen    uint64
+	seqStarted bool
+
+	// inNotify notified when data come in.
+	inNotify chan struct{}
+	// outNotify notified when data sent out.
+	outNotify chan struct{}
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newConn(id string, local string, remote string) *conn {
+	return &conn{
+		id:         id,
+		local:      addr(local),
+		remote:     addr(remote),
+		lastActive: time.Now(),
+		interval:   1,
+		inNotify:   make(chan struct{}, 1),
+		outNotify:  make(chan struct{}, 1),
+		closed:     make(chan struct{}),
+	}
+}
+
+// Read read the data come from peer.
+func (c *conn) Read(b []byte) (n int, err error) {
+	for {
+		c.mu.Lock()
+		if c.in.Len() > 0 {
+			n, err = c.in.Read(b)
+			c.mu.Unlock()
+			return
+		}
+		deadline := c.readDeadline
+		c.mu.Unlock()
+
+		if err = c.wait(c.inNotify, deadline); err != nil {
+			if errConnClosed == err {
+				err = io.EOF
+			}
+			return
+		}
+	}
+}
+
+// Write buffer the data, which will be sent to peer by the next poll.
+func (c *conn) Write(b []byte) (n int, err error) {
+	for {
+		if c.isClosed() {
+			err = errConnClosed
+			return
+		}
+
+		c.mu.Lock()
+		if c.out.Len() < maxBufferedBytes {
+			n, err = c.out.Write(b)
+			c.mu.Unlock()
+			return
+		}
+		deadline := c.writeDeadline
+		c.mu.Unlock()
+
+		if err = c.wait(c.outNotify, deadline); err != nil {
+			return
+		}
+	}
+}
+
+// Close close the connection, the blocked Read and Write will return.
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+
+	return nil
+}
+
+// LocalAddr .
+func (c *conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr .
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline .
+func (c *conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readDeadline = t
+	c.writeDeadline = t
+
+	return nil
+}
+
+// SetReadDeadline .
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readDeadline = t
+
+	return nil
+}
+
+// SetWriteDeadline .
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeDeadline = t
+
+	return nil
+}
+
+func (c *conn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait wait for notify until deadline or closed.
+func (c *conn) wait(notify chan struct{}, deadline time.Time) error {
+	var timeout <-chan time.Time
+
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return timeoutError{}
+		}
+
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		timeout = t.C
+	}
+
+	select {
+	case <-notify:
+		return nil
+	case <-timeout:
+		return timeoutError{}
+	case <-c.closed:
+		return errConnClosed
+	}
+}
+
+// feed the data come from peer, errBufferFull if the data not read exceed maxBufferedBytes.
+func (c *conn) feed(data []byte) (err error) {
+	c.mu.Lock()
+	if c.in.Len()+len(data) > maxBufferedBytes {
+		c.mu.Unlock()
+		return errBufferFull
+	}
+	c.in.Write(data)
+	c.lastActive = time.Now()
+	c.mu.Unlock()
+
+	select {
+	case c.inNotify <- struct{}{}:
+	default:
+	}
+
+	return
+}
+
+// drain take all the data to send to peer.
+func (c *conn) drain() (data []byte) {
+	c.mu.Lock()
+	if c.out.Len() > 0 {
+		data = make([]byte, c.out.Len())
+		c.out.Read(data)
+	}
+	c.lastActive = time.Now()
+	c.mu.Unlock()
+
+	select {
+	case c.outNotify <- struct{}{}:
+	default:
+	}
+
+	return
+}
+
+// nextInterval the polling interval for client,
+// poll slower when no data, and reset when data come.
+func (c *conn) nextInterval(hasData bool) uint8 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if hasData {
+		c.interval = 1
+	} else if c.interval < maxInterval {
+		c.interval++
+	}
+
+	return c.interval
+}
+
+// acceptSeq accept the seq of request, e.g. /send/{id}/{seq}, false if it is replayed.
+// the clients start at different sequences, e.g. ffmpeg starts at 0, and librtmp at 2,
+// for it counts the /open/1, and the requests may arrive out of order or be missing,
+// so only the sequences seen, or too old to remember, are rejected.
+func (c *conn) acceptSeq(seq uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.seqStarted {
+		c.seq, c.seqSeen, c.seqStarted = seq, 1, true
+		return true
+	}
+
+	if seq > c.seq {
+		if n := seq - c.seq; n < seqWindow {
+			c.seqSeen = c.seqSeen<<n | 1
+		} else {
+			c.seqSeen = 1
+		}
+		c.seq = seq
+		return true
+	}
+
+	n := c.seq - seq
+	if n >= seqWindow || 0 != c.seqSeen&(1<<n) {
+		return false
+	}
+	c.seqSeen |= 1 << n
+
+	return true
+}
+
+func (c *conn) idleTime() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return time.Since(c.lastActive)
+}
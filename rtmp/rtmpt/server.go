@@ -0,0 +1,275 @@
+package rtmpt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/calabashdad/utiltools"
+)
+
+const (
+	// contentType the content type of rtmpt requests and responses.
+	contentType = "application/x-fcs"
+	// maxInterval the max polling interval hint told to client.
+	maxInterval = 0x21
+	// sessionTimeout close the session when the client has not polled for it.
+	sessionTimeout = 30 * time.Second
+	// maxRequestBytes the max body size of a send request.
+	maxRequestBytes = 4 * 1024 * 1024
+)
+
+// Server rtmpt server, serve the /open, /send, /idle, /close requests,
+// every session is a virtual connection handled by handler, e.g. rtmp cycle.
+type Server struct {
+	handler func(c net.Conn)
+	logger  *log.Logger
+
+	mu       sync.Mutex
+	sessions map[string]*conn
+
+	// done closed when the server closed, stop checking the sessions.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewServer create a rtmpt server, log to logger, or the std log if nil.
+func NewServer(handler func(c net.Conn), logger *log.Logger) *Server {
+	if nil == logger {
+		logger = log.Default()
+	}
+
+	s := &Server{
+		handler:  handler,
+		logger:   logger,
+		sessions: make(map[string]*conn),
+		done:     make(chan struct{}),
+	}
+
+	go s.checkSessions()
+
+	return s
+}
+
+// ServeHTTP .
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	if "POST" != r.Method {
+		http.Error(w, "rtmpt only support post", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// e.g. /open/1, /send/{id}/{seq}, /idle/{id}/{seq}, /close/{id}/{seq}
+	paths := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch paths[0] {
+	case "open":
+		s.open(w, r)
+	case "send", "idle", "close":
+		if len(paths) < 3 {
+			http.Error(w, "rtmpt path error, no session id or sequence", http.StatusBadRequest)
+			return
+		}
+
+		c := s.getSession(paths[1])
+		if nil == c {
+			http.NotFound(w, r)
+			return
+		}
+
+		// reject the replayed requests of session.
+		seq, err := strconv.ParseUint(paths[2], 10, 64)
+		if err != nil || !c.acceptSeq(seq) {
+			s.logger.Println("rtmpt session sequence error, id=", c.id, ", seq=", paths[2])
+			http.Error(w, "rtmpt sequence error", http.StatusBadRequest)
+			return
+		}
+
+		switch paths[0] {
+		case "send":
+			s.send(c, w, r)
+		case "idle":
+			s.response(c, w)
+		case "close":
+			s.close(c, w)
+		}
+	default:
+		// e.g. /fcs/ident2, flash try it first, and continue with /open when not found.
+		http.NotFound(w, r)
+	}
+}
+
+// Close stop checking the sessions and close all the sessions, the new sessions are rejected,
+// e.g. when the http server shutdown.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*conn)
+	s.mu.Unlock()
+
+	for _, c := range sessions {
+		c.Close()
+	}
+}
+
+func (s *Server) isClosed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Server) open(w http.ResponseWriter, r *http.Request) {
+	if s.isClosed() {
+		http.Error(w, "rtmpt server closed", http.StatusServiceUnavailable)
+		return
+	}
+
+	var id string
+	if true {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			http.Error(w, "rtmpt create session failed", http.StatusInternalServerError)
+			return
+		}
+		id = hex.EncodeToString(b)
+	}
+
+	c := newConn(id, r.Host, r.RemoteAddr)
+
+	s.mu.Lock()
+	s.sessions[id] = c
+	s.mu.Unlock()
+
+	s.logger.Println("rtmpt session open, id=", id, ", remote=", r.RemoteAddr)
+
+	go s.serve(c)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(id + "\n"))
+}
+
+func (s *Server) serve(c *conn) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.logger.Println(utiltools.PanicTrace())
+		}
+
+		c.Close()
+		s.removeSession(c)
+	}()
+
+	s.handler(c)
+}
+
+func (s *Server) send(c *conn, w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		http.Error(w, "rtmpt read request body failed", http.StatusBadRequest)
+		return
+	}
+
+	// the handler does not read in time, close the session rather than buffer without limit.
+	if err = c.feed(data); err != nil {
+		c.Close()
+		s.removeSession(c)
+
+		s.logger.Println("rtmpt session close, id=", c.id, ", err=", err)
+		http.Error(w, "rtmpt session buffer full", http.StatusServiceUnavailable)
+		return
+	}
+
+	s.response(c, w)
+}
+
+// response send the interval and the pending data to client.
+func (s *Server) response(c *conn, w http.ResponseWriter) {
+	data := c.drain()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	w.Write([]byte{c.nextInterval(len(data) > 0)})
+	w.Write(data)
+}
+
+func (s *Server) close(c *conn, w http.ResponseWriter) {
+	c.Close()
+	s.removeSession(c)
+
+	s.logger.Println("rtmpt session close, id=", c.id)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte{0})
+}
+
+func (s *Server) getSession(id string) *conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sessions[id]
+}
+
+func (s *Server) removeSession(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c == s.sessions[c.id] {
+		delete(s.sessions, c.id)
+	}
+}
+
+// checkSessions close the sessions which the client has gone away.
+func (s *Server) checkSessions() {
+	defer func() {
+		if err := recover(); err != nil {
+			s.logger.Println(utiltools.PanicTrace())
+		}
+	}()
+
+	ticker := time.NewTicker(sessionTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return
+		}
+
+		var timeouts []*conn
+
+		s.mu.Lock()
+		for _, c := range s.sessions {
+			if c.idleTime() > sessionTimeout {
+				timeouts = append(timeouts, c)
+			}
+		}
+		s.mu.Unlock()
+
+		for _, c := range timeouts {
+			s.logger.Println("rtmpt session timeout, id=", c.id)
+			c.Close()
+			s.removeSession(c)
+		}
+	}
+}
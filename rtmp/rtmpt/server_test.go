@@ -0,0 +1,146 @@
+package rtmpt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newEchoServer serve a rtmpt server which echoes the data of sessions.
+func newEchoServer(t *testing.T) (*Server, *httptest.Server) {
+	s := NewServer(func(c net.Conn) {
+		io.Copy(c, c)
+	}, nil)
+
+	hs := httptest.NewServer(s)
+	t.Cleanup(func() {
+		hs.Close()
+		s.Close()
+	})
+
+	return s, hs
+}
+
+func TestClientEcho(t *testing.T) {
+	_, hs := newEchoServer(t)
+
+	c, err := Dial(hs.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// larger than a poll, carried by many requests.
+	data := bytes.Repeat([]byte("rtmpt"), 64*1024)
+	go c.Write(data)
+
+	got := make([]byte, len(data))
+	if _, err = io.ReadFull(c, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, got) {
+		t.Fatal("echo data mismatch")
+	}
+}
+
+// openSession open a session of the rtmpt server, return the id.
+func openSession(t *testing.T, url string) string {
+	data, err := post(url+"/open/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func TestSequence(t *testing.T) {
+	_, hs := newEchoServer(t)
+
+	// the requests after /open/1 of real clients, and the replayed or invalid are rejected.
+	for _, v := range []struct {
+		name string
+		seqs []string
+		ok   []bool
+	}{
+		{"ffmpeg", []string{"0", "1", "2", "3"}, []bool{true, true, true, true}},
+		{"librtmp", []string{"2", "3", "4", "5"}, []bool{true, true, true, true}},
+		{"out of order", []string{"1", "3", "2", "5"}, []bool{true, true, true, true}},
+		{"replayed", []string{"1", "2", "2", "1"}, []bool{true, true, false, false}},
+		{"too old", []string{"1", "100", "20", "101"}, []bool{true, true, false, true}},
+		{"invalid", []string{"1", "x", "-1", "2"}, []bool{true, false, false, true}},
+	} {
+		id := openSession(t, hs.URL)
+
+		for i, seq := range v.seqs {
+			_, err := post(fmt.Sprintf("%s/idle/%s/%s", hs.URL, id, seq), nil)
+			if v.ok[i] != (nil == err) {
+				t.Errorf("%s: seq=%s accepted=%v, expect %v", v.name, seq, nil == err, v.ok[i])
+			}
+		}
+	}
+
+	id := openSession(t, hs.URL)
+	if _, err := post(fmt.Sprintf("%s/idle/%s", hs.URL, id), nil); nil == err {
+		t.Fatal("no sequence should be rejected")
+	}
+}
+
+func TestBufferFull(t *testing.T) {
+	// the handler never reads until the test done.
+	done := make(chan struct{})
+	s := NewServer(func(c net.Conn) {
+		<-done
+	}, nil)
+
+	hs := httptest.NewServer(s)
+	defer func() {
+		close(done)
+		hs.Close()
+		s.Close()
+	}()
+
+	id := openSession(t, hs.URL)
+
+	data := make([]byte, maxRequestBytes)
+	if _, err := post(fmt.Sprintf("%s/send/%s/1", hs.URL, id), data); err != nil {
+		t.Fatal(err)
+	}
+
+	// the data not read exceed the limit, the session is closed.
+	if _, err := post(fmt.Sprintf("%s/send/%s/2", hs.URL, id), []byte("a")); nil == err {
+		t.Fatal("send should be rejected when buffer full")
+	}
+
+	if _, err := post(fmt.Sprintf("%s/idle/%s/3", hs.URL, id), nil); nil == err {
+		t.Fatal("session should be closed when buffer full")
+	}
+}
+
+func TestClose(t *testing.T) {
+	s, hs := newEchoServer(t)
+
+	c, err := Dial(hs.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Close()
+
+	// the session is closed, and the client quits when poll failed.
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err = c.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatal("session should be closed, err=", err)
+	}
+
+	if _, err = Dial(hs.URL); nil == err {
+		t.Fatal("open should be rejected after closed")
+	}
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"runtime"
 	"seal/conf"
-	"sync"
+	"seal/server"
+	"syscall"
 	"time"
 
 	"github.com/calabashdad/utiltools"
+	"github.com/yaml"
 )
 
 const sealVersion = "seal: 1.0.0 build-2018052801"
@@ -17,10 +22,7 @@ const sealVersion = "seal: 1.0.0 build-2018052801"
 var (
 	configFile  = flag.String("c", "./seal.yaml", "configure filename")
 	showVersion = flag.Bool("v", false, "show version of seal")
-)
-
-var (
-	gGuards sync.WaitGroup
+	testConfig  = flag.Bool("t", false, "test the configure file, print the effective configure and quit")
 )
 
 func init() {
@@ -49,6 +51,14 @@ func main() {
 	err := conf.GlobalConfInfo.Loads(*configFile)
 	if err != nil {
 		log.Println("conf loads failed.err=", err)
+		if *testConfig {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *testConfig {
+		printConfig(&conf.GlobalConfInfo)
 		return
 	}
 
@@ -63,18 +73,57 @@ func main() {
 		log.Println("app run on cpu nums set by config, num=", conf.GlobalConfInfo.System.CPUNums)
 	}
 
-	gGuards.Add(1)
-	if true {
-		rtmpSrv := rtmpServer{}
-		go rtmpSrv.Start()
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	gGuards.Add(1)
-	if true {
-		hlsSrv := hlsServer{}
-		go hlsSrv.Start()
+	srv := server.New(&conf.GlobalConfInfo)
+	srv.ConfigFile = *configFile
+	if err = srv.Start(ctx); err != nil {
+		log.Println("start server failed.err=", err)
+		return
 	}
 
-	gGuards.Wait()
+	go handleSignals(srv, cancel)
+
+	srv.Wait()
 	log.Println("seal quit gracefully.")
 }
+
+// printConfig print the effective config in yaml, with the defaults and environment overrides.
+func printConfig(c *conf.Config) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		log.Println("marshal conf failed.err=", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(string(data))
+	log.Println("conf file", *configFile, "test success.")
+}
+
+// handleSignals reload the config when got SIGHUP,
+// shutdown the server gracefully when got SIGTERM or SIGINT, quit immediately when got again.
+func handleSignals(srv *server.Server, shutdown context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM, os.Interrupt)
+
+	stopping := false
+
+	for sig := range signals {
+		if syscall.SIGHUP == sig {
+			if _, err := srv.Reload(); err != nil {
+				log.Println("reload config failed, keep the current, err=", err)
+			}
+			continue
+		}
+
+		if stopping {
+			log.Println("got signal=", sig, " again, quit now.")
+			os.Exit(1)
+		}
+
+		log.Println("got signal=", sig, ", shutdown gracefully.")
+		stopping = true
+		shutdown()
+	}
+}
@@ -0,0 +1,365 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/calabashdad/utiltools"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"seal/auth"
+	"seal/hooks"
+	"seal/rtmp/co"
+	"seal/rtmp/rtmpt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Handler the http handler of hls, http-flv, and rtmpt if enabled,
+// served at the http listen of hls config when started.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/live/", s.handleLive)
+
+	if "true" == s.Config().Hls.Rtmpt {
+		rtmptSrv := rtmpt.NewServer(func(c net.Conn) {
+			s.println("one rtmpt connection come in, remote=", c.RemoteAddr())
+			s.sources.NewRtmpConnection(c).Cycle()
+		}, s.logger())
+
+		s.rtmptLock.Lock()
+		s.rtmptServers = append(s.rtmptServers, rtmptSrv)
+		s.rtmptLock.Unlock()
+
+		for _, v := range []string{"/open/", "/send/", "/idle/", "/close/", "/fcs/"} {
+			mux.Handle(v, rtmptSrv)
+		}
+		s.println("rtmpt enabled in hls server")
+	}
+
+	return mux
+}
+
+var crossdomainxml = []byte(
+	`<?xml version="1.0" ?><cross-domain-policy>
+			<allow-access-from domain="*" />
+			<allow-http-request-headers-from domain="*" headers="*"/>
+		</cross-domain-policy>`)
+
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.println(utiltools.PanicTrace())
+		}
+	}()
+
+	if path.Base(r.URL.Path) == "crossdomain.xml" {
+
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write(crossdomainxml)
+		return
+	}
+
+	vhost := s.vhostOf(r)
+
+	ext := path.Ext(r.URL.Path)
+	switch ext {
+	case ".m3u8":
+		app, m3u8 := parseM3u8File(r.URL.Path)
+		if !s.authPlay(w, r, vhost, app, strings.TrimSuffix(m3u8, ext)) {
+			return
+		}
+		m3u8 = s.Config().StreamConfig(vhost, app).Hls.HlsPath + "/" + app + "/" + m3u8
+		if data, err := s.loadFile(m3u8); nil == err {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("Content-Type", "application/x-mpegURL")
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			if _, err = w.Write(data); err != nil {
+				s.println("write m3u8 file err=", err)
+			}
+		}
+	case ".ts":
+		app, ts := parseTsFile(r.URL.Path)
+		ts = s.Config().StreamConfig(vhost, app).Hls.HlsPath + "/" + app + "/" + ts
+		if data, err := s.loadFile(ts); nil == err {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Content-Type", "video/mp2ts")
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			if _, err = w.Write(data); err != nil {
+				s.println("write ts file err=", err)
+			}
+		}
+	case ".flv":
+		u := r.URL.Path
+
+		path := strings.TrimSuffix(strings.TrimLeft(u, "/"), ".flv")
+		paths := strings.SplitN(path, "/", 2)
+
+		if len(paths) != 2 {
+			http.Error(w, "http-flv path error, should be /live/stream.flv", http.StatusBadRequest)
+			return
+		}
+		s.println("url:", u, "path:", path, "paths:", paths)
+
+		if !s.authPlay(w, r, vhost, paths[0], paths[1]) {
+			return
+		}
+
+		event := hooks.Event{
+			Action:     hooks.OnPlay,
+			Vhost:      vhost,
+			App:        paths[0],
+			Stream:     paths[1],
+			Param:      r.URL.RawQuery,
+			PageURL:    r.Referer(),
+			RemoteAddr: r.RemoteAddr,
+		}
+		if err := s.sources.Hooks().Call(&event); err != nil {
+			http.Error(w, "play denied, "+err.Error(), http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+
+		s.println("http flv request, remote=", r.RemoteAddr)
+
+		s.httpFlvStreamCycle(r, vhost, paths[0], paths[1], w)
+
+		stop := event
+		stop.Action = hooks.OnStop
+		s.sources.Hooks().Notify(&stop)
+	default:
+		s.println("unknown hls request file, type=", ext)
+	}
+}
+
+// authPlay check whether the http client can play the stream, response 403 if rejected.
+// notice that the ts is not checked, for the url of ts in m3u8 has no params.
+func (s *Server) authPlay(w http.ResponseWriter, r *http.Request, vhost string, app string, stream string) bool {
+	info := auth.Info{
+		Action:     auth.ActionPlay,
+		Vhost:      vhost,
+		App:        app,
+		Stream:     stream,
+		Params:     r.URL.Query(),
+		PageURL:    r.Referer(),
+		RemoteAddr: r.RemoteAddr,
+	}
+
+	if err := s.sources.Auth().Check(&info); err != nil {
+		http.Error(w, "play rejected, "+err.Error(), http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// vhostOf the vhost configured of the param vhost or the host of request,
+// e.g. http://127.0.0.1:7001/live/test.flv?vhost=xxx
+// notice that the ts has no params, use the host to play hls of vhost.
+func (s *Server) vhostOf(r *http.Request) string {
+	host := r.URL.Query().Get("vhost")
+	if 0 == len(host) {
+		host = r.Host
+		if h, _, err := net.SplitHostPort(r.Host); nil == err {
+			host = h
+		}
+	}
+
+	return s.Config().Vhost(host)
+}
+
+func parseM3u8File(p string) (app string, m3u8File string) {
+	if i := strings.Index(p, "/"); i >= 0 {
+		if j := strings.LastIndex(p, "/"); j > 0 {
+			app = p[i+1 : j]
+		}
+	}
+
+	if i := strings.LastIndex(p, "/"); i > 0 {
+		m3u8File = p[i+1:]
+	}
+
+	return
+}
+
+func parseTsFile(p string) (app string, tsFile string) {
+	if i := strings.Index(p, "/"); i >= 0 {
+		if j := strings.LastIndex(p, "/"); j > 0 {
+			app = p[i+1 : j]
+		}
+	}
+
+	if i := strings.LastIndex(p, "/"); i > 0 {
+		tsFile = p[i+1:]
+	}
+
+	return
+}
+
+func (s *Server) loadFile(filename string) (data []byte, err error) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.println(utiltools.PanicTrace())
+		}
+	}()
+
+	var f *os.File
+	if f, err = os.Open(filename); err != nil {
+		s.println("Open file ", filename, " failed, err is", err)
+		return
+	}
+	defer f.Close()
+
+	if data, err = ioutil.ReadAll(f); err != nil {
+		s.println("read file ", filename, " failed, err is", err)
+		return
+	}
+
+	return
+}
+
+// httpFlvStreamCycle play the stream of vhost/app/stream to the http-flv client,
+// the player is registered in the connections of hub, which can be kicked by id.
+func (s *Server) httpFlvStreamCycle(r *http.Request, vhost string, app string, stream string, w http.ResponseWriter) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.println(utiltools.PanicTrace())
+		}
+	}()
+
+	var err error
+
+	key := co.StreamKey(vhost, app, stream)
+	addr := r.RemoteAddr
+
+	// canceled when kicked, or the client closed.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	source := s.sources.FindSourceToPlay(key)
+	if nil == source {
+		s.printf("httpFlvStreamCycle, stream=%s can not play because has not published\n", key)
+		http.Error(w, "this stream has not published", http.StatusBadRequest)
+		return
+	}
+
+	consumer := co.NewConsumer("http-flv/" + key)
+	source.CreateConsumer(consumer)
+
+	//dump the cached metadata, sequence headers and gop cache to client.
+	source.DumpCache(consumer)
+
+	fs := s.sources.Conns().AddFlvSession(addr, vhost, app, stream, consumer, cancel)
+	defer s.sources.Conns().RemoveFlvSession(fs)
+
+	s.printf("httpFlvStreamCycle now playing, key=%s, remote=%s, id=%d", key, addr, fs.ID())
+
+	//f, err := os.OpenFile("/Users/yangkai/go/src/seal/test.flv", os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+
+	// send flv header
+	flvHeader := []byte{0x46, 0x4c, 0x56, 0x01, 0x05, 0x00, 0x00, 0x00, 0x09}
+	if _, err = w.Write(flvHeader); err != nil {
+		s.println("httpFlvStreamCycle send flv header to remote success.")
+		return
+	}
+	//f.Write(flvHeader)
+	s.printf("httpFlv,key=%s, send flv header to remote sucess\n", key)
+
+	timeLast := time.Now().Unix()
+
+	var previousTagLen uint32
+	for {
+		if consumer.Kicked() || nil != ctx.Err() {
+			s.println("httpFlvStreamCycle player is kicked or closed, key=", key)
+			break
+		}
+
+		msg := consumer.Dump()
+		if nil == msg {
+			// wait and try again.
+			consumer.Wait(time.Duration(1) * time.Second)
+
+			timeCurrent := time.Now().Unix()
+			if timeCurrent-timeLast > 30 {
+				s.println("httpFlvStreamCycle time out > 30, break. key=", key)
+				break
+			}
+
+			continue
+		} else {
+
+			timeLast = time.Now().Unix()
+
+			// previous tag len c4B. 11 + payload data size
+			// type 1B
+			// data size 3B
+			// timestamp 3B
+			// timestampEx 1B
+			// streamID 3B always is 0
+			// total is 4 + 1 +3 + 3 + 1 + 3 = 15B
+			var tagHeader [15]uint8
+			var offset uint32
+
+			// previous tag len
+			binary.BigEndian.PutUint32(tagHeader[offset:], previousTagLen)
+			offset += 4
+
+			// type
+			tagHeader[offset] = msg.Header.MessageType
+			offset++
+
+			// payload data size
+			var sizebuf [4]uint8
+			binary.BigEndian.PutUint32(sizebuf[:], msg.Header.PayloadLength)
+			copy(tagHeader[offset:], sizebuf[1:])
+			offset += 3
+
+			// timestamp
+			var timebuf [4]uint8
+			binary.BigEndian.PutUint32(timebuf[:], uint32(msg.Header.Timestamp))
+			copy(tagHeader[offset:], timebuf[1:])
+			offset += 3
+
+			// timestamp ex, generally not used
+			tagHeader[offset] = 0
+			offset++
+
+			// stream id
+			tagHeader[offset] = 0
+			offset++
+			tagHeader[offset] = 0
+			offset++
+			tagHeader[offset] = 0
+			offset++
+
+			if _, err = w.Write(tagHeader[:]); err != nil {
+				s.println("httpFlvStreamCycle: playing... send tag header to remote failed.err=", err)
+				break
+			}
+			//f.Write(tagHeader[:])
+
+			_, err = w.Write(msg.Payload.Payload)
+			consumer.Release()
+
+			if err != nil {
+				s.println("httpFlvStreamCycle: playing... send tag payload to remote failed.err=", err)
+				break
+			}
+			//f.Write(msg.Payload.Payload)
+
+			consumer.AddSendBytes(uint32(len(tagHeader)) + msg.Header.PayloadLength)
+
+			previousTagLen = 11 + msg.Header.PayloadLength
+		}
+	}
+
+	consumer.Clean()
+	source.DestroyConsumer(consumer)
+	s.printf("httpFlvStreamCycle: playing over, key=%s, consumer has destroyed\n", key)
+
+}
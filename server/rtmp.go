@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/calabashdad/utiltools"
+)
+
+// listenTLS listen rtmps, rtmp over tls.
+func (s *Server) listenTLS() (listener net.Listener, err error) {
+	c := s.Config()
+
+	var cert tls.Certificate
+	if cert, err = tls.LoadX509KeyPair(c.Rtmp.TLSCert, c.Rtmp.TLSKey); err != nil {
+		return
+	}
+
+	return tls.Listen("tcp", ":"+c.Rtmp.TLSListen, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	})
+}
+
+// serve accept the rtmp and rtmps connections in background, until the listener closed.
+func (s *Server) serve(listener net.Listener) {
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				s.println(utiltools.PanicTrace())
+			}
+
+			s.wg.Done()
+		}()
+
+		err := s.accept(listener)
+
+		s.println("rtmp server quit, addr=", listener.Addr(), ", err=", err)
+	}()
+}
+
+func (s *Server) accept(listener net.Listener) (err error) {
+	for {
+		var netConn net.Conn
+		if netConn, err = listener.Accept(); err != nil {
+			break
+		}
+
+		s.println("one rtmp connection come in, remote=", netConn.RemoteAddr())
+		s.sources.Metrics().ConnsAccepted.Inc()
+
+		s.wg.Add(1)
+		if tlsConn, ok := netConn.(*tls.Conn); ok {
+			go s.tlsCycle(tlsConn)
+		} else {
+			go s.cycle(netConn)
+		}
+	}
+
+	return
+}
+
+// cycle serve the rtmp connection until it quit.
+func (s *Server) cycle(c net.Conn) {
+	defer s.wg.Done()
+
+	rtmpConn := s.sources.NewRtmpConnection(c)
+	rtmpConn.Cycle()
+}
+
+// tlsCycle do the tls handshake with timeout, then serve as rtmp.
+func (s *Server) tlsCycle(c *tls.Conn) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.println(utiltools.PanicTrace())
+		}
+
+		s.wg.Done()
+	}()
+
+	c.SetDeadline(time.Now().Add(time.Duration(s.Config().Rtmp.TimeOut) * time.Second))
+
+	if err := c.Handshake(); err != nil {
+		s.println("rtmps tls handshake failed, remote=", c.RemoteAddr(), ", err=", err)
+		c.Close()
+		return
+	}
+
+	c.SetDeadline(time.Time{})
+
+	rtmpConn := s.sources.NewRtmpConnection(c)
+	rtmpConn.Cycle()
+}
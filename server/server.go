@@ -0,0 +1,372 @@
+// Package server the embeddable seal server, serves rtmp, rtmps, hls, http-flv, rtmpt and stats api
+// with its own config and sources, so that multiple servers can run in one process, e.g. in tests.
+//
+// the auth, hooks, metrics and logger are of each server too.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"seal/conf"
+	"seal/rtmp/co"
+	"seal/rtmp/rtmpt"
+	"sync"
+	"time"
+
+	"github.com/calabashdad/utiltools"
+)
+
+// defaultShutdownTimeoutSeconds the timeout to wait for clients to quit when shutdown, if not configured.
+const defaultShutdownTimeoutSeconds = 30
+
+// Server the seal server created by config.
+type Server struct {
+	// Logger the logger of server, and the sources and connections of server, use the std log if nil.
+	// must be set before started.
+	Logger *log.Logger
+	// ConfigFile the config file to reload, reload is disabled if empty.
+	ConfigFile string
+
+	sources *co.SourceHub
+	// reloadLock the reloads are serialized.
+	reloadLock sync.Mutex
+
+	mu       sync.Mutex
+	started  bool
+	shutdown bool
+
+	rtmpListener  net.Listener
+	tlsListener   net.Listener
+	httpListener  net.Listener
+	statsListener net.Listener
+
+	httpServers []*http.Server
+	pulls       []*co.PullClient
+
+	// rtmptServers the rtmpt servers of the handlers created, closed when shutdown.
+	rtmptLock    sync.Mutex
+	rtmptServers []*rtmpt.Server
+
+	// wg the serving goroutines, the rtmp connections and the ingest.
+	wg sync.WaitGroup
+	// done closed when shutdown finished.
+	done chan struct{}
+}
+
+// New create a server with config c, the defaults of config are filled when start,
+// the config must not be changed after started,
+// use Reload to apply a new config.
+func New(c *conf.Config) *Server {
+	return &Server{
+		sources: co.NewSourceHub(c),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start listen and serve in background, and start the ingest,
+// the server is shutdown when ctx is done, wait for the clients to quit until the shutdown timeout of config.
+// use the port 0 in config to listen at a random port, and get it by the addr methods.
+func (s *Server) Start(ctx context.Context) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return errors.New("server already started")
+	}
+	s.started = true
+
+	// fill the defaults and check the config, which may be created by code but not loaded from file.
+	if err = s.Config().Validate(); err != nil {
+		return
+	}
+
+	// the auth and hooks are created with the config before validated.
+	s.sources.SetLogger(s.logger())
+	if err = s.sources.SetConfig(s.Config()); err != nil {
+		return
+	}
+
+	if err = s.listen(); err != nil {
+		closeListeners(s.rtmpListener, s.tlsListener, s.httpListener, s.statsListener)
+		return
+	}
+
+	if nil != s.rtmpListener {
+		s.println("rtmp server start listen at", s.rtmpListener.Addr())
+		s.serve(s.rtmpListener)
+	}
+
+	if nil != s.tlsListener {
+		s.println("rtmps server start listen at", s.tlsListener.Addr())
+		s.serve(s.tlsListener)
+	}
+
+	if nil != s.httpListener {
+		s.println("hls server start listen at", s.httpListener.Addr())
+		s.serveHTTP(s.httpListener, s.Handler())
+	} else {
+		s.println("hls server disabled")
+	}
+
+	if nil != s.statsListener {
+		s.println("stats server start listen at", s.statsListener.Addr())
+		s.serveHTTP(s.statsListener, s.StatsHandler())
+	} else {
+		s.println("stats server disabled")
+	}
+
+	// pull streams from remote servers
+	for _, v := range s.Config().Ingest {
+		s.println("start ingest, url=", v.URL, ", stream=", v.Stream)
+
+		pc := s.sources.NewPullClient(v.URL, v.Stream)
+		s.pulls = append(s.pulls, pc)
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			pc.Start()
+		}()
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+			return
+		}
+
+		timeout := s.Config().System.ShutdownTimeout
+		if 0 == timeout {
+			timeout = defaultShutdownTimeoutSeconds
+		}
+
+		c, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		defer cancel()
+
+		s.Shutdown(c)
+	}()
+
+	return
+}
+
+// listen listen all the ports of config, must be called with lock held.
+func (s *Server) listen() (err error) {
+	c := s.Config()
+
+	if s.rtmpListener, err = net.Listen("tcp", ":"+c.Rtmp.Listen); err != nil {
+		return fmt.Errorf("rtmp listen at %s failed, err=%v", c.Rtmp.Listen, err)
+	}
+
+	if 0 != len(c.Rtmp.TLSListen) {
+		if s.tlsListener, err = s.listenTLS(); err != nil {
+			return fmt.Errorf("rtmps listen at %s failed, err=%v", c.Rtmp.TLSListen, err)
+		}
+	}
+
+	if "true" == c.Hls.Enable {
+		if s.httpListener, err = net.Listen("tcp", ":"+c.Hls.HttpListen); err != nil {
+			return fmt.Errorf("hls listen at %s failed, err=%v", c.Hls.HttpListen, err)
+		}
+	}
+
+	if 0 != len(c.Stats.Listen) {
+		if s.statsListener, err = net.Listen("tcp", net.JoinHostPort(c.Stats.Host, c.Stats.Listen)); err != nil {
+			return fmt.Errorf("stats listen at %s failed, err=%v", c.Stats.Listen, err)
+		}
+	}
+
+	return
+}
+
+// serveHTTP serve the http handler in background, shutdown with the server.
+func (s *Server) serveHTTP(l net.Listener, handler http.Handler) {
+	srv := &http.Server{
+		Handler:  handler,
+		ErrorLog: s.logger(),
+	}
+	s.httpServers = append(s.httpServers, srv)
+
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				s.println(utiltools.PanicTrace())
+			}
+
+			s.wg.Done()
+		}()
+
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			s.println("http server quit, addr=", l.Addr(), ", err=", err)
+		}
+	}()
+}
+
+// Shutdown stop listening and ingest, ask the clients to quit gracefully and wait until ctx is done,
+// the rtmp clients are notified by status, the http-flv responses are ended,
+// and the hls segments are closed when the publishers quit.
+// then drop all the streams and disconnect the clients left.
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		return
+	}
+	s.shutdown = true
+
+	// the http listeners are closed by the shutdown of http servers.
+	closeListeners(s.rtmpListener, s.tlsListener)
+
+	for _, v := range s.pulls {
+		v.Stop()
+	}
+	s.mu.Unlock()
+
+	defer close(s.done)
+
+	// the http servers stop accepting, and wait for the handlers to quit, e.g. the http-flv players.
+	errs := make(chan error, len(s.httpServers))
+	for _, v := range s.httpServers {
+		go func(srv *http.Server) {
+			errs <- srv.Shutdown(ctx)
+		}(v)
+	}
+
+	s.sources.Stop()
+
+	for range s.httpServers {
+		if e := <-errs; e != nil && nil == err {
+			err = e
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// force to quit the left, e.g. the edge and forwarders, or the clients not quit in time.
+	s.rtmptLock.Lock()
+	for _, v := range s.rtmptServers {
+		v.Close()
+	}
+	s.rtmptLock.Unlock()
+
+	s.sources.Close()
+
+	s.println("server shutdown, err=", err)
+
+	return
+}
+
+// closeListeners close the listeners, the serving goroutines will quit.
+func closeListeners(listeners ...net.Listener) {
+	for _, v := range listeners {
+		if nil != v {
+			v.Close()
+		}
+	}
+}
+
+// Wait wait until the server is shutdown, must be called after started.
+func (s *Server) Wait() {
+	<-s.done
+}
+
+// Reload re-read the config file, apply the settings which can be applied live,
+// and return the settings changed which require restart, e.g. the listen ports.
+// the new config is applied to the streams and connections created after, e.g. the hls,
+// gop cache, consumer queue size, time jitter and forward, nothing is applied if invalid.
+func (s *Server) Reload() (restart []string, err error) {
+	s.reloadLock.Lock()
+	defer s.reloadLock.Unlock()
+
+	if 0 == len(s.ConfigFile) {
+		err = errors.New("no config file to reload")
+		return
+	}
+
+	c := &conf.Config{}
+	if err = c.Loads(s.ConfigFile); err != nil {
+		return
+	}
+
+	// the running listeners, ingesters and hooks queue use the old settings until restart.
+	restart = c.KeepRestartRequired(s.Config())
+
+	// the auth and hooks of server are reloaded too, nothing is applied if the auth is invalid.
+	if err = s.sources.SetConfig(c); err != nil {
+		return
+	}
+
+	s.println("reload config success, file=", s.ConfigFile, ", restart required=", restart)
+
+	return
+}
+
+// Config the config of server, the last reloaded.
+func (s *Server) Config() *conf.Config {
+	return s.sources.Config()
+}
+
+// Sources the sources and rtmp connections of server.
+func (s *Server) Sources() *co.SourceHub {
+	return s.sources
+}
+
+// RtmpAddr the address of rtmp listener, nil if not started.
+func (s *Server) RtmpAddr() net.Addr {
+	return addrOf(s.rtmpListener)
+}
+
+// RtmpsAddr the address of rtmps listener, nil if not started or disabled.
+func (s *Server) RtmpsAddr() net.Addr {
+	return addrOf(s.tlsListener)
+}
+
+// HTTPAddr the address of hls and http-flv listener, nil if not started or disabled.
+func (s *Server) HTTPAddr() net.Addr {
+	return addrOf(s.httpListener)
+}
+
+// StatsAddr the address of stats api listener, nil if not started or disabled.
+func (s *Server) StatsAddr() net.Addr {
+	return addrOf(s.statsListener)
+}
+
+func addrOf(l net.Listener) net.Addr {
+	if nil == l {
+		return nil
+	}
+
+	return l.Addr()
+}
+
+// logger the logger of server, the std log if not set.
+func (s *Server) logger() *log.Logger {
+	if nil != s.Logger {
+		return s.Logger
+	}
+
+	return log.Default()
+}
+
+func (s *Server) println(v ...interface{}) {
+	s.logger().Output(2, fmt.Sprintln(v...))
+}
+
+func (s *Server) printf(format string, v ...interface{}) {
+	s.logger().Output(2, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"seal/conf"
+	"testing"
+)
+
+// reload create a server with config of yaml running, then reload the config of yaml reloaded.
+func reload(t *testing.T, running string, reloaded string) (s *Server, restart []string, err error) {
+	c := &conf.Config{}
+	if err = c.Loads(writeConf(t, running)); err != nil {
+		t.Fatal(err)
+	}
+
+	s = New(c)
+	t.Cleanup(s.Sources().Close)
+
+	s.ConfigFile = writeConf(t, reloaded)
+	restart, err = s.Reload()
+
+	return
+}
+
+// writeConf write the yaml to a temp config file.
+func writeConf(t *testing.T, yaml string) string {
+	f, err := ioutil.TempFile("", "seal*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	if _, err = f.WriteString(yaml); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func TestReload(t *testing.T) {
+	// the live settings are applied, the restart required keep the running values.
+	s, restart, err := reload(t,
+		"rtmp:\n  listen: 1935\n  consumerQueueSize: 5\nhls:\n  enable: false\n",
+		"rtmp:\n  listen: 1999\n  consumerQueueSize: 7\nhls:\n  enable: true\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual([]string{"rtmp.listen", "hls.enable"}, restart) {
+		t.Errorf("restart required %v", restart)
+	}
+
+	if c := s.Config(); 7 != c.Rtmp.ConsumerQueueSize || "1935" != c.Rtmp.Listen || "false" != c.Hls.Enable {
+		t.Errorf("reloaded consumerQueueSize=%d, listen=%s, hls.enable=%s", c.Rtmp.ConsumerQueueSize, c.Rtmp.Listen, c.Hls.Enable)
+	}
+
+	// disabling hls is applied live to the new streams.
+	s, restart, err = reload(t, "hls:\n  enable: true\n", "hls:\n  enable: false\n")
+	if err != nil || 0 != len(restart) || "false" != s.Config().Hls.Enable {
+		t.Errorf("disable hls, restart required %v, hls.enable=%s, err=%v", restart, s.Config().Hls.Enable, err)
+	}
+
+	// nothing is applied if the config is invalid.
+	s, _, err = reload(t, "rtmp:\n  consumerQueueSize: 5\n", "rtmp:\n  consumerQueueSize: 9\nauth:\n  type: bogus\n")
+	if err == nil || 5 != s.Config().Rtmp.ConsumerQueueSize {
+		t.Errorf("invalid config applied, consumerQueueSize=%d, err=%v", s.Config().Rtmp.ConsumerQueueSize, err)
+	}
+}
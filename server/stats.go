@@ -0,0 +1,199 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"seal/metrics"
+	"strconv"
+	"strings"
+)
+
+// StatsHandler the http api to query the stats of streams and clients,
+// and the admin actions, e.g. kick client, drop stream,
+// served at the listen of stats config when started.
+// the requests must carry the token of stats config if configured,
+// by the header "Authorization: Bearer token" or the param token.
+func (s *Server) StatsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/streams", s.handleStreams)
+	mux.HandleFunc("/api/v1/streams/", s.handleStream)
+	mux.HandleFunc("/api/v1/clients", s.handleClients)
+	mux.HandleFunc("/api/v1/clients/", s.handleClient)
+	mux.HandleFunc("/api/v1/reload", s.handleReload)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+
+	return s.checkToken(mux)
+}
+
+// checkToken response 401 if the request has not the token of stats config,
+// the token is read when request, so it can be changed by reload.
+func (s *Server) checkToken(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := s.Config().Stats.Token
+
+		v := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			v = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		if 0 != len(token) && 1 != subtle.ConstantTimeCompare([]byte(v), []byte(token)) {
+			s.writeJSON(w, http.StatusUnauthorized, map[string]interface{}{
+				"code":  http.StatusUnauthorized,
+				"error": "invalid token",
+			})
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// handleStreams list all streams, e.g. http://127.0.0.1:7002/api/v1/streams
+func (s *Server) handleStreams(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code":    0,
+		"streams": s.sources.Stats(),
+	})
+}
+
+// handleStream query or drop one stream, or kick the players of stream.
+// GET http://127.0.0.1:7002/api/v1/streams/live/test, query the stream.
+// DELETE http://127.0.0.1:7002/api/v1/streams/live/test, drop the stream, disconnect the publisher and players.
+// DELETE http://127.0.0.1:7002/api/v1/streams/live/test/players, kick all players of the stream.
+// the stream of vhost is vhost/app/stream, e.g. http://127.0.0.1:7002/api/v1/streams/vhost/live/test
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	key := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/streams/"), "/")
+
+	if http.MethodDelete == r.Method {
+		if strings.HasSuffix(key, "/players") {
+			key = strings.TrimSuffix(key, "/players")
+
+			n, ok := s.sources.KickPlayers(key)
+			if !ok {
+				s.writeNotFound(w, "stream not found, key="+key)
+				return
+			}
+
+			s.writeJSON(w, http.StatusOK, map[string]interface{}{
+				"code":    0,
+				"players": n,
+			})
+			return
+		}
+
+		if !s.sources.Drop(key) {
+			s.writeNotFound(w, "stream not found, key="+key)
+			return
+		}
+
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"code": 0,
+		})
+		return
+	}
+
+	st, ok := s.sources.StreamStats(key)
+	if !ok {
+		s.writeNotFound(w, "stream not found, key="+key)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code":   0,
+		"stream": st,
+	})
+}
+
+// handleClients list all rtmp connections and http-flv players, e.g. http://127.0.0.1:7002/api/v1/clients
+func (s *Server) handleClients(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code":    0,
+		"clients": s.sources.Conns().Stats(),
+	})
+}
+
+// handleClient kick the rtmp connection or http-flv player by id,
+// e.g. DELETE http://127.0.0.1:7002/api/v1/clients/1
+func (s *Server) handleClient(w http.ResponseWriter, r *http.Request) {
+	if http.MethodDelete != r.Method {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	v := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/clients/"), "/")
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		http.Error(w, "invalid client id="+v, http.StatusBadRequest)
+		return
+	}
+
+	if !s.sources.Conns().Kick(id) {
+		s.writeNotFound(w, "client not found, id="+v)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code": 0,
+	})
+}
+
+// handleReload reload the config file, response the settings changed which require restart,
+// e.g. POST http://127.0.0.1:7002/api/v1/reload
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if http.MethodPost != r.Method {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	restart, err := s.Reload()
+	if err != nil {
+		s.writeJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"code":  http.StatusInternalServerError,
+			"error": "reload failed, " + err.Error(),
+		})
+		return
+	}
+
+	if nil == restart {
+		restart = []string{}
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code":            0,
+		"restartRequired": restart,
+	})
+}
+
+// handleMetrics expose the metrics in prometheus text format, e.g. http://127.0.0.1:7002/metrics
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.ContentType)
+
+	if err := s.sources.Metrics().WriteText(w); err != nil {
+		s.println("write metrics failed, err=", err)
+	}
+}
+
+func (s *Server) writeNotFound(w http.ResponseWriter, reason string) {
+	s.writeJSON(w, http.StatusNotFound, map[string]interface{}{
+		"code":  http.StatusNotFound,
+		"error": reason,
+	})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		s.println("stats marshal json failed, err=", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(status)
+
+	if _, err = w.Write(data); err != nil {
+		s.println("stats write response failed, err=", err)
+	}
+}
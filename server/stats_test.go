@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"seal/conf"
+	"testing"
+)
+
+func TestStatsToken(t *testing.T) {
+	c := &conf.Config{}
+	c.Stats.Token = "abc"
+
+	s := New(c)
+	defer s.Sources().Close()
+
+	ts := httptest.NewServer(s.StatsHandler())
+	defer ts.Close()
+
+	for _, v := range []struct {
+		name   string
+		url    string
+		header string
+		status int
+	}{
+		{"missing", "/api/v1/streams", "", http.StatusUnauthorized},
+		{"mismatch", "/api/v1/streams?token=xyz", "", http.StatusUnauthorized},
+		{"param", "/api/v1/streams?token=abc", "", http.StatusOK},
+		{"header", "/api/v1/clients", "Bearer abc", http.StatusOK},
+		{"header mismatch", "/metrics?token=abc", "Bearer xyz", http.StatusUnauthorized},
+		{"admin", "/api/v1/clients/1", "", http.StatusUnauthorized},
+	} {
+		req, _ := http.NewRequest(http.MethodDelete, ts.URL+v.url, nil)
+		if http.StatusOK == v.status {
+			req.Method = http.MethodGet
+		}
+		if 0 != len(v.header) {
+			req.Header.Set("Authorization", v.header)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if v.status != res.StatusCode {
+			t.Errorf("%s: status=%d, expect %d", v.name, res.StatusCode, v.status)
+		}
+	}
+}